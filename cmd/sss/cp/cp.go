@@ -9,7 +9,8 @@ import (
 )
 
 type flagpole struct {
-	URL string
+	URL   string
+	ToURL string
 }
 
 // NewCommand returns a new cobra.Command for cp
@@ -28,10 +29,19 @@ func NewCommand(ctx context.Context) *cobra.Command {
 			remote := args[0]
 			remoteOld := args[1]
 
-			return s.Copy(cmd.Context(), remoteOld, remote)
+			if flags.ToURL == "" {
+				return s.Copy(cmd.Context(), remoteOld, remote)
+			}
+
+			to, err := sss.NewSSS(sss.WithURL(flags.ToURL))
+			if err != nil {
+				return err
+			}
+			return sss.CopyBetween(cmd.Context(), s, remoteOld, to, remote)
 		},
 	}
 	cmd.Flags().StringVar(&flags.URL, "url", flags.URL, "config url")
+	cmd.Flags().StringVar(&flags.ToURL, "to-url", flags.ToURL, "config url of the destination, defaults to --url")
 
 	return cmd
 }
@@ -11,9 +11,12 @@ import (
 )
 
 type flagpole struct {
-	URL      string
-	Offset   int64
-	Continue bool
+	URL       string
+	Offset    int64
+	Continue  bool
+	Parallel  int
+	VersionID string
+	LimitRate int64
 }
 
 // NewCommand returns a new cobra.Command for get
@@ -24,14 +27,25 @@ func NewCommand(ctx context.Context) *cobra.Command {
 		Args: cobra.RangeArgs(1, 2),
 		Use:  "get <remote> [local]",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			s, err := sss.NewSSS(sss.WithURL(flags.URL))
+			s, err := sss.NewSSS(sss.WithURL(flags.URL), sss.WithBandwidthLimit(0, flags.LimitRate))
 			if err != nil {
 				return err
 			}
 
 			remote := args[0]
+			reader := func(offset int64) (io.ReadCloser, error) {
+				if flags.Parallel > 1 {
+					return s.ParallelReader(cmd.Context(), remote,
+						sss.WithReadOffset(offset),
+						sss.WithReadWindow(flags.Parallel),
+						sss.WithReadVersionID(flags.VersionID),
+					)
+				}
+				return s.ReaderWithOffset(cmd.Context(), remote, offset, sss.WithVersionID(flags.VersionID))
+			}
+
 			if len(args) == 1 {
-				rc, err := s.ReaderWithOffset(cmd.Context(), remote, flags.Offset)
+				rc, err := reader(flags.Offset)
 				if err != nil {
 					return err
 				}
@@ -49,7 +63,7 @@ func NewCommand(ctx context.Context) *cobra.Command {
 				}
 				defer f.Close()
 
-				rc, err := s.ReaderWithOffset(cmd.Context(), remote, flags.Offset)
+				rc, err := reader(flags.Offset)
 				if err != nil {
 					return err
 				}
@@ -70,7 +84,7 @@ func NewCommand(ctx context.Context) *cobra.Command {
 			}
 			defer f.Close()
 
-			rc, err := s.ReaderWithOffset(cmd.Context(), remote, offset)
+			rc, err := reader(offset)
 			if err != nil {
 				return err
 			}
@@ -83,6 +97,9 @@ func NewCommand(ctx context.Context) *cobra.Command {
 	cmd.Flags().StringVar(&flags.URL, "url", flags.URL, "config url")
 	cmd.Flags().Int64Var(&flags.Offset, "offset", flags.Offset, "offset")
 	cmd.Flags().BoolVar(&flags.Continue, "continue", flags.Continue, "continue")
+	cmd.Flags().IntVar(&flags.Parallel, "parallel", flags.Parallel, "number of ranges downloaded in parallel")
+	cmd.Flags().StringVar(&flags.VersionID, "version-id", flags.VersionID, "version of the object to get")
+	cmd.Flags().Int64Var(&flags.LimitRate, "limit-rate", flags.LimitRate, "maximum bytes per second downloaded, 0 for unlimited")
 
 	return cmd
 }
@@ -11,8 +11,9 @@ import (
 )
 
 type flagpole struct {
-	URL   string
-	Limit int
+	URL      string
+	Limit    int
+	Versions bool
 }
 
 // NewCommand returns a new cobra.Command for ls
@@ -36,6 +37,17 @@ func NewCommand(ctx context.Context) *cobra.Command {
 			}
 
 			var count int
+			if flags.Versions {
+				return s.ListVersions(ctx, remote, func(v sss.Version) bool {
+					count++
+					if v.IsDeleteMarker {
+						fmt.Println(v.Path(), v.VersionID, latest(v.IsLatest), "deleted", v.ModTime().Format(time.RFC3339))
+					} else {
+						fmt.Println(v.Path(), v.VersionID, latest(v.IsLatest), v.Size(), v.ModTime().Format(time.RFC3339))
+					}
+					return flags.Limit < 0 || count < flags.Limit
+				})
+			}
 			err = s.List(ctx, remote, func(fileInfo sss.FileInfo) bool {
 				count++
 				if fileInfo.IsDir() {
@@ -53,5 +65,13 @@ func NewCommand(ctx context.Context) *cobra.Command {
 	}
 	cmd.Flags().StringVar(&flags.URL, "url", flags.URL, "config url")
 	cmd.Flags().IntVar(&flags.Limit, "limit", flags.Limit, "maximum number to return")
+	cmd.Flags().BoolVar(&flags.Versions, "versions", flags.Versions, "list every version of the objects")
 	return cmd
 }
+
+func latest(isLatest bool) string {
+	if isLatest {
+		return "latest"
+	}
+	return "-"
+}
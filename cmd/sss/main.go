@@ -2,21 +2,26 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"log/slog"
 	"os"
 
 	"github.com/spf13/cobra"
 
+	"github.com/wzshiming/sss"
 	"github.com/wzshiming/sss/cmd/sss/cp"
 	"github.com/wzshiming/sss/cmd/sss/find"
 	"github.com/wzshiming/sss/cmd/sss/get"
 	"github.com/wzshiming/sss/cmd/sss/ls"
+	"github.com/wzshiming/sss/cmd/sss/mv"
 	"github.com/wzshiming/sss/cmd/sss/part"
 	"github.com/wzshiming/sss/cmd/sss/put"
 	"github.com/wzshiming/sss/cmd/sss/rm"
 	"github.com/wzshiming/sss/cmd/sss/serve"
 	"github.com/wzshiming/sss/cmd/sss/sign"
 	"github.com/wzshiming/sss/cmd/sss/stat"
+	"github.com/wzshiming/sss/cmd/sss/tag"
 )
 
 func main() {
@@ -31,13 +36,18 @@ func main() {
 
 // NewCommand returns a new cobra.Command for root
 func NewCommand(ctx context.Context) *cobra.Command {
+	logFormat := "text"
 	cmd := &cobra.Command{
 		Args: cobra.NoArgs,
 		Use:  "sss",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return setupLog(logFormat)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return cmd.Help()
 		},
 	}
+	cmd.PersistentFlags().StringVar(&logFormat, "log-format", logFormat, "format of the logs, text or json, their level is the loglevel of the url")
 
 	cmd.AddCommand(
 		sign.NewCommand(ctx),
@@ -47,9 +57,32 @@ func NewCommand(ctx context.Context) *cobra.Command {
 		find.NewCommand(ctx),
 		stat.NewCommand(ctx),
 		cp.NewCommand(ctx),
+		mv.NewCommand(ctx),
 		put.NewCommand(ctx),
 		rm.NewCommand(ctx),
+		tag.NewCommand(ctx),
 		serve.NewCommand(ctx),
 	)
 	return cmd
 }
+
+// setupLog sends every record to stderr in the given format, leaving the levels to the loglevel of the url.
+func setupLog(format string) error {
+	switch format {
+	case "text":
+		slog.SetLogLoggerLevel(sss.LevelTrace)
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+			Level: sss.LevelTrace,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.LevelKey && len(groups) == 0 && a.Value.Any() == sss.LevelTrace {
+					a.Value = slog.StringValue("TRACE")
+				}
+				return a
+			},
+		})))
+	default:
+		return fmt.Errorf("unknown log format %q, want text or json", format)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package mv
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wzshiming/sss"
+)
+
+type flagpole struct {
+	URL       string
+	Recursive bool
+	Rollback  bool
+}
+
+// NewCommand returns a new cobra.Command for mv
+func NewCommand(ctx context.Context) *cobra.Command {
+	flags := &flagpole{}
+
+	cmd := &cobra.Command{
+		Args: cobra.ExactArgs(2),
+		Use:  "mv <remote> <remote-old>",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := sss.NewSSS(sss.WithURL(flags.URL))
+			if err != nil {
+				return err
+			}
+
+			remote := args[0]
+			remoteOld := args[1]
+
+			if flags.Rollback {
+				return s.RollbackMove(cmd.Context(), remoteOld, remote)
+			}
+			if flags.Recursive {
+				return s.MoveAll(cmd.Context(), remoteOld, remote)
+			}
+			return s.Move(cmd.Context(), remoteOld, remote)
+		},
+	}
+	cmd.Flags().StringVar(&flags.URL, "url", flags.URL, "config url")
+	cmd.Flags().BoolVar(&flags.Recursive, "recursive", flags.Recursive, "recursive move, run again to finish an interrupted move")
+	cmd.Flags().BoolVar(&flags.Rollback, "rollback", flags.Rollback, "roll back an interrupted recursive move")
+	return cmd
+}
@@ -0,0 +1,57 @@
+package gc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wzshiming/sss"
+)
+
+type flagpole struct {
+	URL       string
+	OlderThan time.Duration
+	DryRun    bool
+}
+
+// NewCommand returns a new cobra.Command for gc
+func NewCommand(ctx context.Context) *cobra.Command {
+	flags := &flagpole{
+		OlderThan: 7 * 24 * time.Hour,
+	}
+
+	cmd := &cobra.Command{
+		Args: cobra.RangeArgs(0, 1),
+		Use:  "gc [remote]",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := sss.NewSSS(sss.WithURL(flags.URL))
+			if err != nil {
+				return err
+			}
+
+			var remote string = "/"
+			if len(args) != 0 {
+				remote = args[0]
+			}
+
+			aborted, err := s.AbortStaleMultipart(cmd.Context(), remote, flags.OlderThan, flags.DryRun)
+			var reclaimed int64
+			for _, mp := range aborted {
+				fmt.Println(mp.Key(), mp.Size, mp.Initiated().Format(time.RFC3339), mp.UploadID())
+				reclaimed += mp.Size
+			}
+			if flags.DryRun {
+				fmt.Printf("%d uploads, %d bytes to reclaim\n", len(aborted), reclaimed)
+			} else {
+				fmt.Printf("%d uploads aborted, %d bytes reclaimed\n", len(aborted), reclaimed)
+			}
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&flags.URL, "url", flags.URL, "config url")
+	cmd.Flags().DurationVar(&flags.OlderThan, "older-than", flags.OlderThan, "abort the uploads initiated longer ago than this")
+	cmd.Flags().BoolVar(&flags.DryRun, "dry-run", flags.DryRun, "only list the uploads that would be aborted")
+	return cmd
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/wzshiming/sss/cmd/sss/part/commit"
+	"github.com/wzshiming/sss/cmd/sss/part/gc"
 	"github.com/wzshiming/sss/cmd/sss/part/ls"
 	"github.com/wzshiming/sss/cmd/sss/part/rm"
 )
@@ -22,5 +23,6 @@ func NewCommand(ctx context.Context) *cobra.Command {
 	cmd.AddCommand(ls.NewCommand(ctx))
 	cmd.AddCommand(rm.NewCommand(ctx))
 	cmd.AddCommand(commit.NewCommand(ctx))
+	cmd.AddCommand(gc.NewCommand(ctx))
 	return cmd
 }
@@ -15,24 +15,30 @@ type flagpole struct {
 	Continue bool
 	Commit   bool
 	SHA256   string
+
+	Concurrency int
+	LimitRate   int64
 }
 
 // NewCommand returns a new cobra.Command for put
 func NewCommand(ctx context.Context) *cobra.Command {
 	flags := &flagpole{
-		Commit: true,
+		Commit:      true,
+		Concurrency: 1,
 	}
 
 	cmd := &cobra.Command{
 		Args: cobra.RangeArgs(1, 2),
 		Use:  "put <remote> [local]",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			s, err := sss.NewSSS(sss.WithURL(flags.URL))
+			s, err := sss.NewSSS(sss.WithURL(flags.URL), sss.WithBandwidthLimit(flags.LimitRate, 0))
 			if err != nil {
 				return err
 			}
 
-			opts := []sss.WriterOptions{}
+			opts := []sss.WriterOptions{
+				sss.WithConcurrency(flags.Concurrency),
+			}
 			if flags.SHA256 != "" {
 				opts = append(opts, sss.WithSHA256(flags.SHA256))
 			}
@@ -145,6 +151,8 @@ func NewCommand(ctx context.Context) *cobra.Command {
 	cmd.Flags().BoolVar(&flags.Continue, "continue", flags.Continue, "continue")
 	cmd.Flags().BoolVar(&flags.Commit, "commit", flags.Commit, "commit")
 	cmd.Flags().StringVar(&flags.SHA256, "sha256", flags.SHA256, "sha256")
+	cmd.Flags().IntVar(&flags.Concurrency, "concurrency", flags.Concurrency, "number of parts uploaded concurrently")
+	cmd.Flags().Int64Var(&flags.LimitRate, "limit-rate", flags.LimitRate, "maximum bytes per second uploaded, 0 for unlimited")
 
 	return cmd
 }
@@ -2,6 +2,7 @@ package rm
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/spf13/cobra"
 
@@ -11,6 +12,7 @@ import (
 type flagpole struct {
 	URL       string
 	Recursive bool
+	VersionID string
 }
 
 // NewCommand returns a new cobra.Command for rm
@@ -29,12 +31,16 @@ func NewCommand(ctx context.Context) *cobra.Command {
 			remote := args[0]
 
 			if flags.Recursive {
+				if flags.VersionID != "" {
+					return fmt.Errorf("--version-id can't be used with --recursive")
+				}
 				return s.DeleteAll(cmd.Context(), remote)
 			}
-			return s.Delete(cmd.Context(), remote)
+			return s.Delete(cmd.Context(), remote, sss.WithVersionID(flags.VersionID))
 		},
 	}
 	cmd.Flags().StringVar(&flags.URL, "url", flags.URL, "config url")
 	cmd.Flags().BoolVar(&flags.Recursive, "recursive", flags.Recursive, "recursive delete")
+	cmd.Flags().StringVar(&flags.VersionID, "version-id", flags.VersionID, "version of the object to delete for good")
 	return cmd
 }
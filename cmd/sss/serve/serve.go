@@ -8,6 +8,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/wzshiming/sss"
+	"github.com/wzshiming/sss/metrics"
 	"github.com/wzshiming/sss/serve"
 )
 
@@ -20,20 +21,34 @@ type flagpole struct {
 	AllowList   bool
 	AllowPut    bool
 	AllowDelete bool
+
+	Concurrency    int
+	LimitRate      int64
+	MetricsAddress string
 }
 
 // NewCommand returns a new cobra.Command for serve
 func NewCommand(ctx context.Context) *cobra.Command {
 	flags := &flagpole{
-		Address: ":8080",
-		Expires: 10 * time.Second,
+		Address:     ":8080",
+		Expires:     10 * time.Second,
+		Concurrency: 1,
 	}
 
 	cmd := &cobra.Command{
 		Args: cobra.NoArgs,
 		Use:  "serve",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			s, err := sss.NewSSS(sss.WithURL(flags.URL))
+			opts := []sss.Option{
+				sss.WithURL(flags.URL),
+				sss.WithBandwidthLimit(flags.LimitRate, flags.LimitRate),
+			}
+			var collector *metrics.Collector
+			if flags.MetricsAddress != "" {
+				collector = metrics.NewCollector()
+				opts = append(opts, sss.WithObserver(collector.Observe))
+			}
+			s, err := sss.NewSSS(opts...)
 			if err != nil {
 				return err
 			}
@@ -44,9 +59,24 @@ func NewCommand(ctx context.Context) *cobra.Command {
 				serve.WithAllowList(flags.AllowList),
 				serve.WithAllowPut(flags.AllowPut),
 				serve.WithAllowDelete(flags.AllowDelete),
+				serve.WithConcurrency(flags.Concurrency),
 			)
 
-			return http.ListenAndServe(flags.Address, h)
+			if collector == nil {
+				return http.ListenAndServe(flags.Address, h)
+			}
+
+			// The metrics have a listener of their own, away from the keys and the clients of the data.
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", collector)
+			errs := make(chan error, 2)
+			go func() {
+				errs <- http.ListenAndServe(flags.MetricsAddress, mux)
+			}()
+			go func() {
+				errs <- http.ListenAndServe(flags.Address, collector.Handler(h))
+			}()
+			return <-errs
 		},
 	}
 	cmd.Flags().StringVar(&flags.URL, "url", flags.URL, "config url")
@@ -56,5 +86,8 @@ func NewCommand(ctx context.Context) *cobra.Command {
 	cmd.Flags().BoolVar(&flags.AllowList, "allow-list", flags.AllowList, "allow list")
 	cmd.Flags().BoolVar(&flags.AllowPut, "allow-put", flags.AllowPut, "allow put")
 	cmd.Flags().BoolVar(&flags.AllowDelete, "allow-delete", flags.AllowDelete, "allow delete")
+	cmd.Flags().IntVar(&flags.Concurrency, "concurrency", flags.Concurrency, "number of parts uploaded concurrently for each put")
+	cmd.Flags().Int64Var(&flags.LimitRate, "limit-rate", flags.LimitRate, "maximum bytes per second uploaded to and downloaded from the storage each, 0 for unlimited")
+	cmd.Flags().StringVar(&flags.MetricsAddress, "metrics-address", flags.MetricsAddress, "address serving Prometheus metrics at /metrics, none if empty")
 	return cmd
 }
@@ -0,0 +1,48 @@
+package get
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wzshiming/sss"
+)
+
+type flagpole struct {
+	URL       string
+	VersionID string
+}
+
+// NewCommand returns a new cobra.Command for get
+func NewCommand(ctx context.Context) *cobra.Command {
+	flags := &flagpole{}
+
+	cmd := &cobra.Command{
+		Args: cobra.ExactArgs(1),
+		Use:  "get <remote>",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := sss.NewSSS(sss.WithURL(flags.URL))
+			if err != nil {
+				return err
+			}
+
+			remote := args[0]
+			tags, err := s.GetTags(cmd.Context(), remote, sss.WithVersionID(flags.VersionID))
+			if err != nil {
+				return err
+			}
+
+			for _, k := range slices.Sorted(maps.Keys(tags)) {
+				fmt.Printf("%s=%s\n", k, tags[k])
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&flags.URL, "url", flags.URL, "config url")
+	cmd.Flags().StringVar(&flags.VersionID, "version-id", flags.VersionID, "version of the object")
+
+	return cmd
+}
@@ -0,0 +1,37 @@
+package rm
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wzshiming/sss"
+)
+
+type flagpole struct {
+	URL       string
+	VersionID string
+}
+
+// NewCommand returns a new cobra.Command for rm
+func NewCommand(ctx context.Context) *cobra.Command {
+	flags := &flagpole{}
+
+	cmd := &cobra.Command{
+		Args: cobra.ExactArgs(1),
+		Use:  "rm <remote>",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := sss.NewSSS(sss.WithURL(flags.URL))
+			if err != nil {
+				return err
+			}
+
+			remote := args[0]
+			return s.DeleteTags(cmd.Context(), remote, sss.WithVersionID(flags.VersionID))
+		},
+	}
+	cmd.Flags().StringVar(&flags.URL, "url", flags.URL, "config url")
+	cmd.Flags().StringVar(&flags.VersionID, "version-id", flags.VersionID, "version of the object")
+
+	return cmd
+}
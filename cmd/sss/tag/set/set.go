@@ -0,0 +1,48 @@
+package set
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wzshiming/sss"
+)
+
+type flagpole struct {
+	URL       string
+	VersionID string
+}
+
+// NewCommand returns a new cobra.Command for set
+func NewCommand(ctx context.Context) *cobra.Command {
+	flags := &flagpole{}
+
+	cmd := &cobra.Command{
+		Args: cobra.MinimumNArgs(2),
+		Use:  "set <remote> <key=value>...",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := sss.NewSSS(sss.WithURL(flags.URL))
+			if err != nil {
+				return err
+			}
+
+			remote := args[0]
+			tags := map[string]string{}
+			for _, arg := range args[1:] {
+				k, v, ok := strings.Cut(arg, "=")
+				if !ok || k == "" {
+					return fmt.Errorf("invalid tag %q, expected key=value", arg)
+				}
+				tags[k] = v
+			}
+
+			return s.SetTags(cmd.Context(), remote, tags, sss.WithVersionID(flags.VersionID))
+		},
+	}
+	cmd.Flags().StringVar(&flags.URL, "url", flags.URL, "config url")
+	cmd.Flags().StringVar(&flags.VersionID, "version-id", flags.VersionID, "version of the object")
+
+	return cmd
+}
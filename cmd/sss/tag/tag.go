@@ -0,0 +1,26 @@
+package tag
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wzshiming/sss/cmd/sss/tag/get"
+	"github.com/wzshiming/sss/cmd/sss/tag/rm"
+	"github.com/wzshiming/sss/cmd/sss/tag/set"
+)
+
+// NewCommand returns a new cobra.Command for tag
+func NewCommand(ctx context.Context) *cobra.Command {
+	cmd := &cobra.Command{
+		Args: cobra.NoArgs,
+		Use:  "tag",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	cmd.AddCommand(get.NewCommand(ctx))
+	cmd.AddCommand(set.NewCommand(ctx))
+	cmd.AddCommand(rm.NewCommand(ctx))
+	return cmd
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"io"
 	"io/fs"
-	"log/slog"
 	"path"
 	"time"
 
@@ -43,11 +42,25 @@ func (s *file) Stat() (FileInfo, error) {
 func (s *file) init() {
 	if s.readSeekCloser == nil {
 		s.readSeekCloser = NewReadSeekCloser(func(start int64) (io.ReadCloser, error) {
-			return s.s.ReaderWithOffset(s.ctx, s.path, start)
+			return s.s.ReaderWithOffset(s.ctx, s.path, start, s.readOptions()...)
 		}, s.Size())
 	}
 }
 
+// readOptions pins every read, each seek opening a new one, to the object that was stated,
+// which the size comes from as well.
+func (s *file) readOptions() []sss.ObjectOptions {
+	s.initStat()
+	if s.stat == nil {
+		return nil
+	}
+	fie, ok := s.stat.Sys().(sss.FileInfoExpansion)
+	if !ok || fie.ETag == nil {
+		return nil
+	}
+	return []sss.ObjectOptions{sss.WithETag(*fie.ETag)}
+}
+
 func (s *file) Seek(offset int64, whence int) (int64, error) {
 	s.init()
 	return s.readSeekCloser.Seek(offset, whence)
@@ -79,7 +92,7 @@ func (s *file) initStat() {
 		var err error
 		s.stat, err = s.s.Stat(s.ctx, s.path)
 		if err != nil {
-			slog.Error("stat file", "path", s.path, "error", err)
+			s.s.Logger().Error("stat file", "path", s.path, "error", err)
 		}
 	}
 }
@@ -130,7 +143,7 @@ func (s *file) ReadDir(n int) ([]DirEntry, error) {
 		return true
 	})
 	if err != nil {
-		return nil, err
+		return nil, &fs.PathError{Op: "readdir", Path: s.path, Err: err}
 	}
 
 	return list, nil
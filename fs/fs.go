@@ -39,11 +39,25 @@ type fileSystem struct {
 }
 
 func (s *fileSystem) Open(name string) (File, error) {
-	path := path.Join(s.dir, name)
+	f, err := s.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return f, nil
+}
+
+// stat returns the file of name, erring with sss.ErrNotExist if there is none.
+func (s *fileSystem) stat(name string) (*file, error) {
+	p := path.Join(s.dir, name)
+	info, err := s.s.Stat(s.ctx, p)
+	if err != nil {
+		return nil, err
+	}
 	return &file{
 		ctx:  s.ctx,
 		s:    s.s,
-		path: path,
+		path: p,
+		stat: info,
 	}, nil
 }
 
@@ -57,7 +71,7 @@ func (s *fileSystem) ReadDir(name string) ([]DirEntry, error) {
 		return true
 	})
 	if err != nil {
-		return nil, err
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
 	}
 
 	des := make([]DirEntry, 0, len(list))
@@ -73,17 +87,19 @@ func (s *fileSystem) ReadDir(name string) ([]DirEntry, error) {
 
 func (s *fileSystem) ReadFile(name string) ([]byte, error) {
 	p := path.Join(s.dir, name)
-	return s.s.GetContent(s.ctx, p)
+	data, err := s.s.GetContent(s.ctx, p)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return data, nil
 }
 
 func (s *fileSystem) Stat(name string) (FileInfo, error) {
-	p := path.Join(s.dir, name)
-
-	return &file{
-		ctx:  s.ctx,
-		s:    s.s,
-		path: p,
-	}, nil
+	f, err := s.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return f, nil
 }
 
 func (s *fileSystem) Sub(dir string) (FS, error) {
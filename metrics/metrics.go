@@ -0,0 +1,221 @@
+// Package metrics exposes the calls of an SSS to the backend and the requests of a handler
+// as Prometheus metrics.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wzshiming/sss"
+)
+
+// buckets are the upper bounds in seconds of the latency histograms.
+var buckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Collector aggregates metrics and serves them in the Prometheus text format.
+// Observe is given to sss.WithObserver, and Handler wraps the handler whose requests are measured.
+//
+// Collector is not a prometheus.Collector, this package doesn't depend on the Prometheus client,
+// so it can't be registered with a prometheus.Registry. Serve it on its own path to be scraped
+// next to the metrics of such a registry.
+type Collector struct {
+	mut sync.Mutex
+
+	backendRequests *counterVec
+	backendBytes    *counterVec
+	backendRetries  *counterVec
+	backendDuration *histogramVec
+	serveRequests   *counterVec
+	serveDuration   *histogramVec
+}
+
+// NewCollector returns a Collector without samples.
+func NewCollector() *Collector {
+	return &Collector{
+		backendRequests: newCounterVec("sss_backend_requests_total", "Calls to the backend.", "operation", "status"),
+		backendBytes:    newCounterVec("sss_backend_bytes_total", "Bytes of the bodies sent to or received from the backend.", "operation"),
+		backendRetries:  newCounterVec("sss_backend_retries_total", "Requests to the backend sent again.", "operation"),
+		backendDuration: newHistogramVec("sss_backend_request_duration_seconds", "Time the backend took to answer.", "operation"),
+		serveRequests:   newCounterVec("sss_serve_requests_total", "Requests served.", "method", "code"),
+		serveDuration:   newHistogramVec("sss_serve_request_duration_seconds", "Time taken to serve requests, including writing the response.", "method"),
+	}
+}
+
+// Observe records an event of sss.WithObserver.
+func (c *Collector) Observe(e sss.Event) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.backendRequests.add(1, e.Operation, strconv.Itoa(e.Status))
+	c.backendBytes.add(float64(e.Bytes), e.Operation)
+	c.backendRetries.add(float64(e.Retries), e.Operation)
+	c.backendDuration.observe(e.Latency.Seconds(), e.Operation)
+}
+
+// Handler returns next recording the requests it serves.
+func (c *Collector) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: rw, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		elapsed := time.Since(start)
+
+		c.mut.Lock()
+		defer c.mut.Unlock()
+		c.serveRequests.add(1, r.Method, strconv.Itoa(sw.status))
+		c.serveDuration.observe(elapsed.Seconds(), r.Method)
+	})
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (c *Collector) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w := bufio.NewWriter(rw)
+	defer w.Flush()
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.backendRequests.write(w)
+	c.backendBytes.write(w)
+	c.backendRetries.write(w)
+	c.backendDuration.write(w)
+	c.serveRequests.write(w)
+	c.serveDuration.write(w)
+}
+
+// statusWriter remembers the status code written to a ResponseWriter.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+type counterVec struct {
+	name   string
+	help   string
+	labels []string
+	values map[string]float64
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: map[string]float64{},
+	}
+}
+
+func (v *counterVec) add(n float64, values ...string) {
+	v.values[formatLabels(v.labels, values)] += n
+}
+
+func (v *counterVec) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", v.name, v.help, v.name)
+	for _, labels := range sortedKeys(v.values) {
+		fmt.Fprintf(w, "%s{%s} %s\n", v.name, labels, formatFloat(v.values[labels]))
+	}
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+type histogramVec struct {
+	name       string
+	help       string
+	labels     []string
+	histograms map[string]*histogram
+}
+
+func newHistogramVec(name, help string, labels ...string) *histogramVec {
+	return &histogramVec{
+		name:       name,
+		help:       help,
+		labels:     labels,
+		histograms: map[string]*histogram{},
+	}
+}
+
+func (v *histogramVec) observe(value float64, values ...string) {
+	labels := formatLabels(v.labels, values)
+	h, ok := v.histograms[labels]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(buckets))}
+		v.histograms[labels] = h
+	}
+	for i, le := range buckets {
+		if value <= le {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+func (v *histogramVec) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", v.name, v.help, v.name)
+	for _, labels := range sortedKeys(v.histograms) {
+		h := v.histograms[labels]
+		for i, le := range buckets {
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", v.name, labels, formatFloat(le), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", v.name, labels, h.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", v.name, labels, formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", v.name, labels, h.count)
+	}
+}
+
+// formatLabels returns the labels of a sample, e.g. `operation="GetObject",status="200"`.
+func formatLabels(names, values []string) string {
+	var b strings.Builder
+	for i, name := range names {
+		if i != 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(values[i]))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
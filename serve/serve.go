@@ -1,8 +1,10 @@
 package serve
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	iofs "io/fs"
 	"net/http"
 	"path"
 	"strings"
@@ -45,6 +47,13 @@ func WithAllowDelete(b bool) Option {
 	}
 }
 
+// WithConcurrency sets the number of parts uploaded concurrently for each put.
+func WithConcurrency(n int) Option {
+	return func(s *Serve) {
+		s.concurrency = n
+	}
+}
+
 type Serve struct {
 	sss         *sss.SSS
 	expires     time.Duration
@@ -52,6 +61,7 @@ type Serve struct {
 	allowList   bool
 	allowPut    bool
 	allowDelete bool
+	concurrency int
 }
 
 func NewServe(opts ...Option) http.Handler {
@@ -71,7 +81,31 @@ func (s *Serve) forbidden(rw http.ResponseWriter) {
 	http.Error(rw, "Forbidden", http.StatusForbidden)
 }
 
+// fail replies with the status the error of SSS stands for.
+func (s *Serve) fail(rw http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, iofs.ErrNotExist):
+		status = http.StatusNotFound
+	case errors.Is(err, iofs.ErrPermission):
+		status = http.StatusForbidden
+	case errors.Is(err, sss.ErrPreconditionFailed):
+		status = http.StatusPreconditionFailed
+	}
+	http.Error(rw, err.Error(), status)
+}
+
 func (s *Serve) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	signed, err := s.sss.VerifyPresign(r)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusForbidden)
+		return
+	}
+	if signed {
+		s.presigned(rw, r)
+		return
+	}
+
 	switch r.Method {
 	default:
 		s.notAllowed(rw)
@@ -126,19 +160,41 @@ func (s *Serve) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// presigned serves a request carrying a valid signature from one of the SSS Sign methods,
+// which grants the method regardless of the allow options.
+func (s *Serve) presigned(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	default:
+		s.notAllowed(rw)
+	case http.MethodPut:
+		s.put(rw, r)
+	case http.MethodDelete:
+		s.delete(rw, r)
+	case http.MethodGet, http.MethodHead:
+		s.get(rw, r)
+	}
+}
+
 func (s *Serve) delete(rw http.ResponseWriter, r *http.Request) {
 	err := s.sss.Delete(r.Context(), r.URL.Path)
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		s.fail(rw, err)
 		return
 	}
 	rw.WriteHeader(http.StatusOK)
 }
 
 func (s *Serve) put(rw http.ResponseWriter, r *http.Request) {
-	w, err := s.sss.Writer(r.Context(), r.URL.Path)
+	opts := []sss.WriterOptions{sss.WithConcurrency(s.concurrency)}
+	if etag := r.Header.Get("If-Match"); etag != "" {
+		opts = append(opts, sss.WithIfMatch(etag))
+	}
+	if etag := r.Header.Get("If-None-Match"); etag != "" {
+		opts = append(opts, sss.WithIfNoneMatch(etag))
+	}
+	w, err := s.sss.Writer(r.Context(), r.URL.Path, opts...)
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		s.fail(rw, err)
 		return
 	}
 	defer w.Close()
@@ -156,7 +212,7 @@ func (s *Serve) put(rw http.ResponseWriter, r *http.Request) {
 	err = w.Commit(r.Context())
 	if err != nil {
 		w.Cancel(r.Context())
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		s.fail(rw, err)
 		return
 	}
 	rw.WriteHeader(http.StatusCreated)
@@ -165,18 +221,25 @@ func (s *Serve) put(rw http.ResponseWriter, r *http.Request) {
 func (s *Serve) get(rw http.ResponseWriter, r *http.Request) {
 	info, err := s.sss.StatHead(r.Context(), r.URL.Path)
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusNotFound)
+		s.fail(rw, err)
 		return
 	}
+	var opts []sss.ObjectOptions
+	if etag := info.Sys().(sss.FileInfoExpansion).ETag; etag != nil {
+		// ServeContent answers If-Range and If-None-Match with it,
+		// and every range it reads is pinned to it.
+		rw.Header().Set("ETag", *etag)
+		opts = append(opts, sss.WithETag(*etag))
+	}
 	http.ServeContent(rw, r, r.URL.Path, info.ModTime(), fs.NewReadSeekCloser(func(start int64) (io.ReadCloser, error) {
-		return s.sss.ReaderWithOffset(r.Context(), r.URL.Path, start)
+		return s.sss.ReaderWithOffset(r.Context(), r.URL.Path, start, opts...)
 	}, info.Size()))
 }
 
 func (s *Serve) list(rw http.ResponseWriter, r *http.Request) {
 	_, err := s.sss.StatHeadList(r.Context(), r.URL.Path)
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusNotFound)
+		s.fail(rw, err)
 		return
 	}
 
@@ -2,7 +2,10 @@ package sss
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -11,11 +14,6 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/endpoints"
-	"github.com/aws/aws-sdk-go/aws/request"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 )
 
@@ -25,6 +23,27 @@ const (
 	// listMax is the largest amount of objects you can request from S3 in a list call
 	listMax = 1000
 
+	// maxCopySize is the largest object a single CopyObject request accepts
+	maxCopySize = 5 * 1024 * 1024 * 1024
+
+	// defaultCopyPartSize is the preferred size of each part of a multipart copy
+	defaultCopyPartSize = 512 * 1024 * 1024
+
+	// maxParts is the largest number of parts of a multipart upload
+	maxParts = 10000
+
+	// minPartSize is the smallest size of each part but the last of a multipart upload
+	minPartSize = 5 * 1024 * 1024
+
+	// copyConcurrency is the number of parts copied at the same time by a multipart copy
+	copyConcurrency = 8
+
+	// sseCustomerAlgorithm is the only algorithm S3 supports for customer-provided keys
+	sseCustomerAlgorithm = "AES256"
+
+	// sseCustomerKeySize is the size of a customer-provided key
+	sseCustomerKeySize = 32
+
 	// noStorageClass defines the value to be used if storage class is not supported by the S3 endpoint
 	noStorageClass = "NONE"
 )
@@ -42,6 +61,10 @@ type sssOption struct {
 	ForcePathStyle      bool
 	Encrypt             bool
 	KeyID               string
+	SSECustomerKey      []byte
+	ClientKeyID         string
+	ClientKeys          map[string][]byte
+	ClientSegmentSize   int
 	Secure              bool
 	ChunkSize           int
 	RootDirectory       string
@@ -52,6 +75,20 @@ type sssOption struct {
 	UseDualStack        bool
 	Accelerate          bool
 	LogLevel            aws.LogLevelType
+	Logger              *slog.Logger
+	LoggerLevel         slog.Leveler
+	UnknownLogLevel     string
+	RetryPolicy         *RetryPolicy
+	UploadLimit         int64
+	DownloadLimit       int64
+	Observers           []func(Event)
+	CacheDir            string
+	CacheSize           int64
+	MetadataCacheTTL    time.Duration
+	Backend             Backend
+	BackendMiddlewares  []BackendMiddleware
+
+	MultipartCopyThreshold int64
 }
 
 type Option func(*sssOption) error
@@ -134,6 +171,19 @@ func WithKMSKeyID(id string) Option {
 	}
 }
 
+// WithSSECustomerKey encrypts the objects on the server side with the given 256-bit key,
+// which S3 doesn't keep and which has to be given again to read them.
+// It takes precedence over WithEncryption.
+func WithSSECustomerKey(key []byte) Option {
+	return func(p *sssOption) error {
+		if len(key) != sseCustomerKeySize {
+			return fmt.Errorf("invalid SSE customer key: must be %d bytes, got %d", sseCustomerKeySize, len(key))
+		}
+		p.SSECustomerKey = key
+		return nil
+	}
+}
+
 func WithSecure(enable bool) Option {
 	return func(p *sssOption) error {
 		p.Secure = enable
@@ -204,6 +254,15 @@ func WithLogLevel(level aws.LogLevelType) Option {
 	}
 }
 
+// WithMultipartCopyThreshold sets the size above which Copy uses a multipart copy,
+// it defaults to the 5 GiB limit of CopyObject.
+func WithMultipartCopyThreshold(size int64) Option {
+	return func(p *sssOption) error {
+		p.MultipartCopyThreshold = size
+		return nil
+	}
+}
+
 func WithURL(uri string) Option {
 	return func(p *sssOption) error {
 		u, err := url.Parse(uri)
@@ -228,6 +287,8 @@ func WithURL(uri string) Option {
 			region = part[1]
 		}
 
+		factory, hasFactory := getBackendFactory(u.Scheme)
+
 		signEndpoint := query.Get("signendpoint")
 
 		signendpointmethods := query.Get("signendpointmethods")
@@ -240,7 +301,7 @@ func WithURL(uri string) Option {
 
 		forcePathStyleBool, _ := strconv.ParseBool(query.Get("forcepathstyle"))
 
-		if regionEndpoint == "" {
+		if regionEndpoint == "" && !hasFactory {
 			if region == "" {
 				return fmt.Errorf("no region parameter provided")
 			}
@@ -252,13 +313,53 @@ func WithURL(uri string) Option {
 
 		keyID := query.Get("keyid")
 
+		var sseCustomerKey []byte
+		if ssec := query.Get("ssec"); ssec != "" {
+			sseCustomerKey, err = base64.StdEncoding.DecodeString(ssec)
+			if err != nil || len(sseCustomerKey) != sseCustomerKeySize {
+				return fmt.Errorf("invalid ssec: must be a base64 encoded %d bytes key", sseCustomerKeySize)
+			}
+		}
+
+		// Each cse is a key id and its base64 encoded key, the first one encrypting what is written.
+		var clientKeyID string
+		var clientKeys map[string][]byte
+		for _, cse := range query["cse"] {
+			id, encoded, _ := strings.Cut(cse, ":")
+			key, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil || id == "" || len(key) != cseKeySize {
+				return fmt.Errorf("invalid cse: must be a key id and a base64 encoded %d bytes key separated by a colon", cseKeySize)
+			}
+			if clientKeys == nil {
+				clientKeyID = id
+				clientKeys = map[string][]byte{}
+			}
+			clientKeys[id] = key
+		}
+
+		clientSegmentSize, _ := strconv.Atoi(query.Get("csesegmentsize"))
+
+		metadataCacheTTL, _ := time.ParseDuration(query.Get("metadatacache"))
+
+		cacheDir := query.Get("cache")
+		cacheSize, err := strconv.ParseInt(query.Get("cachesize"), 10, 64)
+		if err != nil || cacheSize <= 0 {
+			cacheSize = defaultCacheSize
+		}
+
+		maxAttempts, _ := strconv.Atoi(query.Get("maxattempts"))
+
 		chunkSize := defaultChunkSize
 		chunkSizeInt, err := strconv.Atoi(query.Get("chunksize"))
 		if err == nil && chunkSizeInt > 0 {
 			chunkSize = chunkSizeInt
 		}
 
+		// The path of a registered backend URL belongs to the backend.
 		rootDirectory := u.Path
+		if hasFactory {
+			rootDirectory = ""
+		}
 		rootDirectoryStr := query.Get("rootdirectory")
 		if rootDirectoryStr != "" {
 			rootDirectory = rootDirectoryStr
@@ -284,10 +385,17 @@ func WithURL(uri string) Option {
 
 		accelerateBool, _ := strconv.ParseBool(query.Get("accelerate"))
 
-		var logLevel = aws.LogOff
-		switch query.Get("loglevel") {
-		case "debug":
-			logLevel = aws.LogDebug
+		loggerLevel, ok := parseLogLevel(query.Get("loglevel"))
+		if !ok {
+			p.UnknownLogLevel = query.Get("loglevel")
+		}
+
+		if hasFactory {
+			backend, err := factory(u)
+			if err != nil {
+				return err
+			}
+			p.Backend = backend
 		}
 
 		p.DriverName = u.Scheme
@@ -300,6 +408,24 @@ func WithURL(uri string) Option {
 		p.ForcePathStyle = forcePathStyleBool
 		p.Encrypt = encryptBool
 		p.KeyID = keyID
+		p.SSECustomerKey = sseCustomerKey
+		if clientKeys != nil {
+			p.ClientKeyID = clientKeyID
+			p.ClientKeys = clientKeys
+		}
+		if metadataCacheTTL > 0 {
+			p.MetadataCacheTTL = metadataCacheTTL
+		}
+		if cacheDir != "" {
+			p.CacheDir = cacheDir
+			p.CacheSize = cacheSize
+		}
+		if clientSegmentSize > 0 {
+			p.ClientSegmentSize = clientSegmentSize
+		}
+		if maxAttempts > 0 {
+			p.RetryPolicy = &RetryPolicy{MaxAttempts: maxAttempts}
+		}
 		p.Secure = secureBool
 		p.ChunkSize = chunkSize
 		p.RootDirectory = rootDirectory
@@ -309,7 +435,9 @@ func WithURL(uri string) Option {
 		p.SessionToken = sessionToken
 		p.UseDualStack = useDualStackBool
 		p.Accelerate = accelerateBool
-		p.LogLevel = logLevel
+		if loggerLevel != nil {
+			p.LoggerLevel = loggerLevel
+		}
 		p.SignEndpointMethods = signEndpointMethodsStrings
 		return nil
 	}
@@ -317,17 +445,24 @@ func WithURL(uri string) Option {
 
 type SSS struct {
 	s3            *s3.S3
-	signS3        *s3.S3
-	signMethods   map[string]struct{}
+	backend       Backend
+	origin        Backend
+	accessKey     string
+	verifier      PresignVerifier
 	Name          string
 	bucket        string
 	chunkSize     int
 	encrypt       bool
 	keyID         string
+	sseKey        []byte
+	cse           *clientEncryption
+	logger        *slog.Logger
 	rootDirectory string
 	storageClass  string
 	objectACL     string
 	pool          *sync.Pool
+
+	multipartCopyThreshold int64
 }
 
 func NewSSS(opts ...Option) (*SSS, error) {
@@ -335,6 +470,8 @@ func NewSSS(opts ...Option) (*SSS, error) {
 		StorageClass: s3.StorageClassStandard,
 		ObjectACL:    s3.ObjectCannedACLPrivate,
 		ChunkSize:    defaultChunkSize,
+
+		MultipartCopyThreshold: maxCopySize,
 	}
 
 	for _, opt := range opts {
@@ -344,102 +481,129 @@ func NewSSS(opts ...Option) (*SSS, error) {
 		}
 	}
 
-	awsConfig := aws.NewConfig()
-	if params.AccessKey != "" && params.SecretKey != "" {
-		creds := credentials.NewStaticCredentials(
-			params.AccessKey,
-			params.SecretKey,
-			params.SessionToken,
-		)
-		awsConfig.WithCredentials(creds)
-	} else {
-		awsConfig.WithCredentials(credentials.AnonymousCredentials)
+	if params.MultipartCopyThreshold <= 0 {
+		params.MultipartCopyThreshold = maxCopySize
 	}
-
-	if params.RegionEndpoint != "" {
-		awsConfig.WithEndpoint(params.RegionEndpoint)
+	if params.RetryPolicy != nil {
+		params.RetryPolicy = params.RetryPolicy.withDefaults()
+	}
+	params.Logger = newLogger(params.Logger, params.LoggerLevel)
+	if params.UnknownLogLevel != "" {
+		params.Logger.Warn("ignore unknown loglevel, want error, warn, info, debug or trace", "loglevel", params.UnknownLogLevel)
 	}
 
-	awsConfig.WithRegion(params.Region)
-	awsConfig.WithS3ForcePathStyle(params.ForcePathStyle)
-	awsConfig.WithS3UseAccelerate(params.Accelerate)
-	awsConfig.WithDisableSSL(!params.Secure)
-	awsConfig.WithHTTPClient(params.HTTPClient)
-	awsConfig.WithLogLevel(params.LogLevel)
-
-	if params.UseDualStack {
-		awsConfig.UseDualStackEndpoint = endpoints.DualStackEndpointStateEnabled
+	var s3Client *s3.S3
+	backend := params.Backend
+	verifier, _ := backend.(PresignVerifier)
+	if backend == nil {
+		b, err := newS3Backend(params)
+		if err != nil {
+			return nil, err
+		}
+		s3Client = b.S3
+		backend = b
+	}
+	origin := backend
+	if s3Client == nil {
+		backend = limitBackend(backend, newLimiter(params.UploadLimit), newLimiter(params.DownloadLimit))
+		if params.RetryPolicy != nil {
+			backend = retryBackend(backend, params.RetryPolicy)
+		}
+	}
+	if len(params.Observers) != 0 {
+		backend = observeBackend(backend, params.Observers)
+	}
+	var cached *cachedBackend
+	if params.CacheDir != "" {
+		cache, err := openDiskCache(params.CacheDir, params.CacheSize)
+		if err != nil {
+			return nil, err
+		}
+		cached = cacheBackend(backend, cache)
+		backend = cached
+	}
+	if params.MetadataCacheTTL > 0 {
+		metadata := metadataCacheBackend(backend, params.MetadataCacheTTL)
+		if cached != nil {
+			// Reuse the ETags known to be fresh rather than sending a HEAD with every read.
+			cached.metadata = metadata
+		}
+		backend = metadata
 	}
 
-	sess, err := session.NewSession(awsConfig)
+	cse, err := newClientEncryption(params.ClientKeyID, params.ClientKeys, params.ClientSegmentSize)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create new session with aws config: %v", err)
+		return nil, err
 	}
 
-	if params.UserAgent != "" {
-		sess.Handlers.Build.PushBack(request.MakeAddToUserAgentFreeFormHandler(params.UserAgent))
+	for i := len(params.BackendMiddlewares) - 1; i >= 0; i-- {
+		backend = params.BackendMiddlewares[i](backend)
 	}
 
 	s := &SSS{
-		s3:            s3.New(sess),
+		s3:            s3Client,
+		backend:       backend,
+		origin:        origin,
+		accessKey:     params.AccessKey,
+		verifier:      verifier,
 		Name:          params.DriverName,
 		bucket:        params.Bucket,
 		chunkSize:     params.ChunkSize,
 		encrypt:       params.Encrypt,
 		keyID:         params.KeyID,
+		sseKey:        params.SSECustomerKey,
+		cse:           cse,
+		logger:        params.Logger,
 		rootDirectory: params.RootDirectory,
 		storageClass:  params.StorageClass,
 		objectACL:     params.ObjectACL,
 		pool: &sync.Pool{
 			New: func() any { return &bytes.Buffer{} },
 		},
-	}
-
-	if params.SignEndpoint != "" {
-		sess.Config.Endpoint = &params.SignEndpoint
-		sess.Config.S3ForcePathStyle = aws.Bool(true)
-		s.signS3 = s3.New(sess)
-		if len(params.SignEndpointMethods) != 0 {
-			s.signMethods = make(map[string]struct{})
-			for _, method := range params.SignEndpointMethods {
-				s.signMethods[strings.ToUpper(method)] = struct{}{}
-			}
-		} else {
-			s.signMethods = nil
-		}
+		multipartCopyThreshold: params.MultipartCopyThreshold,
 	}
 	return s, nil
 }
 
-func (s *SSS) presign(expires time.Duration, fun func(s3 *s3.S3) *request.Request) (string, error) {
-	if s.signS3 == nil {
-		return fun(s.s3).Presign(expires)
+func (s *SSS) presign(expires time.Duration, input any) (string, error) {
+	u, err := s.backend.Presign(input, expires)
+	if err != nil {
+		return "", err
 	}
-	if s.signMethods != nil {
-		req := fun(s.s3)
-		if _, ok := s.signMethods[req.HTTPRequest.Method]; !ok {
-			return req.Presign(expires)
+	if s.verifier != nil && s.s3Path("") != "" {
+		// The URL is served by a serve.Serve of the same root directory, which takes the
+		// path below it, while VerifyPresign checks the signature of the full key.
+		p, err := url.Parse(u)
+		if err != nil {
+			return "", err
 		}
+		p.Path = strings.TrimPrefix(p.Path, "/"+s.s3Path(""))
+		p.RawPath = ""
+		u = p.String()
 	}
-	req := fun(s.signS3)
-	req.HTTPRequest.URL.Path = strings.TrimPrefix(req.HTTPRequest.URL.Path, "/{Bucket}")
-	return req.Presign(expires)
-}
-
-func (s *SSS) s3Path(path string) string {
-	return strings.TrimLeft(strings.TrimRight(s.rootDirectory, "/")+path, "/")
+	if s.logger.Enabled(context.Background(), slog.LevelDebug) {
+		operation := strings.TrimSuffix(strings.TrimPrefix(fmt.Sprintf("%T", input), "*s3."), "Input")
+		s.logger.Debug("presign", "operation", operation, "expires", expires, "url", redact(u))
+	}
+	return u, nil
 }
 
-func parseError(path string, err error) error {
-	if s3Err, ok := err.(awserr.Error); ok && s3Err.Code() == "NoSuchKey" {
-		return fmt.Errorf("path not found: %s", path)
+// VerifyPresign checks a request made with a URL from one of the Sign methods,
+// for backends whose pre-signed URLs point back at serve.Serve.
+// It reports false without error if the request is not pre-signed.
+func (s *SSS) VerifyPresign(r *http.Request) (bool, error) {
+	if s.verifier == nil {
+		return false, nil
 	}
+	return s.verifier.VerifyPresign(r.Method, s.s3Path(r.URL.Path), r.URL.Query())
+}
 
-	return err
+func (s *SSS) s3Path(path string) string {
+	return strings.TrimLeft(strings.TrimRight(s.rootDirectory, "/")+path, "/")
 }
 
 func (s *SSS) getEncryptionMode() *string {
-	if !s.encrypt {
+	if !s.encrypt || s.sseKey != nil {
 		return nil
 	}
 	if s.keyID == "" {
@@ -449,12 +613,27 @@ func (s *SSS) getEncryptionMode() *string {
 }
 
 func (s *SSS) getSSEKMSKeyID() *string {
-	if s.keyID != "" {
+	if s.keyID != "" && s.sseKey == nil {
 		return aws.String(s.keyID)
 	}
 	return nil
 }
 
+func (s *SSS) getSSECustomerAlgorithm() *string {
+	if s.sseKey == nil {
+		return nil
+	}
+	return aws.String(sseCustomerAlgorithm)
+}
+
+// getSSECustomerKey returns the raw key, the SDK encodes it and adds its MD5.
+func (s *SSS) getSSECustomerKey() *string {
+	if s.sseKey == nil {
+		return nil
+	}
+	return aws.String(string(s.sseKey))
+}
+
 func (s *SSS) getContentType() *string {
 	return aws.String("application/octet-stream")
 }
@@ -478,10 +657,16 @@ func (s *SSS) ChunkSize() int {
 	return s.chunkSize
 }
 
+// S3 returns the underlying aws-sdk-go client, or nil if SSS is not using the S3 backend.
 func (s *SSS) S3() *s3.S3 {
 	return s.s3
 }
 
+// Backend returns the backend SSS talks to, including any middlewares.
+func (s *SSS) Backend() Backend {
+	return s.backend
+}
+
 type s3completedParts []*s3.CompletedPart
 
 func (a s3completedParts) Len() int           { return len(a) }
@@ -0,0 +1,91 @@
+package sss
+
+import (
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Backend is the storage SSS talks to.
+// Its methods mirror the subset of the S3 API used by SSS, so the aws-sdk-go
+// client, in-process implementations and middleware all share one shape.
+type Backend interface {
+	PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error)
+	GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error)
+	HeadObjectWithContext(ctx aws.Context, input *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error)
+	ListObjectsV2WithContext(ctx aws.Context, input *s3.ListObjectsV2Input, opts ...request.Option) (*s3.ListObjectsV2Output, error)
+	ListObjectsV2PagesWithContext(ctx aws.Context, input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool, opts ...request.Option) error
+	DeleteObjectWithContext(ctx aws.Context, input *s3.DeleteObjectInput, opts ...request.Option) (*s3.DeleteObjectOutput, error)
+	DeleteObjectsWithContext(ctx aws.Context, input *s3.DeleteObjectsInput, opts ...request.Option) (*s3.DeleteObjectsOutput, error)
+	CopyObjectWithContext(ctx aws.Context, input *s3.CopyObjectInput, opts ...request.Option) (*s3.CopyObjectOutput, error)
+	CreateMultipartUploadWithContext(ctx aws.Context, input *s3.CreateMultipartUploadInput, opts ...request.Option) (*s3.CreateMultipartUploadOutput, error)
+	UploadPartWithContext(ctx aws.Context, input *s3.UploadPartInput, opts ...request.Option) (*s3.UploadPartOutput, error)
+	UploadPartCopyWithContext(ctx aws.Context, input *s3.UploadPartCopyInput, opts ...request.Option) (*s3.UploadPartCopyOutput, error)
+	CompleteMultipartUploadWithContext(ctx aws.Context, input *s3.CompleteMultipartUploadInput, opts ...request.Option) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUploadWithContext(ctx aws.Context, input *s3.AbortMultipartUploadInput, opts ...request.Option) (*s3.AbortMultipartUploadOutput, error)
+	ListPartsPagesWithContext(ctx aws.Context, input *s3.ListPartsInput, fn func(*s3.ListPartsOutput, bool) bool, opts ...request.Option) error
+	ListMultipartUploadsPagesWithContext(ctx aws.Context, input *s3.ListMultipartUploadsInput, fn func(*s3.ListMultipartUploadsOutput, bool) bool, opts ...request.Option) error
+	GetObjectTaggingWithContext(ctx aws.Context, input *s3.GetObjectTaggingInput, opts ...request.Option) (*s3.GetObjectTaggingOutput, error)
+	PutObjectTaggingWithContext(ctx aws.Context, input *s3.PutObjectTaggingInput, opts ...request.Option) (*s3.PutObjectTaggingOutput, error)
+	DeleteObjectTaggingWithContext(ctx aws.Context, input *s3.DeleteObjectTaggingInput, opts ...request.Option) (*s3.DeleteObjectTaggingOutput, error)
+	ListObjectVersionsPagesWithContext(ctx aws.Context, input *s3.ListObjectVersionsInput, fn func(*s3.ListObjectVersionsOutput, bool) bool, opts ...request.Option) error
+
+	// Presign returns a URL that grants the request described by input until it expires.
+	// input is one of the *s3.XxxInput types SSS signs, e.g. *s3.GetObjectInput.
+	Presign(input any, expires time.Duration) (string, error)
+}
+
+// PresignVerifier is implemented by backends whose pre-signed URLs
+// are served by serve.Serve rather than by the storage itself.
+type PresignVerifier interface {
+	// VerifyPresign reports whether the query carries a signature and, if so, whether
+	// it grants method on key.
+	VerifyPresign(method, key string, query url.Values) (bool, error)
+}
+
+// BackendFactory creates a Backend from the URL given to WithURL.
+type BackendFactory func(u *url.URL) (Backend, error)
+
+// BackendMiddleware wraps a Backend, e.g. to add logging or caching.
+type BackendMiddleware func(Backend) Backend
+
+var (
+	backendsMut sync.RWMutex
+	backends    = map[string]BackendFactory{}
+)
+
+// RegisterBackend makes a backend available to WithURL under the given scheme.
+// Schemes without a registered backend are served by the S3 backend.
+func RegisterBackend(scheme string, factory BackendFactory) {
+	backendsMut.Lock()
+	defer backendsMut.Unlock()
+	backends[scheme] = factory
+}
+
+func getBackendFactory(scheme string) (BackendFactory, bool) {
+	backendsMut.RLock()
+	defer backendsMut.RUnlock()
+	factory, ok := backends[scheme]
+	return factory, ok
+}
+
+// WithBackend uses the given backend instead of the S3 backend built from the other options.
+func WithBackend(backend Backend) Option {
+	return func(p *sssOption) error {
+		p.Backend = backend
+		return nil
+	}
+}
+
+// WithBackendMiddleware wraps the backend with the given middlewares,
+// the first middleware is the outermost.
+func WithBackendMiddleware(middlewares ...BackendMiddleware) Option {
+	return func(p *sssOption) error {
+		p.BackendMiddlewares = append(p.BackendMiddlewares, middlewares...)
+		return nil
+	}
+}
@@ -0,0 +1,1211 @@
+package sss
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func init() {
+	RegisterBackend("file", func(u *url.URL) (Backend, error) {
+		query := u.Query()
+		return NewFileBackend(fileURLPath(u), query.Get("signendpoint"), query.Get("signsecret"))
+	})
+}
+
+const (
+	// fileReserved is the directory inside the root of a file backend used for its own bookkeeping,
+	// keys below it are rejected.
+	fileReserved = ".sss"
+
+	presignExpiresQuery   = "X-Sss-Expires"
+	presignSignatureQuery = "X-Sss-Signature"
+)
+
+var (
+	_ Backend         = (*fileBackend)(nil)
+	_ PresignVerifier = (*fileBackend)(nil)
+)
+
+// fileMeta is the sidecar kept next to each object for what the filesystem cannot store.
+type fileMeta struct {
+	ETag               string            `json:"etag"`
+	ContentType        string            `json:"contentType,omitempty"`
+	ContentDisposition string            `json:"contentDisposition,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+	Tags               map[string]string `json:"tags,omitempty"`
+	SSECustomerKeyMD5  string            `json:"sseCustomerKeyMD5,omitempty"`
+}
+
+// fileUpload describes an in-progress multipart upload staged on disk.
+type fileUpload struct {
+	Key                string            `json:"key"`
+	Initiated          time.Time         `json:"initiated"`
+	ContentType        string            `json:"contentType,omitempty"`
+	ContentDisposition string            `json:"contentDisposition,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+	Tags               map[string]string `json:"tags,omitempty"`
+	SSECustomerKeyMD5  string            `json:"sseCustomerKeyMD5,omitempty"`
+}
+
+// fileBackend is a Backend mapping object keys onto a directory tree.
+type fileBackend struct {
+	dir          string
+	signEndpoint string
+	signSecret   []byte
+
+	// mut serialises creating and pruning directories.
+	mut sync.Mutex
+	// writeMut serialises checking the conditions of a write with placing its object.
+	writeMut sync.Mutex
+}
+
+// NewFileBackend returns a Backend that stores objects as files below dir,
+// it is what the file:// scheme of WithURL uses.
+// In-progress multipart uploads are staged below dir/.sss.
+// Pre-signed URLs point at signEndpoint, which is expected to be a serve.Serve
+// using VerifyPresign, and are signed with signSecret.
+func NewFileBackend(dir, signEndpoint, signSecret string) (Backend, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("file backend: no directory provided")
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(filepath.Join(dir, fileReserved, "tmp"), 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &fileBackend{
+		dir:          dir,
+		signEndpoint: strings.TrimSuffix(signEndpoint, "/"),
+		signSecret:   []byte(signSecret),
+	}, nil
+}
+
+// fileURLPath returns the local directory named by a file:// URL.
+func fileURLPath(u *url.URL) string {
+	p := u.Path
+	if runtime.GOOS == "windows" && len(p) > 2 && p[0] == '/' && p[2] == ':' {
+		p = p[1:]
+	}
+	return filepath.FromSlash(p)
+}
+
+func (b *fileBackend) objectPath(key string) (string, error) {
+	if key == "" || strings.HasSuffix(key, "/") {
+		return "", errInvalidArgument("file backend: unsupported key: " + key)
+	}
+	if path.Clean("/"+key) != "/"+key {
+		return "", errInvalidArgument("file backend: unsupported key: " + key)
+	}
+	if key == fileReserved || strings.HasPrefix(key, fileReserved+"/") {
+		return "", errInvalidArgument("file backend: reserved key: " + key)
+	}
+	return filepath.Join(b.dir, filepath.FromSlash(key)), nil
+}
+
+func (b *fileBackend) metaPath(key string) string {
+	return filepath.Join(b.dir, fileReserved, "meta", filepath.FromSlash(key))
+}
+
+func (b *fileBackend) uploadPath(uploadID string) (string, error) {
+	if uploadID == "" || strings.ContainsAny(uploadID, `/\.`) {
+		return "", errNoSuchUpload(uploadID)
+	}
+	return filepath.Join(b.dir, fileReserved, "uploads", uploadID), nil
+}
+
+func partFile(partNumber int64) string {
+	return fmt.Sprintf("part-%05d", partNumber)
+}
+
+// writeTemp writes r to a temporary file, it returns the file name and the MD5 of the content.
+func (b *fileBackend) writeTemp(r io.Reader) (string, []byte, error) {
+	f, err := os.CreateTemp(filepath.Join(b.dir, fileReserved, "tmp"), "tmp-")
+	if err != nil {
+		return "", nil, err
+	}
+	h := md5.New()
+	_, err = io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", nil, err
+	}
+	err = f.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		return "", nil, err
+	}
+	return f.Name(), h.Sum(nil), nil
+}
+
+// place moves a temporary file to its final name, creating parent directories.
+func (b *fileBackend) place(tmp, name string) error {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	err := os.MkdirAll(filepath.Dir(name), 0755)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// remove deletes a file and prunes the directories it leaves empty.
+func (b *fileBackend) remove(name string, stop string) error {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	err := os.Remove(name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for dir := filepath.Dir(name); dir != stop && strings.HasPrefix(dir, stop); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+func writeJSON(name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, data, 0644)
+}
+
+func readJSON(name string, v any) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (b *fileBackend) putObject(key string, r io.Reader, meta fileMeta, conditions writeConditions) (string, error) {
+	name, err := b.objectPath(key)
+	if err != nil {
+		return "", err
+	}
+	tmp, sum, err := b.writeTemp(r)
+	if err != nil {
+		return "", err
+	}
+	if meta.ETag == "" {
+		meta.ETag = `"` + hex.EncodeToString(sum) + `"`
+	}
+
+	b.writeMut.Lock()
+	defer b.writeMut.Unlock()
+	err = b.checkWrite(key, conditions)
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	err = b.writeMeta(key, meta)
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	err = b.place(tmp, name)
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return meta.ETag, nil
+}
+
+// checkWrite checks conditions against the current object of key.
+func (b *fileBackend) checkWrite(key string, conditions writeConditions) error {
+	if conditions == (writeConditions{}) {
+		return nil
+	}
+	_, info, err := b.stat(key)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return conditions.check(key, false, "")
+		}
+		return err
+	}
+	return conditions.check(key, true, b.readMeta(key, info).ETag)
+}
+
+func (b *fileBackend) writeMeta(key string, meta fileMeta) error {
+	name := b.metaPath(key)
+	tmp, err := os.CreateTemp(filepath.Join(b.dir, fileReserved, "tmp"), "meta-")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	err = writeJSON(tmp.Name(), meta)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return b.place(tmp.Name(), name)
+}
+
+// readMeta returns the sidecar of key, objects placed in the directory by other means get a synthesized ETag.
+func (b *fileBackend) readMeta(key string, info fs.FileInfo) fileMeta {
+	var meta fileMeta
+	err := readJSON(b.metaPath(key), &meta)
+	if err != nil || meta.ETag == "" {
+		meta.ETag = `"` + strconv.FormatInt(info.ModTime().UnixNano(), 16) + "-" + strconv.FormatInt(info.Size(), 16) + `"`
+	}
+	return meta
+}
+
+func (b *fileBackend) stat(key string) (string, fs.FileInfo, error) {
+	name, err := b.objectPath(key)
+	if err != nil {
+		return "", nil, err
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return "", nil, err
+	}
+	if info.IsDir() {
+		return "", nil, fs.ErrNotExist
+	}
+	return name, info, nil
+}
+
+func (b *fileBackend) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	tags, err := parseTagging(input.Tagging)
+	if err != nil {
+		return nil, err
+	}
+	sseKeyMD5, err := sseCustomerKeyMD5(input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.Reader = strings.NewReader("")
+	if input.Body != nil {
+		body = input.Body
+	}
+	etag, err := b.putObject(aws.StringValue(input.Key), body, fileMeta{
+		ContentType:        aws.StringValue(input.ContentType),
+		ContentDisposition: aws.StringValue(input.ContentDisposition),
+		Metadata:           aws.StringValueMap(input.Metadata),
+		Tags:               tags,
+		SSECustomerKeyMD5:  sseKeyMD5,
+	}, writeConditionsOf(opts))
+	if err != nil {
+		return nil, err
+	}
+	out := &s3.PutObjectOutput{
+		ETag: aws.String(etag),
+	}
+	if sseKeyMD5 != "" {
+		out.SSECustomerAlgorithm = aws.String(sseCustomerAlgorithm)
+		out.SSECustomerKeyMD5 = aws.String(sseKeyMD5)
+	}
+	return out, nil
+}
+
+func (b *fileBackend) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	key := aws.StringValue(input.Key)
+	err := checkNullVersion(key, aws.StringValue(input.VersionId))
+	if err != nil {
+		return nil, err
+	}
+	sseKeyMD5, err := sseCustomerKeyMD5(input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+	name, info, err := b.stat(key)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, errNoSuchKey(key)
+		}
+		return nil, err
+	}
+	meta := b.readMeta(key, info)
+	err = checkSSECustomerKey(meta.SSECustomerKeyMD5, sseKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+	err = writeConditions{IfMatch: aws.StringValue(input.IfMatch)}.check(key, true, meta.ETag)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, errNoSuchKey(key)
+		}
+		return nil, err
+	}
+
+	size := info.Size()
+	out := &s3.GetObjectOutput{
+		AcceptRanges:  aws.String("bytes"),
+		ContentLength: aws.Int64(size),
+		ETag:          aws.String(meta.ETag),
+		LastModified:  aws.Time(info.ModTime().UTC()),
+		Body:          f,
+	}
+	if meta.ContentType != "" {
+		out.ContentType = aws.String(meta.ContentType)
+	}
+	if meta.ContentDisposition != "" {
+		out.ContentDisposition = aws.String(meta.ContentDisposition)
+	}
+	if len(meta.Metadata) != 0 {
+		out.Metadata = aws.StringMap(meta.Metadata)
+	}
+	if meta.SSECustomerKeyMD5 != "" {
+		out.SSECustomerAlgorithm = aws.String(sseCustomerAlgorithm)
+		out.SSECustomerKeyMD5 = aws.String(meta.SSECustomerKeyMD5)
+	}
+
+	if input.Range != nil {
+		start, end, err := parseRange(*input.Range, size)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		out.ContentLength = aws.Int64(end - start + 1)
+		out.ContentRange = aws.String(contentRange(start, end, size))
+		out.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.NewSectionReader(f, start, end-start+1), f}
+	}
+	return out, nil
+}
+
+func (b *fileBackend) HeadObjectWithContext(ctx aws.Context, input *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	key := aws.StringValue(input.Key)
+	if checkNullVersion(key, aws.StringValue(input.VersionId)) != nil {
+		return nil, errNotFound(key)
+	}
+	sseKeyMD5, err := sseCustomerKeyMD5(input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+	_, info, err := b.stat(key)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, errNotFound(key)
+		}
+		return nil, err
+	}
+	meta := b.readMeta(key, info)
+	err = checkSSECustomerKey(meta.SSECustomerKeyMD5, sseKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &s3.HeadObjectOutput{
+		AcceptRanges:  aws.String("bytes"),
+		ContentLength: aws.Int64(info.Size()),
+		ETag:          aws.String(meta.ETag),
+		LastModified:  aws.Time(info.ModTime().UTC()),
+	}
+	if meta.ContentType != "" {
+		out.ContentType = aws.String(meta.ContentType)
+	}
+	if meta.ContentDisposition != "" {
+		out.ContentDisposition = aws.String(meta.ContentDisposition)
+	}
+	if len(meta.Metadata) != 0 {
+		out.Metadata = aws.StringMap(meta.Metadata)
+	}
+	if meta.SSECustomerKeyMD5 != "" {
+		out.SSECustomerAlgorithm = aws.String(sseCustomerAlgorithm)
+		out.SSECustomerKeyMD5 = aws.String(meta.SSECustomerKeyMD5)
+	}
+	return out, nil
+}
+
+func (b *fileBackend) ListObjectsV2WithContext(ctx aws.Context, input *s3.ListObjectsV2Input, opts ...request.Option) (*s3.ListObjectsV2Output, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	prefix := aws.StringValue(input.Prefix)
+	recursive := aws.StringValue(input.Delimiter) != "/"
+
+	// Only the directory holding the prefix needs to be read.
+	base := ""
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		base = prefix[:i+1]
+	}
+
+	var objects []*s3.Object
+	root := filepath.Join(b.dir, filepath.FromSlash(base))
+	err := filepath.WalkDir(root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(b.dir, name)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if d.IsDir() {
+			if name == root {
+				return nil
+			}
+			if key == fileReserved {
+				return fs.SkipDir
+			}
+			if !recursive {
+				// Non empty directories are rolled up into a common prefix.
+				if strings.HasPrefix(key+"/", prefix) {
+					objects = append(objects, &s3.Object{
+						Key: aws.String(key + "/"),
+					})
+				}
+				return fs.SkipDir
+			}
+			if !strings.HasPrefix(key+"/", prefix) && !strings.HasPrefix(prefix, key+"/") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		meta := b.readMeta(key, info)
+		objects = append(objects, &s3.Object{
+			Key:          aws.String(key),
+			Size:         aws.Int64(info.Size()),
+			ETag:         aws.String(meta.ETag),
+			LastModified: aws.Time(info.ModTime().UTC()),
+			StorageClass: aws.String(s3.StorageClassStandard),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return *objects[i].Key < *objects[j].Key
+	})
+
+	out := listObjectsV2(objects, input)
+	out.Name = input.Bucket
+	return out, nil
+}
+
+func (b *fileBackend) ListObjectsV2PagesWithContext(ctx aws.Context, input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool, opts ...request.Option) error {
+	return listObjectsV2Pages(input, func(in *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+		return b.ListObjectsV2WithContext(ctx, in, opts...)
+	}, fn)
+}
+
+func (b *fileBackend) deleteObject(key string) error {
+	name, err := b.objectPath(key)
+	if err != nil {
+		return err
+	}
+	err = b.remove(name, b.dir)
+	if err != nil {
+		return err
+	}
+	return b.remove(b.metaPath(key), filepath.Join(b.dir, fileReserved, "meta"))
+}
+
+func (b *fileBackend) DeleteObjectWithContext(ctx aws.Context, input *s3.DeleteObjectInput, opts ...request.Option) (*s3.DeleteObjectOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	key := aws.StringValue(input.Key)
+	err := checkNullVersion(key, aws.StringValue(input.VersionId))
+	if err != nil {
+		return nil, err
+	}
+	err = b.deleteObject(key)
+	if err != nil {
+		return nil, err
+	}
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func (b *fileBackend) DeleteObjectsWithContext(ctx aws.Context, input *s3.DeleteObjectsInput, opts ...request.Option) (*s3.DeleteObjectsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	out := &s3.DeleteObjectsOutput{}
+	for _, obj := range input.Delete.Objects {
+		err := checkNullVersion(aws.StringValue(obj.Key), aws.StringValue(obj.VersionId))
+		if err == nil {
+			err = b.deleteObject(aws.StringValue(obj.Key))
+		}
+		if err != nil {
+			out.Errors = append(out.Errors, &s3.Error{
+				Key:     obj.Key,
+				Code:    aws.String("InternalError"),
+				Message: aws.String(err.Error()),
+			})
+			continue
+		}
+		if !aws.BoolValue(input.Delete.Quiet) {
+			out.Deleted = append(out.Deleted, &s3.DeletedObject{
+				Key: obj.Key,
+			})
+		}
+	}
+	return out, nil
+}
+
+func (b *fileBackend) CopyObjectWithContext(ctx aws.Context, input *s3.CopyObjectInput, opts ...request.Option) (*s3.CopyObjectOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	srcKey, err := fileCopySource(input.Bucket, input.CopySource)
+	if err != nil {
+		return nil, err
+	}
+	srcKeyMD5, err := sseCustomerKeyMD5(input.CopySourceSSECustomerAlgorithm, input.CopySourceSSECustomerKey, input.CopySourceSSECustomerKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+	sseKeyMD5, err := sseCustomerKeyMD5(input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+	name, info, err := b.stat(srcKey)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, errNoSuchKey(srcKey)
+		}
+		return nil, err
+	}
+	meta := b.readMeta(srcKey, info)
+	err = checkSSECustomerKey(meta.SSECustomerKeyMD5, srcKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+	meta.SSECustomerKeyMD5 = sseKeyMD5
+	if aws.StringValue(input.MetadataDirective) == s3.MetadataDirectiveReplace {
+		meta.ContentType = aws.StringValue(input.ContentType)
+		meta.ContentDisposition = aws.StringValue(input.ContentDisposition)
+		meta.Metadata = aws.StringValueMap(input.Metadata)
+	}
+	if aws.StringValue(input.TaggingDirective) == s3.TaggingDirectiveReplace {
+		meta.Tags, err = parseTagging(input.Tagging)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	etag, err := b.putObject(aws.StringValue(input.Key), f, meta, writeConditions{})
+	if err != nil {
+		return nil, err
+	}
+	return &s3.CopyObjectOutput{
+		CopyObjectResult: &s3.CopyObjectResult{
+			ETag:         aws.String(etag),
+			LastModified: aws.Time(time.Now().UTC()),
+		},
+	}, nil
+}
+
+// fileCopySource returns the key of a copy source, the bucket only matters to its syntax.
+func fileCopySource(bucket, source *string) (string, error) {
+	s := aws.StringValue(source)
+	if aws.StringValue(bucket) == "" {
+		// Without a bucket in the URL the copy source is "/" followed by the key.
+		s = "/" + s
+	}
+	_, key, versionID, err := parseCopySource(s)
+	if err != nil {
+		return "", err
+	}
+	return key, checkNullVersion(key, versionID)
+}
+
+// nullVersionID is the version id S3 gives to objects stored without versioning.
+const nullVersionID = "null"
+
+// checkNullVersion fails for any version id but the null version,
+// the file backend keeping only the latest version of each object.
+func checkNullVersion(key, versionID string) error {
+	if versionID != "" && versionID != nullVersionID {
+		return errNoSuchVersion(key, versionID)
+	}
+	return nil
+}
+
+func (b *fileBackend) CreateMultipartUploadWithContext(ctx aws.Context, input *s3.CreateMultipartUploadInput, opts ...request.Option) (*s3.CreateMultipartUploadOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	key := aws.StringValue(input.Key)
+	_, err := b.objectPath(key)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := parseTagging(input.Tagging)
+	if err != nil {
+		return nil, err
+	}
+	sseKeyMD5, err := sseCustomerKeyMD5(input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+
+	uploadID := newUploadID()
+	dir, err := b.uploadPath(uploadID)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	err = writeJSON(filepath.Join(dir, "upload.json"), fileUpload{
+		Key:                key,
+		Initiated:          time.Now().UTC(),
+		ContentType:        aws.StringValue(input.ContentType),
+		ContentDisposition: aws.StringValue(input.ContentDisposition),
+		Metadata:           aws.StringValueMap(input.Metadata),
+		Tags:               tags,
+		SSECustomerKeyMD5:  sseKeyMD5,
+	})
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return &s3.CreateMultipartUploadOutput{
+		Bucket:   input.Bucket,
+		Key:      input.Key,
+		UploadId: aws.String(uploadID),
+	}, nil
+}
+
+func (b *fileBackend) readUpload(uploadID string) (string, *fileUpload, error) {
+	dir, err := b.uploadPath(uploadID)
+	if err != nil {
+		return "", nil, err
+	}
+	var upload fileUpload
+	err = readJSON(filepath.Join(dir, "upload.json"), &upload)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil, errNoSuchUpload(uploadID)
+		}
+		return "", nil, err
+	}
+	return dir, &upload, nil
+}
+
+func (b *fileBackend) UploadPartWithContext(ctx aws.Context, input *s3.UploadPartInput, opts ...request.Option) (*s3.UploadPartOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	partNumber := aws.Int64Value(input.PartNumber)
+	if partNumber < 1 || partNumber > 10000 {
+		return nil, errInvalidArgument(fmt.Sprintf("part number must be between 1 and 10000: %d", partNumber))
+	}
+
+	sseKeyMD5, err := sseCustomerKeyMD5(input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.Reader = strings.NewReader("")
+	if input.Body != nil {
+		body = input.Body
+	}
+	etag, err := b.uploadPart(aws.StringValue(input.UploadId), partNumber, sseKeyMD5, body)
+	if err != nil {
+		return nil, err
+	}
+	return &s3.UploadPartOutput{
+		ETag: aws.String(etag),
+	}, nil
+}
+
+func (b *fileBackend) UploadPartCopyWithContext(ctx aws.Context, input *s3.UploadPartCopyInput, opts ...request.Option) (*s3.UploadPartCopyOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	partNumber := aws.Int64Value(input.PartNumber)
+	if partNumber < 1 || partNumber > 10000 {
+		return nil, errInvalidArgument(fmt.Sprintf("part number must be between 1 and 10000: %d", partNumber))
+	}
+
+	srcKey, err := fileCopySource(input.Bucket, input.CopySource)
+	if err != nil {
+		return nil, err
+	}
+	srcKeyMD5, err := sseCustomerKeyMD5(input.CopySourceSSECustomerAlgorithm, input.CopySourceSSECustomerKey, input.CopySourceSSECustomerKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+	sseKeyMD5, err := sseCustomerKeyMD5(input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+	name, info, err := b.stat(srcKey)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, errNoSuchKey(srcKey)
+		}
+		return nil, err
+	}
+	err = checkSSECustomerKey(b.readMeta(srcKey, info).SSECustomerKeyMD5, srcKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	start, end := int64(0), info.Size()-1
+	if input.CopySourceRange != nil {
+		start, end, err = parseRange(*input.CopySourceRange, info.Size())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	etag, err := b.uploadPart(aws.StringValue(input.UploadId), partNumber, sseKeyMD5, io.NewSectionReader(f, start, end-start+1))
+	if err != nil {
+		return nil, err
+	}
+	return &s3.UploadPartCopyOutput{
+		CopyPartResult: &s3.CopyPartResult{
+			ETag:         aws.String(etag),
+			LastModified: aws.Time(time.Now().UTC()),
+		},
+	}, nil
+}
+
+// uploadPart stages the content of a part next to its ETag.
+func (b *fileBackend) uploadPart(uploadID string, partNumber int64, sseKeyMD5 string, body io.Reader) (string, error) {
+	dir, upload, err := b.readUpload(uploadID)
+	if err != nil {
+		return "", err
+	}
+	err = checkSSECustomerKey(upload.SSECustomerKeyMD5, sseKeyMD5)
+	if err != nil {
+		return "", err
+	}
+
+	tmp, sum, err := b.writeTemp(body)
+	if err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(sum) + `"`
+	err = os.WriteFile(filepath.Join(dir, partFile(partNumber)+".etag"), []byte(etag), 0644)
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	err = os.Rename(tmp, filepath.Join(dir, partFile(partNumber)))
+	if err != nil {
+		os.Remove(tmp)
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", errNoSuchUpload(uploadID)
+		}
+		return "", err
+	}
+	return etag, nil
+}
+
+// listParts returns the parts staged for an upload, sorted by part number.
+func (b *fileBackend) listParts(dir string) ([]*s3.Part, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var parts []*s3.Part
+	for _, entry := range entries {
+		n, ok := strings.CutPrefix(entry.Name(), "part-")
+		if !ok || strings.Contains(n, ".") {
+			continue
+		}
+		partNumber, err := strconv.ParseInt(n, 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		etag, err := os.ReadFile(filepath.Join(dir, entry.Name()+".etag"))
+		if err != nil {
+			continue
+		}
+		parts = append(parts, &s3.Part{
+			PartNumber:   aws.Int64(partNumber),
+			ETag:         aws.String(string(etag)),
+			Size:         aws.Int64(info.Size()),
+			LastModified: aws.Time(info.ModTime().UTC()),
+		})
+	}
+	sort.Sort(s3parts(parts))
+	return parts, nil
+}
+
+func (b *fileBackend) CompleteMultipartUploadWithContext(ctx aws.Context, input *s3.CompleteMultipartUploadInput, opts ...request.Option) (*s3.CompleteMultipartUploadOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	dir, upload, err := b.readUpload(aws.StringValue(input.UploadId))
+	if err != nil {
+		return nil, err
+	}
+
+	var completed []*s3.CompletedPart
+	if input.MultipartUpload != nil {
+		completed = input.MultipartUpload.Parts
+	}
+	if len(completed) == 0 {
+		return nil, errInvalidArgument("you must specify at least one part")
+	}
+
+	staged, err := b.listParts(dir)
+	if err != nil {
+		return nil, err
+	}
+	partETags := map[int64]string{}
+	for _, part := range staged {
+		partETags[*part.PartNumber] = *part.ETag
+	}
+
+	etags := make([]string, 0, len(completed))
+	readers := make([]io.Reader, 0, len(completed))
+	for i, p := range completed {
+		partNumber := aws.Int64Value(p.PartNumber)
+		if i > 0 && partNumber <= aws.Int64Value(completed[i-1].PartNumber) {
+			return nil, errInvalidArgument("the list of parts was not in ascending order")
+		}
+		etag, ok := partETags[partNumber]
+		if !ok || etag != aws.StringValue(p.ETag) {
+			return nil, errInvalidPart(partNumber)
+		}
+		f, err := os.Open(filepath.Join(dir, partFile(partNumber)))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		etags = append(etags, etag)
+		readers = append(readers, f)
+	}
+
+	etag, err := b.putObject(upload.Key, io.MultiReader(readers...), fileMeta{
+		ETag:               multipartETag(etags),
+		ContentType:        upload.ContentType,
+		ContentDisposition: upload.ContentDisposition,
+		Metadata:           upload.Metadata,
+		Tags:               upload.Tags,
+		SSECustomerKeyMD5:  upload.SSECustomerKeyMD5,
+	}, writeConditionsOf(opts))
+	if err != nil {
+		return nil, err
+	}
+	err = os.RemoveAll(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &s3.CompleteMultipartUploadOutput{
+		Bucket: input.Bucket,
+		Key:    aws.String(upload.Key),
+		ETag:   aws.String(etag),
+	}, nil
+}
+
+func (b *fileBackend) AbortMultipartUploadWithContext(ctx aws.Context, input *s3.AbortMultipartUploadInput, opts ...request.Option) (*s3.AbortMultipartUploadOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	dir, _, err := b.readUpload(aws.StringValue(input.UploadId))
+	if err != nil {
+		return nil, err
+	}
+	err = os.RemoveAll(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func (b *fileBackend) ListPartsPagesWithContext(ctx aws.Context, input *s3.ListPartsInput, fn func(*s3.ListPartsOutput, bool) bool, opts ...request.Option) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	dir, _, err := b.readUpload(aws.StringValue(input.UploadId))
+	if err != nil {
+		return err
+	}
+	parts, err := b.listParts(dir)
+	if err != nil {
+		return err
+	}
+	pages(parts, func(page []*s3.Part, lastPage bool) bool {
+		return fn(&s3.ListPartsOutput{
+			Bucket:      input.Bucket,
+			Key:         input.Key,
+			UploadId:    input.UploadId,
+			Parts:       page,
+			IsTruncated: aws.Bool(!lastPage),
+		}, lastPage)
+	})
+	return nil
+}
+
+func (b *fileBackend) ListMultipartUploadsPagesWithContext(ctx aws.Context, input *s3.ListMultipartUploadsInput, fn func(*s3.ListMultipartUploadsOutput, bool) bool, opts ...request.Option) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	prefix := aws.StringValue(input.Prefix)
+	entries, err := os.ReadDir(filepath.Join(b.dir, fileReserved, "uploads"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	var uploads []*s3.MultipartUpload
+	for _, entry := range entries {
+		_, upload, err := b.readUpload(entry.Name())
+		if err != nil {
+			continue
+		}
+		if !strings.HasPrefix(upload.Key, prefix) {
+			continue
+		}
+		uploads = append(uploads, &s3.MultipartUpload{
+			Key:       aws.String(upload.Key),
+			UploadId:  aws.String(entry.Name()),
+			Initiated: aws.Time(upload.Initiated),
+		})
+	}
+
+	sortUploads(uploads, func(u *s3.MultipartUpload) (string, time.Time) {
+		return *u.Key, *u.Initiated
+	})
+	pages(uploads, func(page []*s3.MultipartUpload, lastPage bool) bool {
+		return fn(&s3.ListMultipartUploadsOutput{
+			Bucket:      input.Bucket,
+			Prefix:      input.Prefix,
+			Uploads:     page,
+			IsTruncated: aws.Bool(!lastPage),
+		}, lastPage)
+	})
+	return nil
+}
+
+// taggedObject returns the sidecar of the object tagging requests are about.
+func (b *fileBackend) taggedObject(key, versionID string) (fileMeta, error) {
+	err := checkNullVersion(key, versionID)
+	if err != nil {
+		return fileMeta{}, err
+	}
+	_, info, err := b.stat(key)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fileMeta{}, errNoSuchKey(key)
+		}
+		return fileMeta{}, err
+	}
+	return b.readMeta(key, info), nil
+}
+
+func (b *fileBackend) GetObjectTaggingWithContext(ctx aws.Context, input *s3.GetObjectTaggingInput, opts ...request.Option) (*s3.GetObjectTaggingOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	meta, err := b.taggedObject(aws.StringValue(input.Key), aws.StringValue(input.VersionId))
+	if err != nil {
+		return nil, err
+	}
+	return &s3.GetObjectTaggingOutput{
+		TagSet: tagSet(meta.Tags),
+	}, nil
+}
+
+func (b *fileBackend) PutObjectTaggingWithContext(ctx aws.Context, input *s3.PutObjectTaggingInput, opts ...request.Option) (*s3.PutObjectTaggingOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if input.Tagging == nil {
+		return nil, errInvalidArgument("missing tagging")
+	}
+
+	key := aws.StringValue(input.Key)
+	meta, err := b.taggedObject(key, aws.StringValue(input.VersionId))
+	if err != nil {
+		return nil, err
+	}
+	meta.Tags = tagsOf(input.Tagging.TagSet)
+	err = b.writeMeta(key, meta)
+	if err != nil {
+		return nil, err
+	}
+	return &s3.PutObjectTaggingOutput{}, nil
+}
+
+func (b *fileBackend) DeleteObjectTaggingWithContext(ctx aws.Context, input *s3.DeleteObjectTaggingInput, opts ...request.Option) (*s3.DeleteObjectTaggingOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	key := aws.StringValue(input.Key)
+	meta, err := b.taggedObject(key, aws.StringValue(input.VersionId))
+	if err != nil {
+		return nil, err
+	}
+	meta.Tags = nil
+	err = b.writeMeta(key, meta)
+	if err != nil {
+		return nil, err
+	}
+	return &s3.DeleteObjectTaggingOutput{}, nil
+}
+
+// ListObjectVersionsPagesWithContext lists the objects as their only, null, version.
+func (b *fileBackend) ListObjectVersionsPagesWithContext(ctx aws.Context, input *s3.ListObjectVersionsInput, fn func(*s3.ListObjectVersionsOutput, bool) bool, opts ...request.Option) error {
+	var versions []objectVersion
+	err := b.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: input.Bucket,
+		Prefix: input.Prefix,
+	}, func(out *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range out.Contents {
+			versions = append(versions, objectVersion{Version: &s3.ObjectVersion{
+				Key:          obj.Key,
+				VersionId:    aws.String(nullVersionID),
+				IsLatest:     aws.Bool(true),
+				ETag:         obj.ETag,
+				Size:         obj.Size,
+				LastModified: obj.LastModified,
+				StorageClass: obj.StorageClass,
+			}})
+		}
+		return true
+	}, opts...)
+	if err != nil {
+		return err
+	}
+
+	listObjectVersionsPages(versions, input, fn)
+	return nil
+}
+
+// presignSignature returns the HMAC over the parts of a pre-signed request.
+func (b *fileBackend) presignSignature(method, key, expires string) []byte {
+	mac := hmac.New(sha256.New, b.signSecret)
+	mac.Write([]byte(method + "\n" + key + "\n" + expires))
+	return mac.Sum(nil)
+}
+
+func (b *fileBackend) Presign(input any, expires time.Duration) (string, error) {
+	if b.signEndpoint == "" || len(b.signSecret) == 0 {
+		return "", fmt.Errorf("presign: the file backend needs signendpoint and signsecret")
+	}
+
+	var method string
+	var key *string
+	switch input := input.(type) {
+	case *s3.GetObjectInput:
+		method, key = "GET", input.Key
+	case *s3.HeadObjectInput:
+		method, key = "HEAD", input.Key
+	case *s3.PutObjectInput:
+		method, key = "PUT", input.Key
+	case *s3.DeleteObjectInput:
+		method, key = "DELETE", input.Key
+	default:
+		return "", fmt.Errorf("presign: unsupported input %T by the file backend", input)
+	}
+
+	exp := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+	query := url.Values{}
+	query.Set(presignExpiresQuery, exp)
+	query.Set(presignSignatureQuery, hex.EncodeToString(b.presignSignature(method, aws.StringValue(key), exp)))
+	return b.signEndpoint + "/" + (&url.URL{Path: aws.StringValue(key)}).EscapedPath() + "?" + query.Encode(), nil
+}
+
+func (b *fileBackend) VerifyPresign(method, key string, query url.Values) (bool, error) {
+	signature := query.Get(presignSignatureQuery)
+	if signature == "" {
+		return false, nil
+	}
+	if len(b.signSecret) == 0 {
+		return false, fmt.Errorf("presign: the file backend needs signsecret")
+	}
+
+	exp := query.Get(presignExpiresQuery)
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("presign: invalid expires %q", exp)
+	}
+	if time.Now().Unix() > expires {
+		return false, fmt.Errorf("presign: expired")
+	}
+
+	got, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(got, b.presignSignature(method, key, exp)) {
+		return false, fmt.Errorf("presign: signature mismatch")
+	}
+	return true, nil
+}
@@ -0,0 +1,468 @@
+package sss
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// The helpers in this file are shared by the backends that emulate S3 in process.
+
+func errNoSuchKey(key string) error {
+	return awserr.NewRequestFailure(awserr.New(s3.ErrCodeNoSuchKey, "The specified key does not exist: "+key, nil), http.StatusNotFound, "")
+}
+
+// errNotFound is what HeadObject returns for a missing key, it has no body to carry NoSuchKey.
+func errNotFound(key string) error {
+	return awserr.NewRequestFailure(awserr.New("NotFound", "Not Found: "+key, nil), http.StatusNotFound, "")
+}
+
+func errNoSuchUpload(uploadID string) error {
+	return awserr.NewRequestFailure(awserr.New(s3.ErrCodeNoSuchUpload, "The specified upload does not exist: "+uploadID, nil), http.StatusNotFound, "")
+}
+
+func errInvalidPart(partNumber int64) error {
+	return awserr.NewRequestFailure(awserr.New("InvalidPart", fmt.Sprintf("One or more of the specified parts could not be found: %d", partNumber), nil), http.StatusBadRequest, "")
+}
+
+func errInvalidRange(rng string) error {
+	return awserr.NewRequestFailure(awserr.New("InvalidRange", "The requested range is not satisfiable: "+rng, nil), http.StatusRequestedRangeNotSatisfiable, "")
+}
+
+func errNoSuchVersion(key, versionID string) error {
+	return awserr.NewRequestFailure(awserr.New("NoSuchVersion", "The specified version does not exist: "+key+" "+versionID, nil), http.StatusNotFound, "")
+}
+
+// errMethodNotAllowed is what reading a delete marker by its version id returns.
+func errMethodNotAllowed(key string) error {
+	return awserr.NewRequestFailure(awserr.New("MethodNotAllowed", "The specified method is not allowed against this resource: "+key, nil), http.StatusMethodNotAllowed, "")
+}
+
+func errInvalidArgument(msg string) error {
+	return awserr.NewRequestFailure(awserr.New("InvalidArgument", msg, nil), http.StatusBadRequest, "")
+}
+
+func errInvalidRequest(msg string) error {
+	return awserr.NewRequestFailure(awserr.New("InvalidRequest", msg, nil), http.StatusBadRequest, "")
+}
+
+func errAccessDenied(msg string) error {
+	return awserr.NewRequestFailure(awserr.New("AccessDenied", msg, nil), http.StatusForbidden, "")
+}
+
+func errPreconditionFailed(key string) error {
+	return awserr.NewRequestFailure(awserr.New("PreconditionFailed", "At least one of the pre-conditions you specified did not hold: "+key, nil), http.StatusPreconditionFailed, "")
+}
+
+// writeConditions are the If-Match and If-None-Match headers of a conditional write,
+// reads only use If-Match.
+type writeConditions struct {
+	IfMatch     string
+	IfNoneMatch string
+}
+
+// writeConditionsOf returns the conditions opts set, by building a request with them
+// the way the SDK does before sending it.
+func writeConditionsOf(opts []request.Option) writeConditions {
+	if len(opts) == 0 {
+		return writeConditions{}
+	}
+	r := &request.Request{
+		HTTPRequest: &http.Request{Header: http.Header{}},
+	}
+	r.ApplyOptions(opts...)
+	r.Handlers.Build.Run(r)
+	return writeConditions{
+		IfMatch:     r.HTTPRequest.Header.Get("If-Match"),
+		IfNoneMatch: r.HTTPRequest.Header.Get("If-None-Match"),
+	}
+}
+
+// check fails the write of key if the conditions don't hold against its current object,
+// etag being ignored if the object doesn't exist.
+func (c writeConditions) check(key string, exists bool, etag string) error {
+	if c.IfNoneMatch != "" && exists && (c.IfNoneMatch == "*" || etagMatch(c.IfNoneMatch, etag)) {
+		return errPreconditionFailed(key)
+	}
+	if c.IfMatch != "" {
+		if !exists {
+			return errNoSuchKey(key)
+		}
+		if c.IfMatch != "*" && !etagMatch(c.IfMatch, etag) {
+			return errPreconditionFailed(key)
+		}
+	}
+	return nil
+}
+
+// etagMatch compares two ETags, either of them may be quoted.
+func etagMatch(a, b string) bool {
+	return strings.Trim(a, `"`) == strings.Trim(b, `"`)
+}
+
+// sseCustomerKeyMD5 validates the SSE-C parameters of a request and returns the base64 MD5 of the key,
+// which is all that is kept of it, or an empty string if the request carries no key.
+func sseCustomerKeyMD5(algorithm, key, keyMD5 *string) (string, error) {
+	if algorithm == nil && key == nil {
+		return "", nil
+	}
+	if aws.StringValue(algorithm) != sseCustomerAlgorithm {
+		return "", errInvalidArgument("the encryption algorithm must be " + sseCustomerAlgorithm)
+	}
+	if len(aws.StringValue(key)) != sseCustomerKeySize {
+		return "", errInvalidArgument("the secret key must be 256 bits")
+	}
+	sum := md5.Sum([]byte(*key))
+	md5sum := base64.StdEncoding.EncodeToString(sum[:])
+	if keyMD5 != nil && *keyMD5 != md5sum {
+		return "", errInvalidArgument("the calculated MD5 hash of the key did not match the hash that was provided")
+	}
+	return md5sum, nil
+}
+
+// checkSSECustomerKey checks the key MD5 of a request against the one an object or upload was stored with.
+func checkSSECustomerKey(stored, given string) error {
+	switch {
+	case stored == given:
+		return nil
+	case stored == "":
+		return errInvalidRequest("The encryption parameters are not applicable to this object.")
+	case given == "":
+		return errInvalidRequest("The object was stored using a form of Server Side Encryption. The correct parameters must be provided to retrieve the object.")
+	}
+	return errAccessDenied("The provided encryption key does not match the one the object was stored with.")
+}
+
+// etagOf returns the quoted ETag S3 assigns to a single part upload.
+func etagOf(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// multipartETag returns the ETag S3 assigns to a completed multipart upload.
+func multipartETag(etags []string) string {
+	h := md5.New()
+	for _, etag := range etags {
+		b, _ := hex.DecodeString(strings.Trim(etag, `"`))
+		h.Write(b)
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)) + "-" + strconv.Itoa(len(etags)) + `"`
+}
+
+// parseRange parses a single HTTP byte range against an object of the given size,
+// it returns the first and last byte offsets, both inclusive.
+func parseRange(rng string, size int64) (start, end int64, err error) {
+	spec, ok := strings.CutPrefix(rng, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, errInvalidRange(rng)
+	}
+	first, last, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, 0, errInvalidRange(rng)
+	}
+
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, errInvalidRange(rng)
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, nil
+	}
+
+	start, err = strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, errInvalidRange(rng)
+	}
+	end = size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, errInvalidRange(rng)
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end, nil
+}
+
+// contentRange formats the Content-Range header of a ranged response.
+func contentRange(start, end, size int64) string {
+	return "bytes " + strconv.FormatInt(start, 10) + "-" + strconv.FormatInt(end, 10) + "/" + strconv.FormatInt(size, 10)
+}
+
+// parseCopySource splits the CopySource of a copy request into bucket and key.
+func parseCopySource(source string) (bucket, key, versionID string, err error) {
+	source = strings.TrimPrefix(source, "/")
+	source, query, _ := strings.Cut(source, "?")
+	if query != "" {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return "", "", "", errInvalidArgument("invalid copy source: " + source)
+		}
+		versionID = values.Get("versionId")
+	}
+	if unescaped, err := url.PathUnescape(source); err == nil {
+		source = unescaped
+	}
+	bucket, key, ok := strings.Cut(source, "/")
+	if !ok || key == "" {
+		return "", "", "", errInvalidArgument("invalid copy source: " + source)
+	}
+	return bucket, key, versionID, nil
+}
+
+// listObjectsV2 applies the paging, prefix and delimiter rules of ListObjectsV2 to objects,
+// which must be sorted by key.
+func listObjectsV2(objects []*s3.Object, input *s3.ListObjectsV2Input) *s3.ListObjectsV2Output {
+	prefix := aws.StringValue(input.Prefix)
+	delimiter := aws.StringValue(input.Delimiter)
+	maxKeys := aws.Int64Value(input.MaxKeys)
+	if maxKeys <= 0 || maxKeys > listMax {
+		maxKeys = listMax
+	}
+
+	// skipPrefix is the last common prefix returned, whose keys were already rolled up.
+	var skipPrefix string
+	marker := aws.StringValue(input.StartAfter)
+	if token := aws.StringValue(input.ContinuationToken); token != "" {
+		marker = token
+		if delimiter != "" && strings.HasSuffix(token, delimiter) {
+			skipPrefix = token
+		}
+	}
+
+	out := &s3.ListObjectsV2Output{
+		Prefix:            input.Prefix,
+		Delimiter:         input.Delimiter,
+		StartAfter:        input.StartAfter,
+		ContinuationToken: input.ContinuationToken,
+		MaxKeys:           aws.Int64(maxKeys),
+		IsTruncated:       aws.Bool(false),
+	}
+
+	i := sort.Search(len(objects), func(i int) bool {
+		return *objects[i].Key >= prefix && *objects[i].Key > marker
+	})
+
+	var count int64
+	var last string
+	for ; i < len(objects); i++ {
+		key := *objects[i].Key
+		if !strings.HasPrefix(key, prefix) {
+			if key > prefix {
+				break
+			}
+			continue
+		}
+		if skipPrefix != "" && strings.HasPrefix(key, skipPrefix) {
+			continue
+		}
+
+		if count == maxKeys {
+			out.IsTruncated = aws.Bool(true)
+			out.NextContinuationToken = aws.String(last)
+			break
+		}
+
+		if delimiter != "" {
+			if idx := strings.Index(key[len(prefix):], delimiter); idx >= 0 {
+				commonPrefix := key[:len(prefix)+idx+len(delimiter)]
+				out.CommonPrefixes = append(out.CommonPrefixes, &s3.CommonPrefix{
+					Prefix: aws.String(commonPrefix),
+				})
+				count++
+				last = commonPrefix
+				skipPrefix = commonPrefix
+				continue
+			}
+		}
+
+		out.Contents = append(out.Contents, objects[i])
+		count++
+		last = key
+	}
+	out.KeyCount = aws.Int64(count)
+	return out
+}
+
+// listObjectsV2Pages drives list through every page the way ListObjectsV2PagesWithContext does.
+func listObjectsV2Pages(input *s3.ListObjectsV2Input, list func(*s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error), fn func(*s3.ListObjectsV2Output, bool) bool) error {
+	in := *input
+	for {
+		out, err := list(&in)
+		if err != nil {
+			return err
+		}
+		lastPage := !aws.BoolValue(out.IsTruncated)
+		if !fn(out, lastPage) || lastPage {
+			return nil
+		}
+		in.ContinuationToken = out.NextContinuationToken
+	}
+}
+
+// pages calls fn with items split into pages of at most listMax.
+func pages[T any](items []T, fn func(page []T, lastPage bool) bool) {
+	for i := 0; ; i += listMax {
+		end := i + listMax
+		if end >= len(items) {
+			fn(items[i:], true)
+			return
+		}
+		if !fn(items[i:end], false) {
+			return
+		}
+	}
+}
+
+// objectVersion is either a version or a delete marker of an object.
+type objectVersion struct {
+	Version *s3.ObjectVersion
+	Marker  *s3.DeleteMarkerEntry
+}
+
+func (v objectVersion) key() string {
+	if v.Marker != nil {
+		return *v.Marker.Key
+	}
+	return *v.Version.Key
+}
+
+func (v objectVersion) versionID() string {
+	if v.Marker != nil {
+		return *v.Marker.VersionId
+	}
+	return *v.Version.VersionId
+}
+
+// listObjectVersionsPages applies the prefix and marker rules of ListObjectVersions to versions,
+// which must be sorted by key and then from the newest, calling fn with each page.
+func listObjectVersionsPages(versions []objectVersion, input *s3.ListObjectVersionsInput, fn func(*s3.ListObjectVersionsOutput, bool) bool) {
+	prefix := aws.StringValue(input.Prefix)
+	keyMarker := aws.StringValue(input.KeyMarker)
+	versionIDMarker := aws.StringValue(input.VersionIdMarker)
+
+	filtered := make([]objectVersion, 0, len(versions))
+	// Without a version id marker every version of the key marker is skipped,
+	// otherwise those up to and including the version id marker.
+	skipping := true
+	for _, v := range versions {
+		key := v.key()
+		if !strings.HasPrefix(key, prefix) || key < keyMarker {
+			continue
+		}
+		if key == keyMarker && keyMarker != "" && skipping {
+			if v.versionID() == versionIDMarker {
+				skipping = false
+			}
+			continue
+		}
+		filtered = append(filtered, v)
+	}
+
+	pages(filtered, func(page []objectVersion, lastPage bool) bool {
+		out := &s3.ListObjectVersionsOutput{
+			Name:            input.Bucket,
+			Prefix:          input.Prefix,
+			KeyMarker:       input.KeyMarker,
+			VersionIdMarker: input.VersionIdMarker,
+			MaxKeys:         aws.Int64(listMax),
+			IsTruncated:     aws.Bool(!lastPage),
+		}
+		for _, v := range page {
+			if v.Marker != nil {
+				out.DeleteMarkers = append(out.DeleteMarkers, v.Marker)
+			} else {
+				out.Versions = append(out.Versions, v.Version)
+			}
+		}
+		if !lastPage && len(page) != 0 {
+			out.NextKeyMarker = aws.String(page[len(page)-1].key())
+			out.NextVersionIdMarker = aws.String(page[len(page)-1].versionID())
+		}
+		return fn(out, lastPage)
+	})
+}
+
+// parseTagging parses the x-amz-tagging header of a write, a URL query of tags.
+func parseTagging(tagging *string) (map[string]string, error) {
+	if tagging == nil {
+		return nil, nil
+	}
+	values, err := url.ParseQuery(*tagging)
+	if err != nil {
+		return nil, errInvalidArgument("invalid tagging: " + *tagging)
+	}
+	tags := make(map[string]string, len(values))
+	for k, v := range values {
+		tags[k] = v[0]
+	}
+	return tags, nil
+}
+
+// tagSet converts tags to the TagSet of a GetObjectTagging response, sorted by key.
+func tagSet(tags map[string]string) []*s3.Tag {
+	set := make([]*s3.Tag, 0, len(tags))
+	for k, v := range tags {
+		set = append(set, &s3.Tag{
+			Key:   aws.String(k),
+			Value: aws.String(v),
+		})
+	}
+	sort.Slice(set, func(i, j int) bool {
+		return *set[i].Key < *set[j].Key
+	})
+	return set
+}
+
+// tagsOf converts the TagSet of a PutObjectTagging request to tags.
+func tagsOf(set []*s3.Tag) map[string]string {
+	tags := make(map[string]string, len(set))
+	for _, tag := range set {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return tags
+}
+
+// newUploadID returns a random id for a new multipart upload.
+func newUploadID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// newVersionID returns a random id for a new version of an object.
+func newVersionID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// sortUploads orders uploads the way ListMultipartUploads does, by key and then by initiation time.
+func sortUploads[T any](uploads []T, by func(T) (string, time.Time)) {
+	sort.Slice(uploads, func(i, j int) bool {
+		ki, ti := by(uploads[i])
+		kj, tj := by(uploads[j])
+		if ki != kj {
+			return ki < kj
+		}
+		return ti.Before(tj)
+	})
+}
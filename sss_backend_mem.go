@@ -0,0 +1,932 @@
+package sss
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func init() {
+	RegisterBackend("mem", func(u *url.URL) (Backend, error) {
+		return NewMemBackend(), nil
+	})
+}
+
+var _ Backend = (*memBackend)(nil)
+
+type memObject struct {
+	key                string
+	versionID          string
+	deleteMarker       bool
+	data               []byte
+	etag               string
+	lastModified       time.Time
+	contentType        string
+	contentDisposition string
+	metadata           map[string]string
+	tags               map[string]string
+	sseKeyMD5          string
+}
+
+type memPart struct {
+	data         []byte
+	etag         string
+	lastModified time.Time
+}
+
+type memUpload struct {
+	bucket             string
+	key                string
+	uploadID           string
+	initiated          time.Time
+	contentType        string
+	contentDisposition string
+	metadata           map[string]string
+	tags               map[string]string
+	sseKeyMD5          string
+	parts              map[int64]*memPart
+}
+
+type memBucket struct {
+	// objects holds the latest version of each key that is not deleted.
+	objects map[string]*memObject
+	// versions holds every version and delete marker of each key, from the oldest.
+	versions map[string][]*memObject
+}
+
+// put makes obj the latest version of its key.
+func (bkt *memBucket) put(obj *memObject) {
+	obj.versionID = newVersionID()
+	bkt.versions[obj.key] = append(bkt.versions[obj.key], obj)
+	bkt.objects[obj.key] = obj
+}
+
+// delete places a delete marker on top of key and returns it.
+func (bkt *memBucket) delete(key string) *memObject {
+	marker := &memObject{
+		key:          key,
+		versionID:    newVersionID(),
+		deleteMarker: true,
+		lastModified: time.Now().UTC(),
+	}
+	bkt.versions[key] = append(bkt.versions[key], marker)
+	delete(bkt.objects, key)
+	return marker
+}
+
+// deleteVersion permanently removes a version of key and returns it, if it exists.
+func (bkt *memBucket) deleteVersion(key, versionID string) *memObject {
+	versions := bkt.versions[key]
+	i := slices.IndexFunc(versions, func(v *memObject) bool {
+		return v.versionID == versionID
+	})
+	if i < 0 {
+		return nil
+	}
+	v := versions[i]
+	versions = slices.Delete(versions, i, i+1)
+	if len(versions) == 0 {
+		delete(bkt.versions, key)
+		delete(bkt.objects, key)
+		return v
+	}
+
+	bkt.versions[key] = versions
+	if latest := versions[len(versions)-1]; latest.deleteMarker {
+		delete(bkt.objects, key)
+	} else {
+		bkt.objects[key] = latest
+	}
+	return v
+}
+
+// memBackend is a Backend keeping every bucket in process memory.
+// Buckets keep every version of their objects, as with versioning enabled on S3.
+type memBackend struct {
+	mut     sync.RWMutex
+	buckets map[string]*memBucket
+	uploads map[string]*memUpload
+}
+
+// NewMemBackend returns a Backend that keeps everything in process memory,
+// it is what the mem:// scheme of WithURL uses.
+// Each call returns an empty store, share one with WithBackend to see the same objects.
+func NewMemBackend() Backend {
+	return &memBackend{
+		buckets: map[string]*memBucket{},
+		uploads: map[string]*memUpload{},
+	}
+}
+
+func (b *memBackend) bucket(name string) *memBucket {
+	bucket, ok := b.buckets[name]
+	if !ok {
+		bucket = &memBucket{
+			objects:  map[string]*memObject{},
+			versions: map[string][]*memObject{},
+		}
+		b.buckets[name] = bucket
+	}
+	return bucket
+}
+
+func (b *memBackend) getObject(bucket, key string) (*memObject, bool) {
+	bkt, ok := b.buckets[bucket]
+	if !ok {
+		return nil, false
+	}
+	obj, ok := bkt.objects[key]
+	return obj, ok
+}
+
+// checkWrite checks the conditions of a write of key set in opts, b.mut must be held.
+func (b *memBackend) checkWrite(bucket, key string, opts []request.Option) error {
+	obj, ok := b.getObject(bucket, key)
+	if !ok {
+		return writeConditionsOf(opts).check(key, false, "")
+	}
+	return writeConditionsOf(opts).check(key, true, obj.etag)
+}
+
+// lookupObject returns the given version of key, or the latest one if versionID is empty,
+// failing the way GetObject does if there is no such object.
+func (b *memBackend) lookupObject(bucket, key, versionID string) (*memObject, error) {
+	obj, ok := b.getVersion(bucket, key, versionID)
+	switch {
+	case !ok && versionID != "":
+		return nil, errNoSuchVersion(key, versionID)
+	case !ok:
+		return nil, errNoSuchKey(key)
+	case obj.deleteMarker:
+		return nil, errMethodNotAllowed(key)
+	}
+	return obj, nil
+}
+
+// getVersion returns the given version of key, or the latest one if versionID is empty.
+// The version may be a delete marker.
+func (b *memBackend) getVersion(bucket, key, versionID string) (*memObject, bool) {
+	if versionID == "" {
+		return b.getObject(bucket, key)
+	}
+	bkt, ok := b.buckets[bucket]
+	if !ok {
+		return nil, false
+	}
+	for _, v := range bkt.versions[key] {
+		if v.versionID == versionID {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func (b *memBackend) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	tags, err := parseTagging(input.Tagging)
+	if err != nil {
+		return nil, err
+	}
+	sseKeyMD5, err := sseCustomerKeyMD5(input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	if input.Body != nil {
+		data, err = io.ReadAll(input.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	obj := &memObject{
+		key:                aws.StringValue(input.Key),
+		data:               data,
+		etag:               etagOf(data),
+		lastModified:       time.Now().UTC(),
+		contentType:        aws.StringValue(input.ContentType),
+		contentDisposition: aws.StringValue(input.ContentDisposition),
+		metadata:           aws.StringValueMap(input.Metadata),
+		tags:               tags,
+		sseKeyMD5:          sseKeyMD5,
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	err = b.checkWrite(aws.StringValue(input.Bucket), obj.key, opts)
+	if err != nil {
+		return nil, err
+	}
+	b.bucket(aws.StringValue(input.Bucket)).put(obj)
+	out := &s3.PutObjectOutput{
+		ETag:      aws.String(obj.etag),
+		VersionId: aws.String(obj.versionID),
+	}
+	if sseKeyMD5 != "" {
+		out.SSECustomerAlgorithm = aws.String(sseCustomerAlgorithm)
+		out.SSECustomerKeyMD5 = aws.String(sseKeyMD5)
+	}
+	return out, nil
+}
+
+func (b *memBackend) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sseKeyMD5, err := sseCustomerKeyMD5(input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mut.RLock()
+	defer b.mut.RUnlock()
+	obj, err := b.lookupObject(aws.StringValue(input.Bucket), aws.StringValue(input.Key), aws.StringValue(input.VersionId))
+	if err != nil {
+		return nil, err
+	}
+	err = checkSSECustomerKey(obj.sseKeyMD5, sseKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+	err = writeConditions{IfMatch: aws.StringValue(input.IfMatch)}.check(obj.key, true, obj.etag)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &s3.GetObjectOutput{
+		AcceptRanges:  aws.String("bytes"),
+		ContentLength: aws.Int64(int64(len(obj.data))),
+		ETag:          aws.String(obj.etag),
+		LastModified:  aws.Time(obj.lastModified),
+		VersionId:     aws.String(obj.versionID),
+	}
+	if obj.contentType != "" {
+		out.ContentType = aws.String(obj.contentType)
+	}
+	if obj.contentDisposition != "" {
+		out.ContentDisposition = aws.String(obj.contentDisposition)
+	}
+	if len(obj.metadata) != 0 {
+		out.Metadata = aws.StringMap(obj.metadata)
+	}
+	if obj.sseKeyMD5 != "" {
+		out.SSECustomerAlgorithm = aws.String(sseCustomerAlgorithm)
+		out.SSECustomerKeyMD5 = aws.String(obj.sseKeyMD5)
+	}
+
+	data := obj.data
+	if input.Range != nil {
+		start, end, err := parseRange(*input.Range, int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		data = data[start : end+1]
+		out.ContentLength = aws.Int64(int64(len(data)))
+		out.ContentRange = aws.String(contentRange(start, end, int64(len(obj.data))))
+	}
+	out.Body = io.NopCloser(bytes.NewReader(data))
+	return out, nil
+}
+
+func (b *memBackend) HeadObjectWithContext(ctx aws.Context, input *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sseKeyMD5, err := sseCustomerKeyMD5(input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mut.RLock()
+	defer b.mut.RUnlock()
+	key := aws.StringValue(input.Key)
+	obj, ok := b.getVersion(aws.StringValue(input.Bucket), key, aws.StringValue(input.VersionId))
+	switch {
+	case !ok:
+		return nil, errNotFound(key)
+	case obj.deleteMarker:
+		return nil, errMethodNotAllowed(key)
+	}
+	err = checkSSECustomerKey(obj.sseKeyMD5, sseKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &s3.HeadObjectOutput{
+		AcceptRanges:  aws.String("bytes"),
+		ContentLength: aws.Int64(int64(len(obj.data))),
+		ETag:          aws.String(obj.etag),
+		LastModified:  aws.Time(obj.lastModified),
+		VersionId:     aws.String(obj.versionID),
+	}
+	if obj.contentType != "" {
+		out.ContentType = aws.String(obj.contentType)
+	}
+	if obj.contentDisposition != "" {
+		out.ContentDisposition = aws.String(obj.contentDisposition)
+	}
+	if len(obj.metadata) != 0 {
+		out.Metadata = aws.StringMap(obj.metadata)
+	}
+	if obj.sseKeyMD5 != "" {
+		out.SSECustomerAlgorithm = aws.String(sseCustomerAlgorithm)
+		out.SSECustomerKeyMD5 = aws.String(obj.sseKeyMD5)
+	}
+	return out, nil
+}
+
+func (b *memBackend) ListObjectsV2WithContext(ctx aws.Context, input *s3.ListObjectsV2Input, opts ...request.Option) (*s3.ListObjectsV2Output, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mut.RLock()
+	defer b.mut.RUnlock()
+
+	var objects []*s3.Object
+	if bkt, ok := b.buckets[aws.StringValue(input.Bucket)]; ok {
+		prefix := aws.StringValue(input.Prefix)
+		objects = make([]*s3.Object, 0, len(bkt.objects))
+		for key, obj := range bkt.objects {
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			objects = append(objects, &s3.Object{
+				Key:          aws.String(key),
+				Size:         aws.Int64(int64(len(obj.data))),
+				ETag:         aws.String(obj.etag),
+				LastModified: aws.Time(obj.lastModified),
+				StorageClass: aws.String(s3.StorageClassStandard),
+			})
+		}
+		sort.Slice(objects, func(i, j int) bool {
+			return *objects[i].Key < *objects[j].Key
+		})
+	}
+
+	out := listObjectsV2(objects, input)
+	out.Name = input.Bucket
+	return out, nil
+}
+
+func (b *memBackend) ListObjectsV2PagesWithContext(ctx aws.Context, input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool, opts ...request.Option) error {
+	return listObjectsV2Pages(input, func(in *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+		return b.ListObjectsV2WithContext(ctx, in, opts...)
+	}, fn)
+}
+
+func (b *memBackend) DeleteObjectWithContext(ctx aws.Context, input *s3.DeleteObjectInput, opts ...request.Option) (*s3.DeleteObjectOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	deleted := b.deleteObject(aws.StringValue(input.Bucket), aws.StringValue(input.Key), aws.StringValue(input.VersionId))
+	return &s3.DeleteObjectOutput{
+		VersionId:    deleted.VersionId,
+		DeleteMarker: deleted.DeleteMarker,
+	}, nil
+}
+
+// deleteObject removes a version of key, or places a delete marker if versionID is empty.
+func (b *memBackend) deleteObject(bucket, key, versionID string) *s3.DeletedObject {
+	bkt := b.bucket(bucket)
+	if versionID == "" {
+		marker := bkt.delete(key)
+		return &s3.DeletedObject{
+			Key:                   aws.String(key),
+			DeleteMarker:          aws.Bool(true),
+			DeleteMarkerVersionId: aws.String(marker.versionID),
+		}
+	}
+
+	deleted := &s3.DeletedObject{
+		Key:       aws.String(key),
+		VersionId: aws.String(versionID),
+	}
+	if v := bkt.deleteVersion(key, versionID); v != nil && v.deleteMarker {
+		deleted.DeleteMarker = aws.Bool(true)
+		deleted.DeleteMarkerVersionId = aws.String(versionID)
+	}
+	return deleted
+}
+
+func (b *memBackend) DeleteObjectsWithContext(ctx aws.Context, input *s3.DeleteObjectsInput, opts ...request.Option) (*s3.DeleteObjectsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	out := &s3.DeleteObjectsOutput{}
+	for _, obj := range input.Delete.Objects {
+		deleted := b.deleteObject(aws.StringValue(input.Bucket), aws.StringValue(obj.Key), aws.StringValue(obj.VersionId))
+		if !aws.BoolValue(input.Delete.Quiet) {
+			out.Deleted = append(out.Deleted, deleted)
+		}
+	}
+	return out, nil
+}
+
+func (b *memBackend) CopyObjectWithContext(ctx aws.Context, input *s3.CopyObjectInput, opts ...request.Option) (*s3.CopyObjectOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	srcBucket, srcKey, srcVersionID, err := parseCopySource(aws.StringValue(input.CopySource))
+	if err != nil {
+		return nil, err
+	}
+	srcKeyMD5, err := sseCustomerKeyMD5(input.CopySourceSSECustomerAlgorithm, input.CopySourceSSECustomerKey, input.CopySourceSSECustomerKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+	sseKeyMD5, err := sseCustomerKeyMD5(input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	src, err := b.copySource(srcBucket, srcKey, srcVersionID)
+	if err != nil {
+		return nil, err
+	}
+	err = checkSSECustomerKey(src.sseKeyMD5, srcKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := *src
+	obj.key = aws.StringValue(input.Key)
+	obj.lastModified = time.Now().UTC()
+	obj.sseKeyMD5 = sseKeyMD5
+	if aws.StringValue(input.MetadataDirective) == s3.MetadataDirectiveReplace {
+		obj.contentType = aws.StringValue(input.ContentType)
+		obj.contentDisposition = aws.StringValue(input.ContentDisposition)
+		obj.metadata = aws.StringValueMap(input.Metadata)
+	}
+	if aws.StringValue(input.TaggingDirective) == s3.TaggingDirectiveReplace {
+		obj.tags, err = parseTagging(input.Tagging)
+		if err != nil {
+			return nil, err
+		}
+	}
+	b.bucket(aws.StringValue(input.Bucket)).put(&obj)
+	return &s3.CopyObjectOutput{
+		CopyObjectResult: &s3.CopyObjectResult{
+			ETag:         aws.String(obj.etag),
+			LastModified: aws.Time(obj.lastModified),
+		},
+		VersionId:           aws.String(obj.versionID),
+		CopySourceVersionId: aws.String(src.versionID),
+	}, nil
+}
+
+// upload returns the multipart upload uploadID of key in bucket,
+// an upload of another key being as missing as on S3.
+func (b *memBackend) upload(bucket, key, uploadID *string) (*memUpload, error) {
+	upload, ok := b.uploads[aws.StringValue(uploadID)]
+	if !ok || upload.bucket != aws.StringValue(bucket) || upload.key != aws.StringValue(key) {
+		return nil, errNoSuchUpload(aws.StringValue(uploadID))
+	}
+	return upload, nil
+}
+
+func (b *memBackend) copySource(bucket, key, versionID string) (*memObject, error) {
+	src, ok := b.getVersion(bucket, key, versionID)
+	switch {
+	case !ok && versionID != "":
+		return nil, errNoSuchVersion(key, versionID)
+	case !ok:
+		return nil, errNoSuchKey(key)
+	case src.deleteMarker:
+		return nil, errInvalidArgument("the source of a copy must not be a delete marker")
+	}
+	return src, nil
+}
+
+func (b *memBackend) CreateMultipartUploadWithContext(ctx aws.Context, input *s3.CreateMultipartUploadInput, opts ...request.Option) (*s3.CreateMultipartUploadOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	tags, err := parseTagging(input.Tagging)
+	if err != nil {
+		return nil, err
+	}
+	sseKeyMD5, err := sseCustomerKeyMD5(input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+
+	upload := &memUpload{
+		bucket:             aws.StringValue(input.Bucket),
+		key:                aws.StringValue(input.Key),
+		uploadID:           newUploadID(),
+		initiated:          time.Now().UTC(),
+		contentType:        aws.StringValue(input.ContentType),
+		contentDisposition: aws.StringValue(input.ContentDisposition),
+		metadata:           aws.StringValueMap(input.Metadata),
+		tags:               tags,
+		sseKeyMD5:          sseKeyMD5,
+		parts:              map[int64]*memPart{},
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	b.uploads[upload.uploadID] = upload
+	return &s3.CreateMultipartUploadOutput{
+		Bucket:   input.Bucket,
+		Key:      input.Key,
+		UploadId: aws.String(upload.uploadID),
+	}, nil
+}
+
+func (b *memBackend) UploadPartWithContext(ctx aws.Context, input *s3.UploadPartInput, opts ...request.Option) (*s3.UploadPartOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	partNumber := aws.Int64Value(input.PartNumber)
+	if partNumber < 1 || partNumber > 10000 {
+		return nil, errInvalidArgument(fmt.Sprintf("part number must be between 1 and 10000: %d", partNumber))
+	}
+
+	sseKeyMD5, err := sseCustomerKeyMD5(input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	if input.Body != nil {
+		data, err = io.ReadAll(input.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	part := &memPart{
+		data:         data,
+		etag:         etagOf(data),
+		lastModified: time.Now().UTC(),
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	upload, err := b.upload(input.Bucket, input.Key, input.UploadId)
+	if err != nil {
+		return nil, err
+	}
+	err = checkSSECustomerKey(upload.sseKeyMD5, sseKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+	upload.parts[partNumber] = part
+	return &s3.UploadPartOutput{
+		ETag: aws.String(part.etag),
+	}, nil
+}
+
+func (b *memBackend) UploadPartCopyWithContext(ctx aws.Context, input *s3.UploadPartCopyInput, opts ...request.Option) (*s3.UploadPartCopyOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	partNumber := aws.Int64Value(input.PartNumber)
+	if partNumber < 1 || partNumber > 10000 {
+		return nil, errInvalidArgument(fmt.Sprintf("part number must be between 1 and 10000: %d", partNumber))
+	}
+
+	srcBucket, srcKey, srcVersionID, err := parseCopySource(aws.StringValue(input.CopySource))
+	if err != nil {
+		return nil, err
+	}
+	srcKeyMD5, err := sseCustomerKeyMD5(input.CopySourceSSECustomerAlgorithm, input.CopySourceSSECustomerKey, input.CopySourceSSECustomerKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+	sseKeyMD5, err := sseCustomerKeyMD5(input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	src, err := b.copySource(srcBucket, srcKey, srcVersionID)
+	if err != nil {
+		return nil, err
+	}
+	err = checkSSECustomerKey(src.sseKeyMD5, srcKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+	upload, err := b.upload(input.Bucket, input.Key, input.UploadId)
+	if err != nil {
+		return nil, err
+	}
+	err = checkSSECustomerKey(upload.sseKeyMD5, sseKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+
+	data := src.data
+	if input.CopySourceRange != nil {
+		start, end, err := parseRange(*input.CopySourceRange, int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		data = data[start : end+1]
+	}
+
+	part := &memPart{
+		data:         data,
+		etag:         etagOf(data),
+		lastModified: time.Now().UTC(),
+	}
+	upload.parts[partNumber] = part
+	return &s3.UploadPartCopyOutput{
+		CopyPartResult: &s3.CopyPartResult{
+			ETag:         aws.String(part.etag),
+			LastModified: aws.Time(part.lastModified),
+		},
+	}, nil
+}
+
+func (b *memBackend) CompleteMultipartUploadWithContext(ctx aws.Context, input *s3.CompleteMultipartUploadInput, opts ...request.Option) (*s3.CompleteMultipartUploadOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	upload, err := b.upload(input.Bucket, input.Key, input.UploadId)
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []*s3.CompletedPart
+	if input.MultipartUpload != nil {
+		parts = input.MultipartUpload.Parts
+	}
+	if len(parts) == 0 {
+		return nil, errInvalidArgument("you must specify at least one part")
+	}
+
+	var size int
+	etags := make([]string, 0, len(parts))
+	for i, p := range parts {
+		partNumber := aws.Int64Value(p.PartNumber)
+		if i > 0 && partNumber <= aws.Int64Value(parts[i-1].PartNumber) {
+			return nil, errInvalidArgument("the list of parts was not in ascending order")
+		}
+		part, ok := upload.parts[partNumber]
+		if !ok || part.etag != aws.StringValue(p.ETag) {
+			return nil, errInvalidPart(partNumber)
+		}
+		size += len(part.data)
+		etags = append(etags, part.etag)
+	}
+
+	data := make([]byte, 0, size)
+	for _, p := range parts {
+		data = append(data, upload.parts[*p.PartNumber].data...)
+	}
+
+	obj := &memObject{
+		key:                upload.key,
+		data:               data,
+		etag:               multipartETag(etags),
+		lastModified:       time.Now().UTC(),
+		contentType:        upload.contentType,
+		contentDisposition: upload.contentDisposition,
+		metadata:           upload.metadata,
+		tags:               upload.tags,
+		sseKeyMD5:          upload.sseKeyMD5,
+	}
+	err = b.checkWrite(upload.bucket, upload.key, opts)
+	if err != nil {
+		return nil, err
+	}
+	b.bucket(upload.bucket).put(obj)
+	delete(b.uploads, upload.uploadID)
+	return &s3.CompleteMultipartUploadOutput{
+		Bucket:    aws.String(upload.bucket),
+		Key:       aws.String(upload.key),
+		ETag:      aws.String(obj.etag),
+		VersionId: aws.String(obj.versionID),
+	}, nil
+}
+
+func (b *memBackend) AbortMultipartUploadWithContext(ctx aws.Context, input *s3.AbortMultipartUploadInput, opts ...request.Option) (*s3.AbortMultipartUploadOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	_, err := b.upload(input.Bucket, input.Key, input.UploadId)
+	if err != nil {
+		return nil, err
+	}
+	delete(b.uploads, aws.StringValue(input.UploadId))
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func (b *memBackend) ListPartsPagesWithContext(ctx aws.Context, input *s3.ListPartsInput, fn func(*s3.ListPartsOutput, bool) bool, opts ...request.Option) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mut.RLock()
+	upload, err := b.upload(input.Bucket, input.Key, input.UploadId)
+	if err != nil {
+		b.mut.RUnlock()
+		return err
+	}
+	parts := make([]*s3.Part, 0, len(upload.parts))
+	for partNumber, part := range upload.parts {
+		parts = append(parts, &s3.Part{
+			PartNumber:   aws.Int64(partNumber),
+			ETag:         aws.String(part.etag),
+			Size:         aws.Int64(int64(len(part.data))),
+			LastModified: aws.Time(part.lastModified),
+		})
+	}
+	b.mut.RUnlock()
+
+	sort.Sort(s3parts(parts))
+	pages(parts, func(page []*s3.Part, lastPage bool) bool {
+		return fn(&s3.ListPartsOutput{
+			Bucket:      input.Bucket,
+			Key:         input.Key,
+			UploadId:    input.UploadId,
+			Parts:       page,
+			IsTruncated: aws.Bool(!lastPage),
+		}, lastPage)
+	})
+	return nil
+}
+
+func (b *memBackend) ListMultipartUploadsPagesWithContext(ctx aws.Context, input *s3.ListMultipartUploadsInput, fn func(*s3.ListMultipartUploadsOutput, bool) bool, opts ...request.Option) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	bucket := aws.StringValue(input.Bucket)
+	prefix := aws.StringValue(input.Prefix)
+
+	b.mut.RLock()
+	uploads := make([]*memUpload, 0, len(b.uploads))
+	for _, upload := range b.uploads {
+		if upload.bucket == bucket && strings.HasPrefix(upload.key, prefix) {
+			uploads = append(uploads, upload)
+		}
+	}
+	b.mut.RUnlock()
+
+	sortUploads(uploads, func(u *memUpload) (string, time.Time) {
+		return u.key, u.initiated
+	})
+	pages(uploads, func(page []*memUpload, lastPage bool) bool {
+		out := &s3.ListMultipartUploadsOutput{
+			Bucket:      input.Bucket,
+			Prefix:      input.Prefix,
+			IsTruncated: aws.Bool(!lastPage),
+		}
+		for _, upload := range page {
+			out.Uploads = append(out.Uploads, &s3.MultipartUpload{
+				Key:       aws.String(upload.key),
+				UploadId:  aws.String(upload.uploadID),
+				Initiated: aws.Time(upload.initiated),
+			})
+		}
+		return fn(out, lastPage)
+	})
+	return nil
+}
+
+func (b *memBackend) GetObjectTaggingWithContext(ctx aws.Context, input *s3.GetObjectTaggingInput, opts ...request.Option) (*s3.GetObjectTaggingOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mut.RLock()
+	defer b.mut.RUnlock()
+	obj, err := b.lookupObject(aws.StringValue(input.Bucket), aws.StringValue(input.Key), aws.StringValue(input.VersionId))
+	if err != nil {
+		return nil, err
+	}
+	return &s3.GetObjectTaggingOutput{
+		TagSet:    tagSet(obj.tags),
+		VersionId: aws.String(obj.versionID),
+	}, nil
+}
+
+func (b *memBackend) PutObjectTaggingWithContext(ctx aws.Context, input *s3.PutObjectTaggingInput, opts ...request.Option) (*s3.PutObjectTaggingOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if input.Tagging == nil {
+		return nil, errInvalidArgument("missing tagging")
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	obj, err := b.lookupObject(aws.StringValue(input.Bucket), aws.StringValue(input.Key), aws.StringValue(input.VersionId))
+	if err != nil {
+		return nil, err
+	}
+	obj.tags = tagsOf(input.Tagging.TagSet)
+	return &s3.PutObjectTaggingOutput{
+		VersionId: aws.String(obj.versionID),
+	}, nil
+}
+
+func (b *memBackend) DeleteObjectTaggingWithContext(ctx aws.Context, input *s3.DeleteObjectTaggingInput, opts ...request.Option) (*s3.DeleteObjectTaggingOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	obj, err := b.lookupObject(aws.StringValue(input.Bucket), aws.StringValue(input.Key), aws.StringValue(input.VersionId))
+	if err != nil {
+		return nil, err
+	}
+	obj.tags = nil
+	return &s3.DeleteObjectTaggingOutput{
+		VersionId: aws.String(obj.versionID),
+	}, nil
+}
+
+func (b *memBackend) ListObjectVersionsPagesWithContext(ctx aws.Context, input *s3.ListObjectVersionsInput, fn func(*s3.ListObjectVersionsOutput, bool) bool, opts ...request.Option) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	prefix := aws.StringValue(input.Prefix)
+
+	b.mut.RLock()
+	var versions []objectVersion
+	if bkt, ok := b.buckets[aws.StringValue(input.Bucket)]; ok {
+		keys := make([]string, 0, len(bkt.versions))
+		for key := range bkt.versions {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			objs := bkt.versions[key]
+			for i := len(objs) - 1; i >= 0; i-- {
+				obj := objs[i]
+				isLatest := aws.Bool(i == len(objs)-1)
+				if obj.deleteMarker {
+					versions = append(versions, objectVersion{Marker: &s3.DeleteMarkerEntry{
+						Key:          aws.String(key),
+						VersionId:    aws.String(obj.versionID),
+						IsLatest:     isLatest,
+						LastModified: aws.Time(obj.lastModified),
+					}})
+					continue
+				}
+				versions = append(versions, objectVersion{Version: &s3.ObjectVersion{
+					Key:          aws.String(key),
+					VersionId:    aws.String(obj.versionID),
+					IsLatest:     isLatest,
+					ETag:         aws.String(obj.etag),
+					Size:         aws.Int64(int64(len(obj.data))),
+					LastModified: aws.Time(obj.lastModified),
+					StorageClass: aws.String(s3.StorageClassStandard),
+				}})
+			}
+		}
+	}
+	b.mut.RUnlock()
+
+	listObjectVersionsPages(versions, input, fn)
+	return nil
+}
+
+func (b *memBackend) Presign(input any, expires time.Duration) (string, error) {
+	return "", fmt.Errorf("presign: not supported by the mem backend")
+}
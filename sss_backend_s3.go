@@ -0,0 +1,138 @@
+package sss
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var _ Backend = (*s3Backend)(nil)
+
+// s3Backend is the Backend talking to an S3 compatible endpoint through aws-sdk-go.
+type s3Backend struct {
+	*s3.S3
+	signS3      *s3.S3
+	signMethods map[string]struct{}
+}
+
+func newS3Backend(params sssOption) (*s3Backend, error) {
+	awsConfig := aws.NewConfig()
+	if params.AccessKey != "" && params.SecretKey != "" {
+		creds := credentials.NewStaticCredentials(
+			params.AccessKey,
+			params.SecretKey,
+			params.SessionToken,
+		)
+		awsConfig.WithCredentials(creds)
+	} else {
+		awsConfig.WithCredentials(credentials.AnonymousCredentials)
+	}
+
+	if params.RegionEndpoint != "" {
+		awsConfig.WithEndpoint(params.RegionEndpoint)
+	}
+
+	awsConfig.WithRegion(params.Region)
+	awsConfig.WithS3ForcePathStyle(params.ForcePathStyle)
+	awsConfig.WithS3UseAccelerate(params.Accelerate)
+	awsConfig.WithDisableSSL(!params.Secure)
+	awsConfig.WithHTTPClient(params.HTTPClient)
+	awsConfig.WithLogLevel(params.LogLevel)
+	if params.Logger != nil && params.Logger.Enabled(context.Background(), LevelTrace) {
+		awsConfig.WithLogger(sdkLogger{params.Logger})
+		if params.LogLevel == aws.LogOff {
+			awsConfig.WithLogLevel(aws.LogDebugWithRequestRetries | aws.LogDebugWithRequestErrors)
+		}
+	}
+	if params.RetryPolicy != nil {
+		request.WithRetryer(awsConfig, sdkRetryer{params.RetryPolicy})
+		// Have the policy classify every error, not only those the SDK left undecided.
+		awsConfig.EnforceShouldRetryCheck = aws.Bool(true)
+	}
+
+	if params.UseDualStack {
+		awsConfig.UseDualStackEndpoint = endpoints.DualStackEndpointStateEnabled
+	}
+
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new session with aws config: %v", err)
+	}
+
+	if params.UserAgent != "" {
+		sess.Handlers.Build.PushBack(request.MakeAddToUserAgentFreeFormHandler(params.UserAgent))
+	}
+	limitHandlers(&sess.Handlers, newLimiter(params.UploadLimit), newLimiter(params.DownloadLimit))
+
+	b := &s3Backend{
+		S3: s3.New(sess),
+	}
+
+	if params.SignEndpoint != "" {
+		sess.Config.Endpoint = &params.SignEndpoint
+		sess.Config.S3ForcePathStyle = aws.Bool(true)
+		b.signS3 = s3.New(sess)
+		if len(params.SignEndpointMethods) != 0 {
+			b.signMethods = make(map[string]struct{})
+			for _, method := range params.SignEndpointMethods {
+				b.signMethods[strings.ToUpper(method)] = struct{}{}
+			}
+		} else {
+			b.signMethods = nil
+		}
+	}
+	return b, nil
+}
+
+func (b *s3Backend) Presign(input any, expires time.Duration) (string, error) {
+	req, err := s3Request(b.S3, input)
+	if err != nil {
+		return "", err
+	}
+	if b.signS3 == nil {
+		return req.Presign(expires)
+	}
+	if b.signMethods != nil {
+		if _, ok := b.signMethods[req.HTTPRequest.Method]; !ok {
+			return req.Presign(expires)
+		}
+	}
+	req, err = s3Request(b.signS3, input)
+	if err != nil {
+		return "", err
+	}
+	req.HTTPRequest.URL.Path = strings.TrimPrefix(req.HTTPRequest.URL.Path, "/{Bucket}")
+	return req.Presign(expires)
+}
+
+// s3Request builds the unsent request for one of the inputs accepted by Backend.Presign.
+func s3Request(c *s3.S3, input any) (*request.Request, error) {
+	var req *request.Request
+	switch input := input.(type) {
+	case *s3.GetObjectInput:
+		req, _ = c.GetObjectRequest(input)
+	case *s3.HeadObjectInput:
+		req, _ = c.HeadObjectRequest(input)
+	case *s3.PutObjectInput:
+		req, _ = c.PutObjectRequest(input)
+	case *s3.DeleteObjectInput:
+		req, _ = c.DeleteObjectRequest(input)
+	case *s3.CopyObjectInput:
+		req, _ = c.CopyObjectRequest(input)
+	case *s3.ListObjectsInput:
+		req, _ = c.ListObjectsRequest(input)
+	case *s3.UploadPartInput:
+		req, _ = c.UploadPartRequest(input)
+	default:
+		return nil, fmt.Errorf("presign: unsupported input %T", input)
+	}
+	return req, nil
+}
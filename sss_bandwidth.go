@@ -0,0 +1,188 @@
+package sss
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// maxLimiterBurst caps the bytes sent or received at once past the rate of a limiter.
+const maxLimiterBurst = 32 << 10
+
+// WithBandwidthLimit limits the bytes per second uploaded to and downloaded from the backend,
+// a limit of 0 or less leaving that direction unlimited.
+// Each limit is shared by all the concurrent requests of the SSS, e.g. the parts of a Writer and of a ParallelReader.
+func WithBandwidthLimit(upload, download int64) Option {
+	return func(p *sssOption) error {
+		p.UploadLimit = upload
+		p.DownloadLimit = download
+		return nil
+	}
+}
+
+// limiter is a token bucket shared by the bodies it throttles.
+type limiter struct {
+	rate  float64
+	burst int
+
+	mut    sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(rate int64) *limiter {
+	if rate <= 0 {
+		return nil
+	}
+	burst := int(min(rate, maxLimiterBurst))
+	return &limiter{
+		rate:   float64(rate),
+		burst:  burst,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// wait takes n tokens, waiting until the bucket has refilled the ones taken in advance.
+func (l *limiter) wait(ctx context.Context, n int) error {
+	l.mut.Lock()
+	now := time.Now()
+	l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*l.rate, float64(l.burst))
+	l.last = now
+	l.tokens -= float64(n)
+	d := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mut.Unlock()
+	if d <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// limitedBody throttles the reads of an HTTP body.
+type limitedBody struct {
+	ctx context.Context
+	l   *limiter
+	io.ReadCloser
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if len(p) > b.l.burst {
+		p = p[:b.l.burst]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		if werr := b.l.wait(b.ctx, n); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return n, err
+}
+
+// limitedSeeker throttles the reads of the body of an upload to a backend without HTTP.
+type limitedSeeker struct {
+	ctx context.Context
+	l   *limiter
+	io.ReadSeeker
+}
+
+func (b *limitedSeeker) Read(p []byte) (int, error) {
+	if len(p) > b.l.burst {
+		p = p[:b.l.burst]
+	}
+	n, err := b.ReadSeeker.Read(p)
+	if n > 0 {
+		if werr := b.l.wait(b.ctx, n); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return n, err
+}
+
+// limitedBackend throttles the bodies given to and returned by a backend without HTTP,
+// whose requests don't go through limitHandlers.
+type limitedBackend struct {
+	Backend
+	upload   *limiter
+	download *limiter
+}
+
+func limitBackend(backend Backend, upload, download *limiter) Backend {
+	if upload == nil && download == nil {
+		return backend
+	}
+	return &limitedBackend{
+		Backend:  backend,
+		upload:   upload,
+		download: download,
+	}
+}
+
+func (b *limitedBackend) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	if b.upload != nil && input.Body != nil {
+		in := *input
+		in.Body = &limitedSeeker{ctx: ctx, l: b.upload, ReadSeeker: input.Body}
+		input = &in
+	}
+	return b.Backend.PutObjectWithContext(ctx, input, opts...)
+}
+
+func (b *limitedBackend) UploadPartWithContext(ctx aws.Context, input *s3.UploadPartInput, opts ...request.Option) (*s3.UploadPartOutput, error) {
+	if b.upload != nil && input.Body != nil {
+		in := *input
+		in.Body = &limitedSeeker{ctx: ctx, l: b.upload, ReadSeeker: input.Body}
+		input = &in
+	}
+	return b.Backend.UploadPartWithContext(ctx, input, opts...)
+}
+
+func (b *limitedBackend) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	out, err := b.Backend.GetObjectWithContext(ctx, input, opts...)
+	if err != nil || b.download == nil || out.Body == nil {
+		return out, err
+	}
+	out.Body = &limitedBody{ctx: ctx, l: b.download, ReadCloser: out.Body}
+	return out, nil
+}
+
+// limitHandlers throttles the bodies sent and received by the requests of the handlers.
+// The bodies are wrapped while they are sent rather than when they are given to the SDK,
+// which reads the body of an upload once more to sign it.
+func limitHandlers(handlers *request.Handlers, upload, download *limiter) {
+	if upload != nil {
+		handlers.Send.PushFrontNamed(request.NamedHandler{
+			Name: "sss.LimitUpload",
+			Fn: func(r *request.Request) {
+				body := r.HTTPRequest.Body
+				if body == nil || body == http.NoBody {
+					return
+				}
+				r.HTTPRequest.Body = &limitedBody{ctx: r.Context(), l: upload, ReadCloser: body}
+			},
+		})
+	}
+	if download != nil {
+		handlers.Send.PushBackNamed(request.NamedHandler{
+			Name: "sss.LimitDownload",
+			Fn: func(r *request.Request) {
+				if r.HTTPResponse == nil || r.HTTPResponse.Body == nil {
+					return
+				}
+				r.HTTPResponse.Body = &limitedBody{ctx: r.Context(), l: download, ReadCloser: r.HTTPResponse.Body}
+			},
+		})
+	}
+}
@@ -0,0 +1,546 @@
+package sss
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const (
+	// cacheBlockSize is the size of the blocks objects are cached in.
+	cacheBlockSize = 4 * 1024 * 1024
+
+	// defaultCacheSize is the size of the cache of the cache URL parameter without cachesize.
+	defaultCacheSize = 10 * 1024 * 1024 * 1024
+
+	cacheTempPrefix = "tmp-"
+)
+
+// WithCache caches the objects read in blocks under dir, keeping at most maxBytes of them,
+// the least recently used blocks being evicted first.
+// Every read checks the ETag of the object with a HEAD request, answered by the metadata cache
+// when WithMetadataCache is used, and reuses the blocks cached for it, fetching the others
+// with ranged GETs while they are read. An ETag of the metadata cache found stale is checked again
+// with the backend. An object overwritten while read without WithETag fails with io.ErrUnexpectedEOF,
+// since the blocks it has already returned can't be completed. Reads with an SSE-C key are not cached.
+// A directory should be used by one SSS at a time.
+func WithCache(dir string, maxBytes int64) Option {
+	return func(p *sssOption) error {
+		p.CacheDir = dir
+		p.CacheSize = maxBytes
+		return nil
+	}
+}
+
+// diskCache is the index of the blocks cached in a directory.
+type diskCache struct {
+	dir      string
+	maxBytes int64
+
+	mut    sync.Mutex
+	size   int64
+	lru    *list.List
+	blocks map[string]*list.Element
+}
+
+type cacheBlock struct {
+	name string
+	size int64
+}
+
+// openDiskCache indexes the blocks left in dir, the most recently modified first.
+func openDiskCache(dir string, maxBytes int64) (*diskCache, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("invalid cache size %d", maxBytes)
+	}
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type found struct {
+		cacheBlock
+		info fs.FileInfo
+	}
+	var blocks []found
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if strings.HasPrefix(entry.Name(), cacheTempPrefix) {
+			// Left by an interrupted fetch.
+			os.Remove(filepath.Join(dir, entry.Name()))
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		blocks = append(blocks, found{cacheBlock{entry.Name(), info.Size()}, info})
+	}
+	// Oldest first, so that each is pushed in front of the older ones.
+	slices.SortFunc(blocks, func(a, b found) int {
+		return a.info.ModTime().Compare(b.info.ModTime())
+	})
+
+	c := &diskCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		blocks:   map[string]*list.Element{},
+	}
+	for _, b := range blocks {
+		c.add(b.cacheBlock)
+	}
+	c.mut.Lock()
+	c.evict()
+	c.mut.Unlock()
+	return c, nil
+}
+
+// blockName returns the file name of a block of the object with etag.
+func blockName(bucket, key, etag string, index int64) string {
+	sum := sha256.Sum256([]byte(bucket + "\x00" + key + "\x00" + strings.Trim(etag, `"`)))
+	return hex.EncodeToString(sum[:]) + "-" + strconv.FormatInt(index, 10)
+}
+
+// open returns the cached block, marking it as the most recently used.
+func (c *diskCache) open(name string) (*os.File, bool) {
+	c.mut.Lock()
+	e, ok := c.blocks[name]
+	if ok {
+		c.lru.MoveToFront(e)
+	}
+	c.mut.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	path := filepath.Join(c.dir, name)
+	f, err := os.Open(path)
+	if err != nil {
+		c.remove(name)
+		return nil, false
+	}
+	// Keep the order of use for the next openDiskCache.
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return f, true
+}
+
+// has reports whether the block is cached.
+func (c *diskCache) has(name string) bool {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	_, ok := c.blocks[name]
+	return ok
+}
+
+// create returns a temporary file to fill with a block before committing it.
+func (c *diskCache) create() (*os.File, error) {
+	return os.CreateTemp(c.dir, cacheTempPrefix+"*")
+}
+
+// commit caches the temporary file tmp filled with the block of the given size.
+func (c *diskCache) commit(tmp *os.File, name string, size int64) error {
+	fi, err := tmp.Stat()
+	if err == nil && fi.Size() != size {
+		err = io.ErrUnexpectedEOF
+	}
+	if err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(c.dir, name))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	c.add(cacheBlock{name, size})
+	c.mut.Lock()
+	c.evict()
+	c.mut.Unlock()
+	return nil
+}
+
+// discard removes the temporary file tmp.
+func (c *diskCache) discard(tmp *os.File) {
+	tmp.Close()
+	os.Remove(tmp.Name())
+}
+
+func (c *diskCache) add(b cacheBlock) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if e, ok := c.blocks[b.name]; ok {
+		c.size -= e.Value.(cacheBlock).size
+		c.lru.Remove(e)
+	}
+	c.blocks[b.name] = c.lru.PushFront(b)
+	c.size += b.size
+}
+
+func (c *diskCache) remove(name string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if e, ok := c.blocks[name]; ok {
+		c.size -= e.Value.(cacheBlock).size
+		c.lru.Remove(e)
+		delete(c.blocks, name)
+	}
+}
+
+// evict removes the least recently used blocks beyond maxBytes, c.mut must be held.
+func (c *diskCache) evict() {
+	for c.size > c.maxBytes {
+		e := c.lru.Back()
+		b := e.Value.(cacheBlock)
+		c.size -= b.size
+		c.lru.Remove(e)
+		delete(c.blocks, b.name)
+		os.Remove(filepath.Join(c.dir, b.name))
+	}
+}
+
+// cachedBackend reads objects through a diskCache.
+type cachedBackend struct {
+	Backend
+	cache *diskCache
+
+	// metadata answers the HEAD requests revalidating the cached blocks when there is one,
+	// its ETags are dropped once found stale.
+	metadata *metadataCachedBackend
+}
+
+func cacheBackend(backend Backend, cache *diskCache) *cachedBackend {
+	return &cachedBackend{
+		Backend: backend,
+		cache:   cache,
+	}
+}
+
+func (b *cachedBackend) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	if input.SSECustomerKey != nil || input.PartNumber != nil || input.IfNoneMatch != nil ||
+		input.IfModifiedSince != nil || input.IfUnmodifiedSince != nil {
+		return b.Backend.GetObjectWithContext(ctx, input, opts...)
+	}
+
+	if b.metadata != nil {
+		out, err := b.getObject(ctx, b.metadata, input, opts)
+		if !isPreconditionFailed(err) {
+			return out, err
+		}
+		// The ETag of the metadata cache may be stale, ask the backend.
+		b.metadata.invalidate(aws.StringValue(input.Key))
+	}
+	out, err := b.getObject(ctx, b.Backend, input, opts)
+	if isPreconditionFailed(err) && input.IfMatch == nil {
+		// Overwritten since the HEAD, read it without the cache as it is now.
+		return b.Backend.GetObjectWithContext(ctx, input, opts...)
+	}
+	return out, err
+}
+
+// getObject reads through the cache the object as heads sees it,
+// failing with a PreconditionFailed error if the backend has another ETag.
+func (b *cachedBackend) getObject(ctx aws.Context, heads Backend, input *s3.GetObjectInput, opts []request.Option) (*s3.GetObjectOutput, error) {
+	head, err := heads.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket:    input.Bucket,
+		Key:       input.Key,
+		VersionId: input.VersionId,
+	}, opts...)
+	if err != nil || head.ETag == nil || head.ContentLength == nil {
+		// Let GetObject fail the way it does.
+		return b.Backend.GetObjectWithContext(ctx, input, opts...)
+	}
+	key := aws.StringValue(input.Key)
+	etag := aws.StringValue(head.ETag)
+	if input.IfMatch != nil && !etagMatch(*input.IfMatch, etag) {
+		return nil, errPreconditionFailed(key)
+	}
+
+	size := *head.ContentLength
+	out := &s3.GetObjectOutput{
+		AcceptRanges:         aws.String("bytes"),
+		CacheControl:         head.CacheControl,
+		ContentDisposition:   head.ContentDisposition,
+		ContentEncoding:      head.ContentEncoding,
+		ContentLanguage:      head.ContentLanguage,
+		ContentLength:        aws.Int64(size),
+		ContentType:          head.ContentType,
+		ETag:                 head.ETag,
+		Expires:              head.Expires,
+		LastModified:         head.LastModified,
+		Metadata:             head.Metadata,
+		ServerSideEncryption: head.ServerSideEncryption,
+		SSEKMSKeyId:          head.SSEKMSKeyId,
+		StorageClass:         head.StorageClass,
+		VersionId:            head.VersionId,
+	}
+	start, end := int64(0), size-1
+	if input.Range != nil {
+		start, end, err = parseRange(*input.Range, size)
+		if err != nil {
+			return nil, err
+		}
+		out.ContentLength = aws.Int64(end - start + 1)
+		out.ContentRange = aws.String(contentRange(start, end, size))
+	}
+
+	body := &cachedBody{
+		ctx:     ctx,
+		backend: b,
+		input:   input,
+		opts:    opts,
+		etag:    etag,
+		size:    size,
+		offset:  start,
+		end:     end + 1,
+	}
+	if body.offset < body.end {
+		// Open the first block now, so that an ETag found stale fails here rather than on Read.
+		err = body.open()
+		if err != nil {
+			return nil, err
+		}
+	}
+	out.Body = body
+	return out, nil
+}
+
+func isPreconditionFailed(err error) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && awsErr.Code() == "PreconditionFailed"
+}
+
+// cachedBody reads the bytes of an object from offset to end, block by block.
+// The blocks missing from the cache are fetched with a single ranged GET up to the next cached one,
+// and are returned while being saved.
+type cachedBody struct {
+	ctx     aws.Context
+	backend *cachedBackend
+	input   *s3.GetObjectInput
+	opts    []request.Option
+	etag    string
+	size    int64
+
+	offset int64
+	end    int64
+
+	// block is the cached block being read, with left bytes to read up to end.
+	block *os.File
+	left  int64
+
+	// stream is the body of the GET of the missing blocks, at streamOffset up to streamEnd,
+	// which is saved into fill until the block being fetched is complete.
+	stream       io.ReadCloser
+	streamOffset int64
+	streamEnd    int64
+	fill         *os.File
+}
+
+func (r *cachedBody) Read(p []byte) (int, error) {
+	if r.offset >= r.end {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if r.block == nil && r.stream == nil {
+		err := r.open()
+		if isPreconditionFailed(err) && r.input.IfMatch == nil {
+			// Overwritten while being read, what was read can't be completed.
+			if r.backend.metadata != nil {
+				r.backend.metadata.invalidate(aws.StringValue(r.input.Key))
+			}
+			return 0, fmt.Errorf("%s was overwritten while being read: %w", aws.StringValue(r.input.Key), io.ErrUnexpectedEOF)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	if r.stream != nil {
+		return r.readStream(p)
+	}
+
+	if int64(len(p)) > r.left {
+		p = p[:r.left]
+	}
+	n, err := r.block.Read(p)
+	r.offset += int64(n)
+	r.left -= int64(n)
+	if r.left == 0 || err != nil {
+		r.block.Close()
+		r.block = nil
+		if err == io.EOF {
+			if r.left != 0 {
+				return n, io.ErrUnexpectedEOF
+			}
+			err = nil
+		}
+	}
+	return n, err
+}
+
+func (r *cachedBody) blockName(index int64) string {
+	return blockName(aws.StringValue(r.input.Bucket), aws.StringValue(r.input.Key), r.etag, index)
+}
+
+// open opens the block holding offset if it is cached,
+// otherwise it starts fetching it along with the missing blocks after it.
+func (r *cachedBody) open() error {
+	index := r.offset / cacheBlockSize
+	blockStart := index * cacheBlockSize
+	blockEnd := min(blockStart+cacheBlockSize, r.size)
+
+	f, ok := r.backend.cache.open(r.blockName(index))
+	if ok {
+		_, err := f.Seek(r.offset-blockStart, io.SeekStart)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		r.block = f
+		r.left = min(blockEnd, r.end) - r.offset
+		return nil
+	}
+
+	streamEnd := blockEnd
+	for streamEnd < r.end && !r.backend.cache.has(r.blockName(streamEnd/cacheBlockSize)) {
+		streamEnd = min(streamEnd+cacheBlockSize, r.size)
+	}
+	out, err := r.backend.Backend.GetObjectWithContext(r.ctx, &s3.GetObjectInput{
+		Bucket:    r.input.Bucket,
+		Key:       r.input.Key,
+		VersionId: r.input.VersionId,
+		IfMatch:   aws.String(r.etag),
+		Range:     aws.String("bytes=" + strconv.FormatInt(blockStart, 10) + "-" + strconv.FormatInt(streamEnd-1, 10)),
+	}, r.opts...)
+	if err != nil {
+		return err
+	}
+	r.stream = out.Body
+	r.streamOffset = blockStart
+	r.streamEnd = streamEnd
+	return nil
+}
+
+// readStream reads from the stream the bytes at offset, skipping those before it in their block.
+func (r *cachedBody) readStream(p []byte) (int, error) {
+	for r.streamOffset < r.offset {
+		_, err := r.fetch(p[:min(int64(len(p)), r.offset-r.streamOffset)])
+		if err != nil {
+			r.closeStream()
+			return 0, err
+		}
+	}
+	n, err := r.fetch(p[:min(int64(len(p)), r.end-r.offset)])
+	r.offset += int64(n)
+	if err != nil {
+		r.closeStream()
+	}
+	return n, err
+}
+
+// fetch reads the next bytes of the stream into p, at most up to the end of their block,
+// saving them into fill and caching the block once complete.
+func (r *cachedBody) fetch(p []byte) (int, error) {
+	index := r.streamOffset / cacheBlockSize
+	blockStart := index * cacheBlockSize
+	blockEnd := min(blockStart+cacheBlockSize, r.size)
+	if int64(len(p)) > blockEnd-r.streamOffset {
+		p = p[:blockEnd-r.streamOffset]
+	}
+	if r.fill == nil {
+		f, err := r.backend.cache.create()
+		if err != nil {
+			return 0, err
+		}
+		r.fill = f
+	}
+
+	n, err := r.stream.Read(p)
+	if n > 0 {
+		_, werr := r.fill.Write(p[:n])
+		if werr != nil {
+			return 0, werr
+		}
+		r.streamOffset += int64(n)
+	}
+	if r.streamOffset == blockEnd {
+		fill := r.fill
+		r.fill = nil
+		cerr := r.backend.cache.commit(fill, r.blockName(index), blockEnd-blockStart)
+		if r.streamOffset == r.streamEnd {
+			r.stream.Close()
+			r.stream = nil
+			if err == io.EOF {
+				err = nil
+			}
+		}
+		if err == nil {
+			err = cerr
+		}
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// closeStream stops fetching, dropping the block being fetched.
+func (r *cachedBody) closeStream() {
+	if r.fill != nil {
+		r.backend.cache.discard(r.fill)
+		r.fill = nil
+	}
+	if r.stream != nil {
+		r.stream.Close()
+		r.stream = nil
+	}
+}
+
+func (r *cachedBody) Close() error {
+	if r.stream != nil && r.offset >= r.end {
+		// Finish the last block fetched, past the end of what was read, so that it is cached.
+		buf := make([]byte, 32<<10)
+		for r.stream != nil {
+			_, err := r.fetch(buf)
+			if err != nil {
+				break
+			}
+		}
+	}
+	r.closeStream()
+	r.offset = r.end
+	if r.block == nil {
+		return nil
+	}
+	err := r.block.Close()
+	r.block = nil
+	return err
+}
@@ -2,32 +2,151 @@ package sss
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/s3"
 )
 
 func (s *SSS) SignCopy(ctx context.Context, sourcePath, destPath string, expires time.Duration) (string, error) {
-	return s.presign(expires,
-		func(c *s3.S3) *request.Request {
-			req, _ := c.CopyObjectRequest(&s3.CopyObjectInput{
-				Bucket:               s.getBucket(),
-				Key:                  aws.String(s.s3Path(destPath)),
-				ContentType:          s.getContentType(),
-				ACL:                  s.getACL(),
-				ServerSideEncryption: s.getEncryptionMode(),
-				SSEKMSKeyId:          s.getSSEKMSKeyID(),
-				StorageClass:         s.getStorageClass(),
-				CopySource:           aws.String(s.bucket + "/" + s.s3Path(sourcePath)),
-			})
-			return req
-		})
+	return s.presign(expires, &s3.CopyObjectInput{
+		Bucket:               s.getBucket(),
+		Key:                  aws.String(s.s3Path(destPath)),
+		ContentType:          s.getContentType(),
+		ACL:                  s.getACL(),
+		ServerSideEncryption: s.getEncryptionMode(),
+		SSEKMSKeyId:          s.getSSEKMSKeyID(),
+		StorageClass:         s.getStorageClass(),
+		SSECustomerAlgorithm: s.getSSECustomerAlgorithm(),
+		SSECustomerKey:       s.getSSECustomerKey(),
+		CopySource:           aws.String(s.copySource(sourcePath, "")),
+
+		CopySourceSSECustomerAlgorithm: s.getSSECustomerAlgorithm(),
+		CopySourceSSECustomerKey:       s.getSSECustomerKey(),
+	})
+}
+
+// Copy copies the object at sourcePath to destPath on the server side.
+// Objects larger than the multipart copy threshold are copied part by part.
+// The content type, content disposition, metadata and tags of the source are preserved,
+// unless replaced with WithContentType, WithContentDisposition, WithMetadata and WithTags.
+func (s *SSS) Copy(ctx context.Context, sourcePath, destPath string, opts ...WriterOptions) error {
+	var o writerOption
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return s.copyFrom(ctx, s, sourcePath, "", destPath, o)
 }
 
-func (s *SSS) Copy(ctx context.Context, sourcePath, destPath string) error {
-	_, err := s.s3.CopyObjectWithContext(ctx, &s3.CopyObjectInput{
+// CopyBetween copies the object at srcPath of src to dstPath of dst.
+// It copies on the server side when both use the same storage, credentials and client-side encryption,
+// otherwise it streams the object through a Writer of dst.
+// opts are applied as they are by Copy.
+func CopyBetween(ctx context.Context, src *SSS, srcPath string, dst *SSS, dstPath string, opts ...WriterOptions) error {
+	var o writerOption
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if src.sameStorage(dst) && src.cse.equal(dst.cse) {
+		err := dst.copyFrom(ctx, src, srcPath, "", dstPath, o)
+		if !errors.Is(err, ErrPermission) {
+			return err
+		}
+		// The credentials of dst can't read the source, fall back to streaming.
+	}
+
+	r, info, err := src.ReaderAndInfo(ctx, srcPath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	// Keep what opts don't replace.
+	if fie, ok := info.Sys().(FileInfoExpansion); ok {
+		if o.ContentType == "" && fie.ContentType != nil {
+			opts = append(opts, WithContentType(*fie.ContentType))
+		}
+		if o.ContentDisposition == "" && fie.ContentDisposition != nil {
+			opts = append(opts, WithContentDisposition(*fie.ContentDisposition))
+		}
+		if o.Metadata == nil && fie.Metadata != nil {
+			opts = append(opts, WithMetadata(fie.Metadata))
+		}
+	}
+	if o.Tagging == nil {
+		tags, err := src.copyTags(ctx, srcPath, "")
+		if err != nil {
+			return err
+		}
+		if len(tags) != 0 {
+			opts = append(opts, WithTags(tags))
+		}
+	}
+
+	w, err := dst.Writer(ctx, dstPath, opts...)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	_, err = io.Copy(w, r)
+	if err != nil {
+		w.Cancel(context.WithoutCancel(ctx))
+		return err
+	}
+	err = w.Commit(ctx)
+	if err != nil {
+		w.Cancel(context.WithoutCancel(ctx))
+		return err
+	}
+	return nil
+}
+
+// sameStorage reports whether a server-side copy from s to other can work.
+func (s *SSS) sameStorage(other *SSS) bool {
+	if s.s3 != nil && other.s3 != nil {
+		return s.s3.Endpoint == other.s3.Endpoint && s.accessKey == other.accessKey
+	}
+	return s.origin == other.origin
+}
+
+// copyFrom copies sourcePath of src to destPath of s on the server side,
+// both must be in the same storage. An empty versionID copies the latest version.
+func (s *SSS) copyFrom(ctx context.Context, src *SSS, sourcePath, versionID, destPath string, o writerOption) error {
+	version := objectOption{VersionID: versionID}
+	head, err := src.backend.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket:               src.getBucket(),
+		Key:                  aws.String(src.s3Path(sourcePath)),
+		VersionId:            version.versionID(),
+		SSECustomerAlgorithm: src.getSSECustomerAlgorithm(),
+		SSECustomerKey:       src.getSSECustomerKey(),
+	})
+	if err != nil {
+		return parseError(sourcePath, err)
+	}
+	if aws.Int64Value(head.ContentLength) > s.multipartCopyThreshold {
+		if o.Tagging == nil {
+			// Unlike CopyObject, a multipart upload doesn't carry the tags over.
+			tags, err := src.copyTags(ctx, sourcePath, versionID)
+			if err != nil {
+				return err
+			}
+			if len(tags) != 0 {
+				o.Tagging = aws.String(encodeTags(tags))
+			}
+		}
+		return parseError(sourcePath, s.copyMultipart(ctx, src, sourcePath, versionID, head, destPath, o))
+	}
+
+	input := &s3.CopyObjectInput{
 		Bucket:               s.getBucket(),
 		Key:                  aws.String(s.s3Path(destPath)),
 		ContentType:          s.getContentType(),
@@ -35,11 +154,175 @@ func (s *SSS) Copy(ctx context.Context, sourcePath, destPath string) error {
 		ServerSideEncryption: s.getEncryptionMode(),
 		SSEKMSKeyId:          s.getSSEKMSKeyID(),
 		StorageClass:         s.getStorageClass(),
-		CopySource:           aws.String(s.bucket + "/" + s.s3Path(sourcePath)),
-	})
+		SSECustomerAlgorithm: s.getSSECustomerAlgorithm(),
+		SSECustomerKey:       s.getSSECustomerKey(),
+		CopySource:           aws.String(src.copySource(sourcePath, versionID)),
+
+		CopySourceSSECustomerAlgorithm: src.getSSECustomerAlgorithm(),
+		CopySourceSSECustomerKey:       src.getSSECustomerKey(),
+	}
+	if o.ContentType != "" || o.ContentDisposition != "" || o.Metadata != nil {
+		// Replacing any of them replaces all, so carry over those kept from the source.
+		input.MetadataDirective = aws.String(s3.MetadataDirectiveReplace)
+		input.ContentType, input.ContentDisposition, input.Metadata = s.copyMetadata(head, o)
+	}
+	if o.Tagging != nil {
+		input.TaggingDirective = aws.String(s3.TaggingDirectiveReplace)
+		input.Tagging = o.Tagging
+	}
+	_, err = s.backend.CopyObjectWithContext(ctx, input)
 	if err != nil {
 		return parseError(sourcePath, err)
 	}
 	return nil
+}
+
+// copyMetadata returns the content type, content disposition and metadata of a copy
+// of the object described by head, those set in o taking precedence.
+func (s *SSS) copyMetadata(head *s3.HeadObjectOutput, o writerOption) (contentType, contentDisposition *string, metadata map[string]*string) {
+	contentType = head.ContentType
+	if o.ContentType != "" {
+		contentType = aws.String(o.ContentType)
+	}
+	if contentType == nil {
+		contentType = s.getContentType()
+	}
+	contentDisposition = head.ContentDisposition
+	if o.ContentDisposition != "" {
+		contentDisposition = aws.String(o.ContentDisposition)
+	}
+	metadata = head.Metadata
+	if o.Metadata != nil {
+		metadata = o.Metadata
+		// The parameters of client-side encryption belong to the content, which is copied as it is.
+		if params := cseParams(head.Metadata); params != nil {
+			metadata = withMetadata(metadata, aws.StringMap(params))
+		}
+	}
+	return contentType, contentDisposition, metadata
+}
+
+// copyMultipart copies the object described by head from sourcePath of src to destPath
+// with concurrent UploadPartCopy requests, keeping the metadata of the source
+// unless replaced in o.
+func (s *SSS) copyMultipart(ctx context.Context, src *SSS, sourcePath, versionID string, head *s3.HeadObjectOutput, destPath string, o writerOption) error {
+	size := aws.Int64Value(head.ContentLength)
+	partSize := min(s.multipartCopyThreshold, defaultCopyPartSize)
+	partSize = max(partSize, minPartSize, (size+maxParts-1)/maxParts)
 
+	contentType, contentDisposition, metadata := s.copyMetadata(head, o)
+	resp, err := s.backend.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:               s.getBucket(),
+		Key:                  aws.String(s.s3Path(destPath)),
+		ContentType:          contentType,
+		ContentDisposition:   contentDisposition,
+		ContentEncoding:      head.ContentEncoding,
+		ContentLanguage:      head.ContentLanguage,
+		CacheControl:         head.CacheControl,
+		Metadata:             metadata,
+		Tagging:              o.Tagging,
+		SSECustomerAlgorithm: s.getSSECustomerAlgorithm(),
+		SSECustomerKey:       s.getSSECustomerKey(),
+		ACL:                  s.getACL(),
+		ServerSideEncryption: s.getEncryptionMode(),
+		SSEKMSKeyId:          s.getSSEKMSKeyID(),
+		StorageClass:         s.getStorageClass(),
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	parts := make(s3completedParts, (size+partSize-1)/partSize)
+	sem := make(chan struct{}, copyConcurrency)
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var copyErr error
+	for i := range parts {
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			<-sem
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			start := int64(i) * partSize
+			end := min(start+partSize, size) - 1
+			partNumber := aws.Int64(int64(i) + 1)
+			out, err := s.backend.UploadPartCopyWithContext(ctx, &s3.UploadPartCopyInput{
+				Bucket:               s.getBucket(),
+				Key:                  resp.Key,
+				UploadId:             resp.UploadId,
+				PartNumber:           partNumber,
+				SSECustomerAlgorithm: s.getSSECustomerAlgorithm(),
+				SSECustomerKey:       s.getSSECustomerKey(),
+				CopySource:           aws.String(src.copySource(sourcePath, versionID)),
+				CopySourceRange:      aws.String("bytes=" + strconv.FormatInt(start, 10) + "-" + strconv.FormatInt(end, 10)),
+
+				CopySourceSSECustomerAlgorithm: src.getSSECustomerAlgorithm(),
+				CopySourceSSECustomerKey:       src.getSSECustomerKey(),
+			})
+			if err != nil {
+				errOnce.Do(func() {
+					copyErr = fmt.Errorf("upload part copy %d: %w", *partNumber, err)
+					cancel()
+				})
+				return
+			}
+			parts[i] = &s3.CompletedPart{
+				ETag:       out.CopyPartResult.ETag,
+				PartNumber: partNumber,
+			}
+		}(i)
+	}
+	wg.Wait()
+	if copyErr == nil {
+		copyErr = ctx.Err()
+	}
+
+	if copyErr == nil {
+		_, copyErr = s.backend.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:   s.getBucket(),
+			Key:      resp.Key,
+			UploadId: resp.UploadId,
+			MultipartUpload: &s3.CompletedMultipartUpload{
+				Parts: parts,
+			},
+		})
+		if copyErr == nil {
+			return nil
+		}
+	}
+
+	_, err = s.backend.AbortMultipartUploadWithContext(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+		Bucket:   s.getBucket(),
+		Key:      resp.Key,
+		UploadId: resp.UploadId,
+	})
+	if err != nil {
+		return errors.Join(copyErr, err)
+	}
+	return copyErr
+}
+
+// copySource returns the URL-encoded x-amz-copy-source of versionID of path,
+// or of its latest version if versionID is empty.
+func (s *SSS) copySource(path, versionID string) string {
+	segments := strings.Split(s.bucket+"/"+s.s3Path(path), "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(url.QueryEscape(segment), "+", "%20")
+	}
+	source := strings.Join(segments, "/")
+	if versionID != "" {
+		source += "?versionId=" + url.QueryEscape(versionID)
+	}
+	return source
 }
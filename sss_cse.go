@@ -0,0 +1,602 @@
+package sss
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Client-side encryption stores an object as a sequence of segments, each holding
+// segmentSize bytes of the content, the last one fewer, sealed with AES-256-GCM
+// under a random data key of the object. The data key is kept in the metadata of the
+// object, sealed with the key named there, so any range of the content can be read
+// by fetching and opening only the segments holding it.
+//
+// The nonce of a segment is its index, with a flag on the last one so that
+// segments can neither be reordered nor dropped from the end.
+
+const (
+	// cseAlgorithm names the segment layout and cipher of the objects written.
+	cseAlgorithm = "AES-256-GCM-SEGMENTED"
+
+	// cseKeySize is the size of the keys and of the data keys of the objects
+	cseKeySize = 32
+
+	// defaultCSESegmentSize is the content held by each segment
+	defaultCSESegmentSize = 64 * 1024
+
+	// cseMetadataPrefix starts the metadata names holding the encryption parameters,
+	// which are hidden from FileInfoExpansion.Metadata.
+	cseMetadataPrefix      = "sss-cse-"
+	cseMetadataAlgorithm   = cseMetadataPrefix + "algorithm"
+	cseMetadataKeyID       = cseMetadataPrefix + "key-id"
+	cseMetadataDataKey     = cseMetadataPrefix + "data-key"
+	cseMetadataSegmentSize = cseMetadataPrefix + "segment-size"
+)
+
+// WithClientEncryption encrypts the content of the objects before it leaves the process
+// with the 256-bit key named keyID, and decrypts it on read. The key id is stored with
+// each object so that the key can be rotated with WithClientKeyring.
+// Sizes from List and Walk are those of the stored objects, Stat gives those of the content,
+// and the holders of pre-signed URLs get the encrypted content.
+func WithClientEncryption(keyID string, key []byte) Option {
+	return func(p *sssOption) error {
+		if keyID == "" {
+			return fmt.Errorf("invalid client encryption key: empty key id")
+		}
+		if len(key) != cseKeySize {
+			return fmt.Errorf("invalid client encryption key %q: must be %d bytes, got %d", keyID, cseKeySize, len(key))
+		}
+		if p.ClientKeys == nil {
+			p.ClientKeys = map[string][]byte{}
+		}
+		p.ClientKeyID = keyID
+		p.ClientKeys[keyID] = key
+		return nil
+	}
+}
+
+// WithClientKeyring adds 256-bit keys, by key id, to decrypt the objects written with
+// WithClientEncryption, such as those of retired keys.
+// Without WithClientEncryption objects are read with them but written unencrypted.
+func WithClientKeyring(keys map[string][]byte) Option {
+	return func(p *sssOption) error {
+		if p.ClientKeys == nil {
+			p.ClientKeys = map[string][]byte{}
+		}
+		for keyID, key := range keys {
+			if len(key) != cseKeySize {
+				return fmt.Errorf("invalid client encryption key %q: must be %d bytes, got %d", keyID, cseKeySize, len(key))
+			}
+			p.ClientKeys[keyID] = key
+		}
+		return nil
+	}
+}
+
+// WithClientSegmentSize sets the content held by each encrypted segment,
+// the smallest unit fetched by ranged reads.
+func WithClientSegmentSize(size int) Option {
+	return func(p *sssOption) error {
+		if size <= 0 {
+			return fmt.Errorf("invalid client segment size: %d", size)
+		}
+		p.ClientSegmentSize = size
+		return nil
+	}
+}
+
+// clientEncryption holds the keys of client-side encryption.
+type clientEncryption struct {
+	keyID       string
+	keys        map[string][]byte
+	ciphers     map[string]cipher.AEAD
+	segmentSize int
+}
+
+func newClientEncryption(keyID string, keys map[string][]byte, segmentSize int) (*clientEncryption, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	if segmentSize <= 0 {
+		segmentSize = defaultCSESegmentSize
+	}
+	c := &clientEncryption{
+		keyID:       keyID,
+		keys:        keys,
+		ciphers:     make(map[string]cipher.AEAD, len(keys)),
+		segmentSize: segmentSize,
+	}
+	for id, key := range keys {
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		c.ciphers[id] = aead
+	}
+	return c, nil
+}
+
+// equal reports whether objects written with c are read the same with other.
+func (c *clientEncryption) equal(other *clientEncryption) bool {
+	if c == nil || other == nil {
+		return c == other
+	}
+	if c.keyID != other.keyID || c.segmentSize != other.segmentSize || len(c.keys) != len(other.keys) {
+		return false
+	}
+	for id, key := range c.keys {
+		if !bytes.Equal(key, other.keys[id]) {
+			return false
+		}
+	}
+	return true
+}
+
+// encrypting reports whether the objects written are encrypted.
+func (c *clientEncryption) encrypting() bool {
+	return c != nil && c.keyID != ""
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// newObject returns the cipher of the segments of a new object
+// and the metadata to store with it.
+func (c *clientEncryption) newObject() (*segmentCipher, map[string]*string, error) {
+	dataKey := make([]byte, cseKeySize)
+	_, err := rand.Read(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	kek := c.ciphers[c.keyID]
+	nonce := make([]byte, kek.NonceSize(), kek.NonceSize()+cseKeySize+kek.Overhead())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+	sealed := kek.Seal(nonce, nonce, dataKey, []byte(c.keyID))
+
+	metadata := map[string]*string{
+		cseMetadataAlgorithm:   aws.String(cseAlgorithm),
+		cseMetadataKeyID:       aws.String(c.keyID),
+		cseMetadataDataKey:     aws.String(base64.StdEncoding.EncodeToString(sealed)),
+		cseMetadataSegmentSize: aws.String(strconv.Itoa(c.segmentSize)),
+	}
+	return &segmentCipher{aead: aead, segmentSize: c.segmentSize}, metadata, nil
+}
+
+// withMetadata returns metadata with the encryption parameters added, leaving metadata unchanged.
+func withMetadata(metadata, params map[string]*string) map[string]*string {
+	m := make(map[string]*string, len(metadata)+len(params))
+	for k, v := range metadata {
+		m[k] = v
+	}
+	for k, v := range params {
+		m[k] = v
+	}
+	return m
+}
+
+// cseParams returns the encryption parameters in the metadata of an object,
+// or nil if the object is not encrypted.
+func cseParams(metadata map[string]*string) map[string]string {
+	var params map[string]string
+	for k, v := range metadata {
+		k = strings.ToLower(k)
+		if strings.HasPrefix(k, cseMetadataPrefix) {
+			if params == nil {
+				params = map[string]string{}
+			}
+			params[k] = aws.StringValue(v)
+		}
+	}
+	if params[cseMetadataAlgorithm] == "" {
+		return nil
+	}
+	return params
+}
+
+// cseSegmentSize returns the segment size of the parameters of an object.
+func cseSegmentSize(params map[string]string) (int, error) {
+	if params[cseMetadataAlgorithm] != cseAlgorithm {
+		return 0, fmt.Errorf("unsupported client encryption algorithm %q", params[cseMetadataAlgorithm])
+	}
+	segmentSize, err := strconv.Atoi(params[cseMetadataSegmentSize])
+	if err != nil || segmentSize <= 0 {
+		return 0, fmt.Errorf("invalid client encryption segment size %q", params[cseMetadataSegmentSize])
+	}
+	return segmentSize, nil
+}
+
+// openObject returns the cipher of the segments of an object with the given parameters.
+func (c *clientEncryption) openObject(params map[string]string) (*segmentCipher, error) {
+	segmentSize, err := cseSegmentSize(params)
+	if err != nil {
+		return nil, err
+	}
+	keyID := params[cseMetadataKeyID]
+	kek, ok := c.ciphers[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown client encryption key %q", keyID)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(params[cseMetadataDataKey])
+	if err != nil || len(sealed) < kek.NonceSize() {
+		return nil, fmt.Errorf("invalid client encryption data key")
+	}
+	dataKey, err := kek.Open(nil, sealed[:kek.NonceSize()], sealed[kek.NonceSize():], []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("open client encryption data key with key %q: %w", keyID, err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &segmentCipher{aead: aead, segmentSize: segmentSize}, nil
+}
+
+// segmentCipher seals and opens the segments of an object.
+type segmentCipher struct {
+	aead        cipher.AEAD
+	segmentSize int
+}
+
+func (c *segmentCipher) nonce(index int64, last bool) []byte {
+	nonce := make([]byte, c.aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce, uint64(index))
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+func (c *segmentCipher) seal(dst, plaintext []byte, index int64, last bool) []byte {
+	return c.aead.Seal(dst, c.nonce(index, last), plaintext, nil)
+}
+
+func (c *segmentCipher) open(dst, ciphertext []byte, index int64, last bool) ([]byte, error) {
+	plaintext, err := c.aead.Open(dst, c.nonce(index, last), ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("open segment %d: %w", index, err)
+	}
+	return plaintext, nil
+}
+
+// sealedSize is the size of a sealed full segment.
+func (c *segmentCipher) sealedSize() int64 {
+	return int64(c.segmentSize + c.aead.Overhead())
+}
+
+// encrypt seals the whole content of an object.
+func (c *segmentCipher) encrypt(contents []byte) []byte {
+	segments := max(int64(len(contents)+c.segmentSize-1)/int64(c.segmentSize), 1)
+	out := make([]byte, 0, int64(len(contents))+segments*int64(c.aead.Overhead()))
+	for i := int64(0); i < segments; i++ {
+		segment := contents[min(i*int64(c.segmentSize), int64(len(contents))):min((i+1)*int64(c.segmentSize), int64(len(contents)))]
+		out = c.seal(out, segment, i, i == segments-1)
+	}
+	return out
+}
+
+// cseContentSize returns the size of the content of an encrypted object of the given size,
+// and its number of segments.
+func cseContentSize(size int64, segmentSize int) (int64, int64, error) {
+	sealedSize := int64(segmentSize + gcmOverhead)
+	segments := (size + sealedSize - 1) / sealedSize
+	if segments == 0 || size-(segments-1)*sealedSize < gcmOverhead {
+		return 0, 0, fmt.Errorf("invalid size %d of a client encrypted object", size)
+	}
+	return size - segments*gcmOverhead, segments, nil
+}
+
+// cseObjectSize returns the size of the content of an object of the given metadata and size.
+func cseObjectSize(metadata map[string]*string, size int64) (int64, error) {
+	params := cseParams(metadata)
+	if params == nil {
+		return size, nil
+	}
+	segmentSize, err := cseSegmentSize(params)
+	if err != nil {
+		return 0, err
+	}
+	size, _, err = cseContentSize(size, segmentSize)
+	return size, err
+}
+
+// gcmOverhead is the size of the tag added to each segment.
+const gcmOverhead = 16
+
+// encryptWriter seals what is written into segments written to a FileWriter,
+// holding back the last segment until Commit.
+type encryptWriter struct {
+	FileWriter
+
+	cipher *segmentCipher
+	buf    []byte
+	sealed []byte
+	index  int64
+	size   int64
+}
+
+func newEncryptWriter(w FileWriter, c *segmentCipher) *encryptWriter {
+	return &encryptWriter{
+		FileWriter: w,
+		cipher:     c,
+		buf:        make([]byte, 0, c.segmentSize),
+		sealed:     make([]byte, 0, c.sealedSize()),
+	}
+}
+
+func (w *encryptWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if len(w.buf) == w.cipher.segmentSize {
+			// More content follows, so the segment is not the last one.
+			err := w.writeSegment(false)
+			if err != nil {
+				return 0, err
+			}
+		}
+		c := copy(w.buf[len(w.buf):w.cipher.segmentSize], p)
+		w.buf = w.buf[:len(w.buf)+c]
+		p = p[c:]
+	}
+	w.size += int64(n)
+	return n, nil
+}
+
+func (w *encryptWriter) writeSegment(last bool) error {
+	w.sealed = w.cipher.seal(w.sealed[:0], w.buf, w.index, last)
+	_, err := w.FileWriter.Write(w.sealed)
+	if err != nil {
+		return err
+	}
+	w.buf = w.buf[:0]
+	w.index++
+	return nil
+}
+
+// Size returns the size of the content written.
+func (w *encryptWriter) Size() int64 {
+	return w.size
+}
+
+func (w *encryptWriter) Commit(ctx context.Context) error {
+	err := w.writeSegment(true)
+	if err != nil {
+		return err
+	}
+	return w.FileWriter.Commit(ctx)
+}
+
+// getObject is GetObject returning the decrypted content of objects encrypted on the client side,
+// the range of input being one of the content.
+func (s *SSS) getObject(ctx context.Context, input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	if s.cse == nil {
+		return s.backend.GetObjectWithContext(ctx, input)
+	}
+
+	start, end := int64(0), int64(-1)
+	if input.Range != nil {
+		var err error
+		start, end, err = parseOpenRange(*input.Range)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Guess the segment size of the object is the one written, to fetch the range at once.
+	segmentSize := s.cse.segmentSize
+	guessed := true
+	for {
+		in := *input
+		if input.Range != nil {
+			in.Range = aws.String(cseRange(start, end, segmentSize))
+		}
+		out, err := s.backend.GetObjectWithContext(ctx, &in)
+		var awsErr awserr.Error
+		if guessed && errors.As(err, &awsErr) && awsErr.Code() == "InvalidRange" {
+			// The guess reaches past the end of an object with smaller segments or not encrypted,
+			// the range itself tells which.
+			in = *input
+			out, err = s.backend.GetObjectWithContext(ctx, &in)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		params := cseParams(out.Metadata)
+		if params == nil {
+			if aws.StringValue(in.Range) == aws.StringValue(input.Range) {
+				return out, nil
+			}
+			// Not encrypted, fetch the range as it is.
+			out.Body.Close()
+			return s.backend.GetObjectWithContext(ctx, input)
+		}
+
+		size, err := cseSegmentSize(params)
+		if err != nil {
+			out.Body.Close()
+			return nil, err
+		}
+		if input.Range != nil && *in.Range != cseRange(start, end, size) {
+			out.Body.Close()
+			segmentSize = size
+			guessed = false
+			continue
+		}
+
+		c, err := s.cse.openObject(params)
+		if err != nil {
+			out.Body.Close()
+			return nil, err
+		}
+		out, err = decryptObject(out, c, input.Range, start, end)
+		if err != nil {
+			return nil, err
+		}
+		return out, nil
+	}
+}
+
+// parseOpenRange parses the byte ranges SSS requests, "bytes=start-" and "bytes=start-end",
+// end being -1 for the former.
+func parseOpenRange(rng string) (start, end int64, err error) {
+	spec, _ := strings.CutPrefix(rng, "bytes=")
+	first, last, _ := strings.Cut(spec, "-")
+	start, err = strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, errInvalidRange(rng)
+	}
+	end = -1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil {
+			return 0, 0, errInvalidRange(rng)
+		}
+	}
+	return start, end, nil
+}
+
+// cseRange returns the range of the segments holding the content from start to end.
+func cseRange(start, end int64, segmentSize int) string {
+	sealedSize := int64(segmentSize + gcmOverhead)
+	rng := "bytes=" + strconv.FormatInt(start/int64(segmentSize)*sealedSize, 10) + "-"
+	if end >= 0 {
+		rng += strconv.FormatInt((end/int64(segmentSize)+1)*sealedSize-1, 10)
+	}
+	return rng
+}
+
+// decryptObject turns out, holding the segments from the one of start, into the content from start to end.
+func decryptObject(out *s3.GetObjectOutput, c *segmentCipher, rng *string, start, end int64) (*s3.GetObjectOutput, error) {
+	size := aws.Int64Value(out.ContentLength)
+	if out.ContentRange != nil {
+		_, total, _ := strings.Cut(*out.ContentRange, "/")
+		var err error
+		size, err = strconv.ParseInt(total, 10, 64)
+		if err != nil {
+			out.Body.Close()
+			return nil, fmt.Errorf("invalid content range %q", *out.ContentRange)
+		}
+	}
+	contentSize, segments, err := cseContentSize(size, c.segmentSize)
+	if err != nil {
+		out.Body.Close()
+		return nil, err
+	}
+	if rng != nil && start >= contentSize {
+		out.Body.Close()
+		return nil, errInvalidRange(*rng)
+	}
+	if end < 0 || end >= contentSize {
+		end = contentSize - 1
+	}
+
+	first := start / int64(c.segmentSize)
+	decrypted := *out
+	decrypted.ContentLength = aws.Int64(end - start + 1)
+	decrypted.ContentRange = nil
+	if rng != nil {
+		decrypted.ContentRange = aws.String(contentRange(start, end, contentSize))
+	}
+	decrypted.Body = &decryptReader{
+		body:      out.Body,
+		cipher:    c,
+		index:     first,
+		last:      segments - 1,
+		lastSize:  size - (segments-1)*c.sealedSize(),
+		skip:      start - first*int64(c.segmentSize),
+		remaining: end - start + 1,
+	}
+	return &decrypted, nil
+}
+
+// decryptReader opens the segments read from body, from the one of the given index.
+type decryptReader struct {
+	body      io.ReadCloser
+	cipher    *segmentCipher
+	index     int64
+	last      int64
+	lastSize  int64
+	skip      int64
+	remaining int64
+
+	sealed []byte
+	buf    []byte
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if len(r.buf) == 0 {
+		err := r.next()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.buf[:min(int64(len(r.buf)), r.remaining)])
+	r.buf = r.buf[n:]
+	r.remaining -= int64(n)
+	return n, nil
+}
+
+// next opens the next segment into buf.
+func (r *decryptReader) next() error {
+	if r.index > r.last {
+		return io.ErrUnexpectedEOF
+	}
+	size := r.cipher.sealedSize()
+	if r.index == r.last {
+		size = r.lastSize
+	}
+	if r.sealed == nil {
+		r.sealed = make([]byte, r.cipher.sealedSize())
+	}
+	sealed := r.sealed[:size]
+	_, err := io.ReadFull(r.body, sealed)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	// Open in place, the content is shorter than the sealed segment.
+	r.buf, err = r.cipher.open(sealed[:0], sealed, r.index, r.index == r.last)
+	if err != nil {
+		return err
+	}
+	r.buf = r.buf[r.skip:]
+	r.skip = 0
+	r.index++
+	return nil
+}
+
+func (r *decryptReader) Close() error {
+	return r.body.Close()
+}
@@ -6,29 +6,29 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/s3"
 )
 
 func (s *SSS) SignDelete(path string, expires time.Duration) (string, error) {
-	return s.presign(expires,
-		func(c *s3.S3) *request.Request {
-			req, _ := c.DeleteObjectRequest(&s3.DeleteObjectInput{
-				Bucket: s.getBucket(),
-				Key:    aws.String(s.s3Path(path)),
-			})
-			return req
-		})
-}
-
-// Delete deletes the object stored at the given paths
-func (s *SSS) Delete(ctx context.Context, path string) error {
-	_, err := s.s3.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+	return s.presign(expires, &s3.DeleteObjectInput{
 		Bucket: s.getBucket(),
 		Key:    aws.String(s.s3Path(path)),
 	})
+}
+
+// Delete deletes the object stored at the given paths.
+// With WithVersionID the version is deleted for good, otherwise a versioned bucket keeps
+// the object under a delete marker.
+func (s *SSS) Delete(ctx context.Context, path string, opts ...ObjectOptions) error {
+	o := newObjectOption(opts)
+	_, err := s.backend.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket:    s.getBucket(),
+		Key:       aws.String(s.s3Path(path)),
+		VersionId: o.versionID(),
+	})
 	if err != nil {
-		return err
+		return parseError(path, err)
 	}
 	return nil
 }
@@ -36,19 +36,23 @@ func (s *SSS) Delete(ctx context.Context, path string) error {
 // DeleteBatch deletes multiple objects stored at the given paths
 func (s *SSS) DeleteBatch(ctx context.Context, paths []string) error {
 	var s3Objects []*s3.ObjectIdentifier
+	// keyPaths maps the keys of a batch back to the paths given.
+	keyPaths := map[string]string{}
 	for i := 0; i < len(paths); i += listMax {
 		end := i + listMax
 		if end > len(paths) {
 			end = len(paths)
 		}
 
+		clear(keyPaths)
 		for _, path := range paths[i:end] {
 			s3Objects = append(s3Objects, &s3.ObjectIdentifier{
 				Key: aws.String(s.s3Path(path)),
 			})
+			keyPaths[s.s3Path(path)] = path
 		}
 
-		resp, err := s.s3.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+		resp, err := s.backend.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
 			Bucket: s.getBucket(),
 			Delete: &s3.Delete{
 				Objects: s3Objects,
@@ -56,15 +60,16 @@ func (s *SSS) DeleteBatch(ctx context.Context, paths []string) error {
 			},
 		})
 		if err != nil {
-			return err
+			return parseError("", err)
 		}
 
 		s3Objects = s3Objects[:0]
+		s.logger.Info("delete batch", "objects", end-i, "errors", len(resp.Errors))
 
 		if len(resp.Errors) > 0 {
 			errs := make([]error, 0, len(resp.Errors))
 			for _, err := range resp.Errors {
-				errs = append(errs, errors.New(err.String()))
+				errs = append(errs, parseError(keyPaths[aws.StringValue(err.Key)], awserr.New(aws.StringValue(err.Code), aws.StringValue(err.Message), nil)))
 			}
 			return errors.Join(errs...)
 		}
@@ -0,0 +1,98 @@
+package sss
+
+import (
+	"errors"
+	"io/fs"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+var (
+	// ErrNotExist is returned for a path without an object, it matches fs.ErrNotExist as well.
+	ErrNotExist error = &sentinelError{"path not found", fs.ErrNotExist}
+
+	// ErrPermission is returned when the credentials are denied access, it matches fs.ErrPermission as well.
+	ErrPermission error = &sentinelError{"permission denied", fs.ErrPermission}
+
+	// ErrPreconditionFailed is returned when a write made with WithIfMatch or WithIfNoneMatch
+	// finds the object in another state.
+	ErrPreconditionFailed = errors.New("precondition failed")
+
+	// ErrObjectChanged is returned when a read pinned with WithETag finds the object with another ETag,
+	// it matches ErrPreconditionFailed as well.
+	ErrObjectChanged error = &sentinelError{"object changed", ErrPreconditionFailed}
+
+	// ErrUploadNotFound is returned for a multipart upload that doesn't exist or was completed or aborted,
+	// it matches fs.ErrNotExist as well.
+	ErrUploadNotFound error = &sentinelError{"multipart upload not found", fs.ErrNotExist}
+)
+
+// sentinelError is an error refining a more general one.
+type sentinelError struct {
+	msg    string
+	parent error
+}
+
+func (e *sentinelError) Error() string {
+	return e.msg
+}
+
+func (e *sentinelError) Unwrap() error {
+	return e.parent
+}
+
+// Error is returned by SSS for a failure on Path that callers tell apart, errors.Is matches it with Err,
+// one of the Err variables of this package, and errors.As finds the awserr.Error it comes from, if any.
+type Error struct {
+	Path  string
+	Err   error
+	Cause error
+}
+
+func (e *Error) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return e.Err.Error() + ": " + e.Path
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Cause == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Err, e.Cause}
+}
+
+// parseError returns err as an *Error if S3 reported one of the failures callers tell apart,
+// otherwise err as it is.
+func parseError(path string, err error) error {
+	var e *Error
+	var awsErr awserr.Error
+	if err == nil || errors.As(err, &e) || !errors.As(err, &awsErr) {
+		return err
+	}
+
+	var target error
+	switch awsErr.Code() {
+	case "NoSuchKey", "NoSuchVersion", "NotFound":
+		target = ErrNotExist
+	case "AccessDenied", "Forbidden":
+		target = ErrPermission
+	case "PreconditionFailed":
+		target = ErrPreconditionFailed
+	case "NoSuchUpload":
+		target = ErrUploadNotFound
+	default:
+		return err
+	}
+	return &Error{Path: path, Err: target, Cause: err}
+}
+
+// parseReadError is parseError for GetObject, whose only precondition is the ETag of WithETag.
+func parseReadError(path string, err error) error {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == "PreconditionFailed" {
+		return &Error{Path: path, Err: ErrObjectChanged, Cause: err}
+	}
+	return parseError(path, err)
+}
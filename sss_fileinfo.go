@@ -3,7 +3,10 @@ package sss
 import (
 	"io/fs"
 	"path"
+	"strings"
 	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
 )
 
 // FileInfo returns information about a given path.
@@ -20,6 +23,28 @@ type FileInfoExpansion struct {
 	AcceptRanges       *string
 	ETag               *string
 	Expires            *string
+	VersionID          *string
+	// Metadata is the user metadata of the object, keyed by the lower case name
+	// following x-amz-meta-.
+	Metadata map[string]string
+}
+
+// userMetadata returns the metadata of a response with lower case keys,
+// the SDK canonicalizing the header names they come from.
+// The parameters of client-side encryption are left out.
+func userMetadata(m map[string]*string) map[string]string {
+	var metadata map[string]string
+	for k, v := range m {
+		k = strings.ToLower(k)
+		if strings.HasPrefix(k, cseMetadataPrefix) {
+			continue
+		}
+		if metadata == nil {
+			metadata = make(map[string]string, len(m))
+		}
+		metadata[k] = aws.StringValue(v)
+	}
+	return metadata
 }
 
 type fileInfo struct {
@@ -6,19 +6,14 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/s3"
 )
 
 func (s *SSS) SignList(path string, expires time.Duration) (string, error) {
-	return s.presign(expires,
-		func(c *s3.S3) *request.Request {
-			req, _ := c.ListObjectsRequest(&s3.ListObjectsInput{
-				Bucket: s.getBucket(),
-				Prefix: aws.String(s.s3Path(path)),
-			})
-			return req
-		})
+	return s.presign(expires, &s3.ListObjectsInput{
+		Bucket: s.getBucket(),
+		Prefix: aws.String(s.s3Path(path)),
+	})
 }
 
 func (s *SSS) List(ctx context.Context, opath string, fun func(fileInfo FileInfo) bool) error {
@@ -37,14 +32,14 @@ func (s *SSS) List(ctx context.Context, opath string, fun func(fileInfo FileInfo
 		prefix = "/"
 	}
 
-	err := s.s3.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+	err := s.backend.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
 		Bucket:    s.getBucket(),
 		Prefix:    aws.String(s.s3Path(path)),
 		Delimiter: aws.String("/"),
 		MaxKeys:   aws.Int64(listMax),
 	}, func(resp *s3.ListObjectsV2Output, lastPage bool) bool {
 		for _, key := range resp.Contents {
-			if *key.Size == 0 {
+			if strings.HasSuffix(*key.Key, "/") {
 				fileInfo := &fileInfo{
 					path:    strings.Replace(*key.Key, s3Path, prefix, 1),
 					isDir:   true,
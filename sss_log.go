@@ -0,0 +1,106 @@
+package sss
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// LevelTrace is the level of the requests and responses dumped by the SDK, below slog.LevelDebug.
+const LevelTrace = slog.LevelDebug - 4
+
+// defaultLogLevel is the level SSS logs at to slog.Default when neither WithLogger nor loglevel is given.
+const defaultLogLevel = slog.LevelWarn
+
+// WithLogger sends the records of SSS to logger instead of slog.Default.
+// Records below the loglevel of WithURL are dropped, the others are left to the handler of logger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(p *sssOption) error {
+		p.Logger = logger
+		return nil
+	}
+}
+
+// parseLogLevel returns the level of the loglevel parameter of WithURL, false if it is unknown.
+// The values of aws.LogLevelType once taken by loglevel are still accepted:
+// "off" is the default and the "debugwith" ones dump the requests as trace does.
+func parseLogLevel(level string) (slog.Leveler, bool) {
+	level = strings.ToLower(level)
+	switch level {
+	case "", "off":
+		return nil, true
+	case "error":
+		return slog.LevelError, true
+	case "warn", "warning":
+		return slog.LevelWarn, true
+	case "info":
+		return slog.LevelInfo, true
+	case "debug":
+		return slog.LevelDebug, true
+	case "trace":
+		return LevelTrace, true
+	}
+	if strings.HasPrefix(level, "debugwith") {
+		return LevelTrace, true
+	}
+	return nil, false
+}
+
+// newLogger returns logger dropping the records below level.
+func newLogger(logger *slog.Logger, level slog.Leveler) *slog.Logger {
+	if logger == nil {
+		logger = slog.Default()
+		if level == nil {
+			level = defaultLogLevel
+		}
+	}
+	if level == nil {
+		return logger
+	}
+	return slog.New(&levelHandler{level: level, Handler: logger.Handler()})
+}
+
+// Logger returns the logger SSS sends its records to.
+func (s *SSS) Logger() *slog.Logger {
+	return s.logger
+}
+
+// levelHandler drops the records below level.
+type levelHandler struct {
+	level slog.Leveler
+	slog.Handler
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.Handler.Enabled(ctx, level)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{level: h.level, Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{level: h.level, Handler: h.Handler.WithGroup(name)}
+}
+
+// sdkLogger sends the logs of the SDK to a logger at LevelTrace.
+type sdkLogger struct {
+	logger *slog.Logger
+}
+
+func (l sdkLogger) Log(args ...any) {
+	l.logger.Log(context.Background(), LevelTrace, "aws sdk", "message", redact(fmt.Sprint(args...)))
+}
+
+var (
+	redactedHeaders = regexp.MustCompile(`(?im)^((?:Authorization|X-Amz-Security-Token|X-Amz-(?:Copy-Source-)?Server-Side-Encryption-Customer-Key):)[^\r\n]*`)
+	redactedParams  = regexp.MustCompile(`(?i)((?:X-Amz-Signature|X-Amz-Credential|X-Amz-Security-Token|Signature|AWSAccessKeyId)=)[^&\s]*`)
+)
+
+// redact hides the credentials, signatures and encryption keys in URLs and dumps of HTTP messages.
+func redact(s string) string {
+	s = redactedHeaders.ReplaceAllString(s, "$1 REDACTED")
+	return redactedParams.ReplaceAllString(s, "${1}REDACTED")
+}
@@ -0,0 +1,250 @@
+package sss
+
+import (
+	"container/list"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// WithMetadataCache keeps the answers of HeadObject and ListObjectsV2 in memory for ttl,
+// missing objects included, so that Stat, StatHead, List and Walk reuse them.
+// At most 10000 answers are kept, the oldest being dropped first.
+// The writes and deletes made through the SSS drop what they change right away,
+// while those made by others are seen once the ttl expires.
+func WithMetadataCache(ttl time.Duration) Option {
+	return func(p *sssOption) error {
+		p.MetadataCacheTTL = ttl
+		return nil
+	}
+}
+
+// maxMetadataEntries caps the answers kept by the metadata cache, the oldest being dropped first.
+const maxMetadataEntries = 10000
+
+type metadataEntry struct {
+	id      string
+	expires time.Time
+	key     string
+	prefix  string
+	head    *s3.HeadObjectOutput
+	list    *s3.ListObjectsV2Output
+	err     error
+
+	elem *list.Element
+}
+
+// metadataCachedBackend serves HeadObject and ListObjectsV2 from memory.
+type metadataCachedBackend struct {
+	Backend
+	ttl time.Duration
+
+	mut     sync.Mutex
+	entries map[string]*metadataEntry
+	// heads and lists index the entries by key and by prefix listed.
+	heads map[string]map[string]*metadataEntry
+	lists map[string]map[string]*metadataEntry
+	// order holds the entries from the oldest, which expire first.
+	order      *list.List
+	generation uint64
+}
+
+func metadataCacheBackend(backend Backend, ttl time.Duration) *metadataCachedBackend {
+	return &metadataCachedBackend{
+		Backend: backend,
+		ttl:     ttl,
+		entries: map[string]*metadataEntry{},
+		heads:   map[string]map[string]*metadataEntry{},
+		lists:   map[string]map[string]*metadataEntry{},
+		order:   list.New(),
+	}
+}
+
+// lookup returns the entry cached under id and the generation to store a new one with.
+func (b *metadataCachedBackend) lookup(id string) (*metadataEntry, uint64) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	e, ok := b.entries[id]
+	if ok && time.Now().Before(e.expires) {
+		return e, b.generation
+	}
+	return nil, b.generation
+}
+
+// store caches e under id unless something was invalidated since generation.
+func (b *metadataCachedBackend) store(id string, generation uint64, e *metadataEntry) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	if generation != b.generation {
+		return
+	}
+	if old, ok := b.entries[id]; ok {
+		b.remove(old)
+	}
+
+	now := time.Now()
+	e.id = id
+	e.expires = now.Add(b.ttl)
+	e.elem = b.order.PushBack(e)
+	b.entries[id] = e
+	index := b.heads
+	name := e.key
+	if e.list != nil {
+		index = b.lists
+		name = e.prefix
+	}
+	ids := index[name]
+	if ids == nil {
+		ids = map[string]*metadataEntry{}
+		index[name] = ids
+	}
+	ids[id] = e
+
+	for b.order.Len() != 0 {
+		oldest := b.order.Front().Value.(*metadataEntry)
+		if b.order.Len() <= maxMetadataEntries && now.Before(oldest.expires) {
+			break
+		}
+		b.remove(oldest)
+	}
+}
+
+// remove drops e, b.mut must be held.
+func (b *metadataCachedBackend) remove(e *metadataEntry) {
+	b.order.Remove(e.elem)
+	delete(b.entries, e.id)
+	index := b.heads
+	name := e.key
+	if e.list != nil {
+		index = b.lists
+		name = e.prefix
+	}
+	delete(index[name], e.id)
+	if len(index[name]) == 0 {
+		delete(index, name)
+	}
+}
+
+// invalidate drops the heads of key and the listings that may contain it.
+func (b *metadataCachedBackend) invalidate(keys ...string) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	b.generation++
+	for _, key := range keys {
+		for _, e := range b.heads[key] {
+			b.remove(e)
+		}
+		for i := 0; i <= len(key) && len(b.lists) != 0; i++ {
+			for _, e := range b.lists[key[:i]] {
+				b.remove(e)
+			}
+		}
+	}
+}
+
+// cacheable reports whether err is an answer about the object rather than a failure to get one.
+func cacheable(err error) bool {
+	var reqErr awserr.RequestFailure
+	return errors.As(err, &reqErr) && reqErr.StatusCode() == http.StatusNotFound
+}
+
+func (b *metadataCachedBackend) HeadObjectWithContext(ctx aws.Context, input *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	if input.IfMatch != nil || input.IfNoneMatch != nil || input.IfModifiedSince != nil ||
+		input.IfUnmodifiedSince != nil || input.PartNumber != nil || input.Range != nil {
+		return b.Backend.HeadObjectWithContext(ctx, input, opts...)
+	}
+
+	key := aws.StringValue(input.Key)
+	id := "head\x00" + aws.StringValue(input.Bucket) + "\x00" + key + "\x00" + aws.StringValue(input.VersionId)
+	e, generation := b.lookup(id)
+	if e != nil {
+		return e.head, e.err
+	}
+
+	out, err := b.Backend.HeadObjectWithContext(ctx, input, opts...)
+	if err == nil || cacheable(err) {
+		b.store(id, generation, &metadataEntry{key: key, head: out, err: err})
+	}
+	return out, err
+}
+
+func (b *metadataCachedBackend) ListObjectsV2WithContext(ctx aws.Context, input *s3.ListObjectsV2Input, opts ...request.Option) (*s3.ListObjectsV2Output, error) {
+	prefix := aws.StringValue(input.Prefix)
+	id := strings.Join([]string{
+		"list",
+		aws.StringValue(input.Bucket),
+		prefix,
+		aws.StringValue(input.Delimiter),
+		aws.StringValue(input.StartAfter),
+		aws.StringValue(input.ContinuationToken),
+		strconv.FormatInt(aws.Int64Value(input.MaxKeys), 10),
+		strconv.FormatBool(aws.BoolValue(input.FetchOwner)),
+		aws.StringValue(input.EncodingType),
+	}, "\x00")
+	e, generation := b.lookup(id)
+	if e != nil {
+		return e.list, e.err
+	}
+
+	out, err := b.Backend.ListObjectsV2WithContext(ctx, input, opts...)
+	if err == nil {
+		b.store(id, generation, &metadataEntry{prefix: prefix, list: out})
+	}
+	return out, err
+}
+
+// ListObjectsV2PagesWithContext lists page by page through ListObjectsV2WithContext,
+// so that each page is cached.
+func (b *metadataCachedBackend) ListObjectsV2PagesWithContext(ctx aws.Context, input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool, opts ...request.Option) error {
+	in := *input
+	for {
+		out, err := b.ListObjectsV2WithContext(ctx, &in, opts...)
+		if err != nil {
+			return err
+		}
+		lastPage := !aws.BoolValue(out.IsTruncated) || out.NextContinuationToken == nil
+		if !fn(out, lastPage) || lastPage {
+			return nil
+		}
+		in.ContinuationToken = out.NextContinuationToken
+	}
+}
+
+func (b *metadataCachedBackend) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	defer b.invalidate(aws.StringValue(input.Key))
+	return b.Backend.PutObjectWithContext(ctx, input, opts...)
+}
+
+func (b *metadataCachedBackend) CompleteMultipartUploadWithContext(ctx aws.Context, input *s3.CompleteMultipartUploadInput, opts ...request.Option) (*s3.CompleteMultipartUploadOutput, error) {
+	defer b.invalidate(aws.StringValue(input.Key))
+	return b.Backend.CompleteMultipartUploadWithContext(ctx, input, opts...)
+}
+
+func (b *metadataCachedBackend) CopyObjectWithContext(ctx aws.Context, input *s3.CopyObjectInput, opts ...request.Option) (*s3.CopyObjectOutput, error) {
+	defer b.invalidate(aws.StringValue(input.Key))
+	return b.Backend.CopyObjectWithContext(ctx, input, opts...)
+}
+
+func (b *metadataCachedBackend) DeleteObjectWithContext(ctx aws.Context, input *s3.DeleteObjectInput, opts ...request.Option) (*s3.DeleteObjectOutput, error) {
+	defer b.invalidate(aws.StringValue(input.Key))
+	return b.Backend.DeleteObjectWithContext(ctx, input, opts...)
+}
+
+func (b *metadataCachedBackend) DeleteObjectsWithContext(ctx aws.Context, input *s3.DeleteObjectsInput, opts ...request.Option) (*s3.DeleteObjectsOutput, error) {
+	if input.Delete != nil {
+		keys := make([]string, 0, len(input.Delete.Objects))
+		for _, obj := range input.Delete.Objects {
+			keys = append(keys, aws.StringValue(obj.Key))
+		}
+		defer b.invalidate(keys...)
+	}
+	return b.Backend.DeleteObjectsWithContext(ctx, input, opts...)
+}
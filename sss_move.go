@@ -0,0 +1,355 @@
+package sss
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const (
+	// moveBatch is the number of objects copied between saves of the move record
+	moveBatch = 100
+
+	// moveRecordSuffix is appended to the source prefix to name the move record
+	moveRecordSuffix = ".sss-move"
+)
+
+const (
+	movePhaseCopy   = "copy"
+	movePhaseDelete = "delete"
+)
+
+// moveRecord is the progress of MoveAll, stored next to the source prefix
+// so an interrupted move can be finished or rolled back.
+type moveRecord struct {
+	Source string `json:"source"`
+	Dest   string `json:"dest"`
+	Phase  string `json:"phase"`
+
+	// Copied is the last path, relative to Source, copied to Dest.
+	Copied string `json:"copied,omitempty"`
+	// Deleted is the last path, relative to Source, deleted from Source.
+	Deleted string `json:"deleted,omitempty"`
+}
+
+// Move moves the object at sourcePath to destPath by copying then deleting it.
+func (s *SSS) Move(ctx context.Context, sourcePath, destPath string) error {
+	err := s.Copy(ctx, sourcePath, destPath)
+	if err != nil {
+		return err
+	}
+	return s.Delete(ctx, sourcePath)
+}
+
+// MoveAll moves all objects below srcPrefix to dstPrefix.
+// The objects are copied first, then deleted from srcPrefix, while the progress is kept in
+// an object next to srcPrefix. Calling MoveAll again finishes an interrupted move and
+// RollbackMove undoes it. dstPrefix must be empty unless an interrupted move is resumed,
+// and srcPrefix should not be written to during the move.
+func (s *SSS) MoveAll(ctx context.Context, srcPrefix, dstPrefix string) error {
+	srcPrefix, dstPrefix, err := cleanMovePrefixes(srcPrefix, dstPrefix)
+	if err != nil {
+		return err
+	}
+
+	record, err := s.loadMoveRecord(ctx, srcPrefix)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		empty, err := s.isEmpty(ctx, dstPrefix)
+		if err != nil {
+			return err
+		}
+		if !empty {
+			return fmt.Errorf("move destination is not empty: %s", dstPrefix)
+		}
+		record = &moveRecord{
+			Source: srcPrefix,
+			Dest:   dstPrefix,
+			Phase:  movePhaseCopy,
+		}
+		err = s.saveMoveRecord(ctx, record)
+		if err != nil {
+			return err
+		}
+	} else if record.Dest != dstPrefix {
+		return fmt.Errorf("%s is being moved to %s", srcPrefix, record.Dest)
+	}
+
+	if record.Phase == movePhaseCopy {
+		var batch []string
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			err := s.copyConcurrently(ctx, srcPrefix, dstPrefix, batch)
+			if err != nil {
+				return err
+			}
+			record.Copied = batch[len(batch)-1]
+			batch = batch[:0]
+			return s.saveMoveRecord(ctx, record)
+		}
+
+		err = s.walkAfter(ctx, srcPrefix, record.Copied, func(rel string) error {
+			batch = append(batch, rel)
+			if len(batch) == moveBatch {
+				return flush()
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = flush()
+		if err != nil {
+			return err
+		}
+
+		record.Phase = movePhaseDelete
+		err = s.saveMoveRecord(ctx, record)
+		if err != nil {
+			return err
+		}
+	}
+
+	var batch []string
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := s.DeleteBatch(ctx, batch)
+		if err != nil {
+			return err
+		}
+		record.Deleted = strings.TrimPrefix(batch[len(batch)-1], srcPrefix+"/")
+		batch = batch[:0]
+		return s.saveMoveRecord(ctx, record)
+	}
+
+	err = s.walkAfter(ctx, srcPrefix, record.Deleted, func(rel string) error {
+		if rel > record.Copied {
+			// Not part of this move.
+			return nil
+		}
+		batch = append(batch, srcPrefix+"/"+rel)
+		if len(batch) == listMax {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = flush()
+	if err != nil {
+		return err
+	}
+
+	return s.Delete(ctx, srcPrefix+moveRecordSuffix)
+}
+
+// RollbackMove undoes an interrupted MoveAll from srcPrefix to dstPrefix,
+// restoring the objects already deleted from srcPrefix and removing the copies in dstPrefix.
+// The copies are only removed once every object is back in srcPrefix.
+func (s *SSS) RollbackMove(ctx context.Context, srcPrefix, dstPrefix string) error {
+	srcPrefix, dstPrefix, err := cleanMovePrefixes(srcPrefix, dstPrefix)
+	if err != nil {
+		return err
+	}
+
+	record, err := s.loadMoveRecord(ctx, srcPrefix)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return fmt.Errorf("no move in progress: %s", srcPrefix)
+	}
+	if record.Dest != dstPrefix {
+		return fmt.Errorf("%s is being moved to %s", srcPrefix, record.Dest)
+	}
+
+	if record.Phase == movePhaseDelete {
+		// Record.Deleted may lag behind the deletes made before an interruption,
+		// so every copy missing from srcPrefix is restored, not only those up to it.
+		var batch []string
+		err = s.walkAfter(ctx, dstPrefix, "", func(rel string) error {
+			if rel > record.Copied {
+				return ErrFilledBuffer
+			}
+			batch = append(batch, rel)
+			if len(batch) == moveBatch {
+				err := s.restoreConcurrently(ctx, dstPrefix, srcPrefix, batch)
+				batch = batch[:0]
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = s.restoreConcurrently(ctx, dstPrefix, srcPrefix, batch)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = s.DeleteAll(ctx, dstPrefix)
+	if err != nil {
+		return err
+	}
+	return s.Delete(ctx, srcPrefix+moveRecordSuffix)
+}
+
+func cleanMovePrefixes(srcPrefix, dstPrefix string) (string, string, error) {
+	srcPrefix = "/" + strings.Trim(srcPrefix, "/")
+	dstPrefix = "/" + strings.Trim(dstPrefix, "/")
+	switch {
+	case srcPrefix == "/" || dstPrefix == "/":
+		return "", "", fmt.Errorf("can't move the root directory")
+	case srcPrefix == dstPrefix,
+		strings.HasPrefix(dstPrefix, srcPrefix+"/"),
+		strings.HasPrefix(srcPrefix, dstPrefix+"/"):
+		return "", "", fmt.Errorf("can't move %s to %s", srcPrefix, dstPrefix)
+	}
+	return srcPrefix, dstPrefix, nil
+}
+
+// walkAfter calls fn with the path relative to prefix of each file below prefix,
+// skipping those up to and including after.
+func (s *SSS) walkAfter(ctx context.Context, prefix, after string, fn func(rel string) error) error {
+	var opts []func(*walkOptions)
+	if after != "" {
+		opts = append(opts, WithStartAfterHint(prefix+"/"+after))
+	}
+	err := s.Walk(ctx, prefix, func(fileInfo FileInfo) error {
+		if fileInfo.IsDir() {
+			return nil
+		}
+		rel := strings.TrimPrefix(fileInfo.Path(), prefix+"/")
+		if after != "" && rel <= after {
+			return nil
+		}
+		return fn(rel)
+	}, opts...)
+	if errors.Is(err, ErrFilledBuffer) {
+		return nil
+	}
+	return err
+}
+
+// copyConcurrently copies the paths relative to srcPrefix to dstPrefix.
+func (s *SSS) copyConcurrently(ctx context.Context, srcPrefix, dstPrefix string, rels []string) error {
+	return forEachConcurrently(ctx, rels, func(ctx context.Context, rel string) error {
+		return s.Copy(ctx, srcPrefix+"/"+rel, dstPrefix+"/"+rel)
+	})
+}
+
+// restoreConcurrently copies the paths relative to srcPrefix to dstPrefix
+// unless they already exist there.
+func (s *SSS) restoreConcurrently(ctx context.Context, srcPrefix, dstPrefix string, rels []string) error {
+	return forEachConcurrently(ctx, rels, func(ctx context.Context, rel string) error {
+		_, err := s.StatHead(ctx, dstPrefix+"/"+rel)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, ErrNotExist) {
+			return err
+		}
+		return s.Copy(ctx, srcPrefix+"/"+rel, dstPrefix+"/"+rel)
+	})
+}
+
+// forEachConcurrently calls fn with each of rels, stopping at the first error.
+func forEachConcurrently(ctx context.Context, rels []string, fn func(ctx context.Context, rel string) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sem := make(chan struct{}, copyConcurrency)
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+	for _, rel := range rels {
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			<-sem
+			break
+		}
+
+		wg.Add(1)
+		go func(rel string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			err := fn(ctx, rel)
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(rel)
+	}
+	wg.Wait()
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return firstErr
+}
+
+func (s *SSS) isEmpty(ctx context.Context, prefix string) (bool, error) {
+	empty := true
+	err := s.List(ctx, prefix, func(fileInfo FileInfo) bool {
+		empty = false
+		return false
+	})
+	return empty, err
+}
+
+// loadMoveRecord returns the record of the move from srcPrefix, or nil if there is none.
+func (s *SSS) loadMoveRecord(ctx context.Context, srcPrefix string) (*moveRecord, error) {
+	resp, err := s.getObject(ctx, &s3.GetObjectInput{
+		Bucket:               s.getBucket(),
+		Key:                  aws.String(s.s3Path(srcPrefix + moveRecordSuffix)),
+		SSECustomerAlgorithm: s.getSSECustomerAlgorithm(),
+		SSECustomerKey:       s.getSSECustomerKey(),
+	})
+	if err != nil {
+		err = parseReadError(srcPrefix+moveRecordSuffix, err)
+		if errors.Is(err, ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var record moveRecord
+	err = json.Unmarshal(data, &record)
+	if err != nil {
+		return nil, fmt.Errorf("invalid move record %s: %w", srcPrefix+moveRecordSuffix, err)
+	}
+	return &record, nil
+}
+
+func (s *SSS) saveMoveRecord(ctx context.Context, record *moveRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.PutContent(ctx, record.Source+moveRecordSuffix, data, WithContentType("application/json"))
+}
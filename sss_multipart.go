@@ -2,13 +2,13 @@ package sss
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"sort"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/s3"
 )
 
@@ -35,9 +35,10 @@ func (p *Parts) LastModified() time.Time {
 }
 
 type Multipart struct {
-	driver   *SSS
-	key      string
-	uploadID string
+	driver    *SSS
+	key       string
+	uploadID  string
+	initiated time.Time
 
 	parts []*s3.Part
 }
@@ -50,6 +51,12 @@ func (m *Multipart) UploadID() string {
 	return m.uploadID
 }
 
+// Initiated returns when the upload was created, the zero time if unknown,
+// as for GetMultipartWithUploadID.
+func (m *Multipart) Initiated() time.Time {
+	return m.initiated
+}
+
 func (m *Multipart) SetParts(parts []*s3.Part) {
 	m.parts = parts
 }
@@ -58,16 +65,16 @@ func (m *Multipart) Resume(ctx context.Context) error {
 	parts := make([]*s3.Part, 0, 16)
 	listPartsInput := &s3.ListPartsInput{
 		Bucket:   m.driver.getBucket(),
-		Key:      aws.String(m.driver.s3Path(m.key)),
+		Key:      aws.String(m.key),
 		UploadId: aws.String(m.uploadID),
 	}
 
-	err := m.driver.s3.ListPartsPagesWithContext(ctx, listPartsInput, func(partsList *s3.ListPartsOutput, lastPage bool) bool {
+	err := m.driver.backend.ListPartsPagesWithContext(ctx, listPartsInput, func(partsList *s3.ListPartsOutput, lastPage bool) bool {
 		parts = append(parts, partsList.Parts...)
 		return !lastPage
 	})
 	if err != nil {
-		return err
+		return parseError(m.key, err)
 	}
 
 	partMap := map[int64]*s3.Part{}
@@ -88,11 +95,15 @@ func (m *Multipart) Resume(ctx context.Context) error {
 
 	uniqueParts := make([]*s3.Part, 0, len(partMap))
 	for _, part := range partMap {
+		if part == ignore {
+			continue
+		}
 		uniqueParts = append(uniqueParts, part)
 	}
 
 	sort.Sort(s3parts(uniqueParts))
 	m.parts = uniqueParts
+	m.driver.logger.Info("resume multipart upload", "key", m.key, "upload_id", m.uploadID, "parts", len(uniqueParts))
 
 	return nil
 }
@@ -159,42 +170,44 @@ func (m *Multipart) OrderParts(ctx context.Context) (*Parts, error) {
 }
 
 func (m *Multipart) Cancel(ctx context.Context) error {
-	_, err := m.driver.s3.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+	_, err := m.driver.backend.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
 		Bucket:   aws.String(m.driver.bucket),
 		Key:      aws.String(m.key),
 		UploadId: aws.String(m.uploadID),
 	})
-	return err
+	return parseError(m.key, err)
 }
 
 func (m *Multipart) SignUploadPart(partNumber int64, expires time.Duration) (string, error) {
-	return m.driver.presign(expires,
-		func(c *s3.S3) *request.Request {
-			req, _ := c.UploadPartRequest(&s3.UploadPartInput{
-				Bucket:     aws.String(m.driver.bucket),
-				Key:        aws.String(m.key),
-				PartNumber: &partNumber,
-				UploadId:   aws.String(m.uploadID),
-			})
-			return req
-		})
+	return m.driver.presign(expires, &s3.UploadPartInput{
+		Bucket:               aws.String(m.driver.bucket),
+		Key:                  aws.String(m.key),
+		PartNumber:           &partNumber,
+		UploadId:             aws.String(m.uploadID),
+		SSECustomerAlgorithm: m.driver.getSSECustomerAlgorithm(),
+		SSECustomerKey:       m.driver.getSSECustomerKey(),
+	})
 }
 
 func (m *Multipart) UploadPart(ctx context.Context, partNumber int64, body io.ReadSeeker) error {
-	_, err := m.driver.s3.UploadPartWithContext(ctx, &s3.UploadPartInput{
-		Bucket:     aws.String(m.driver.bucket),
-		Key:        aws.String(m.key),
-		PartNumber: &partNumber,
-		UploadId:   aws.String(m.uploadID),
-		Body:       body,
+	_, err := m.driver.backend.UploadPartWithContext(ctx, &s3.UploadPartInput{
+		Bucket:               aws.String(m.driver.bucket),
+		Key:                  aws.String(m.key),
+		PartNumber:           &partNumber,
+		UploadId:             aws.String(m.uploadID),
+		SSECustomerAlgorithm: m.driver.getSSECustomerAlgorithm(),
+		SSECustomerKey:       m.driver.getSSECustomerKey(),
+		Body:                 body,
 	})
 	if err != nil {
-		return fmt.Errorf("upload part: %w", err)
+		return fmt.Errorf("upload part: %w", parseError(m.key, err))
 	}
 	return nil
 }
 
-func (m *Multipart) Commit(ctx context.Context) error {
+// Commit completes the upload with its parts, opts may set the conditions
+// of WithIfMatch and WithIfNoneMatch, the others are given when creating the upload.
+func (m *Multipart) Commit(ctx context.Context, opts ...WriterOptions) error {
 	if len(m.parts) == 0 {
 		err := m.Resume(ctx)
 		if err != nil {
@@ -223,9 +236,13 @@ func (m *Multipart) Commit(ctx context.Context) error {
 		},
 	}
 
-	_, err := m.driver.s3.CompleteMultipartUploadWithContext(ctx, completeMultipartUploadInput)
+	var o writerOption
+	for _, opt := range opts {
+		opt(&o)
+	}
+	_, err := m.driver.backend.CompleteMultipartUploadWithContext(ctx, completeMultipartUploadInput, o.requestOptions()...)
 	if err != nil {
-		return err
+		return parseError(m.key, err)
 	}
 	return nil
 }
@@ -238,12 +255,13 @@ func (s *SSS) ListMultipart(ctx context.Context, path string, fun func(mp *Multi
 		Prefix: aws.String(key),
 	}
 
-	err := s.s3.ListMultipartUploadsPagesWithContext(ctx, listMultipartUploadsInput, func(resp *s3.ListMultipartUploadsOutput, lastPage bool) bool {
+	err := s.backend.ListMultipartUploadsPagesWithContext(ctx, listMultipartUploadsInput, func(resp *s3.ListMultipartUploadsOutput, lastPage bool) bool {
 		for _, multi := range resp.Uploads {
 			if !fun(&Multipart{
-				uploadID: *multi.UploadId,
-				key:      *multi.Key,
-				driver:   s,
+				uploadID:  *multi.UploadId,
+				key:       *multi.Key,
+				initiated: aws.TimeValue(multi.Initiated),
+				driver:    s,
 			}) {
 				return false
 			}
@@ -261,7 +279,7 @@ func (s *SSS) GetMultipart(ctx context.Context, path string) (*Multipart, error)
 	key := s.s3Path(path)
 
 	var mps []*Multipart
-	err := s.ListMultipart(ctx, key, func(mp *Multipart) bool {
+	err := s.ListMultipart(ctx, path, func(mp *Multipart) bool {
 		if mp.Key() == key {
 			mps = append(mps, mp)
 		}
@@ -273,7 +291,7 @@ func (s *SSS) GetMultipart(ctx context.Context, path string) (*Multipart, error)
 
 	switch len(mps) {
 	case 0:
-		return nil, fmt.Errorf("not found part: %s", path)
+		return nil, &Error{Path: path, Err: ErrUploadNotFound}
 	case 1:
 		return mps[0], nil
 	}
@@ -307,8 +325,8 @@ func (s *SSS) GetMultipartByUploadID(ctx context.Context, path, uploadID string)
 	key := s.s3Path(path)
 
 	var mps *Multipart
-	err := s.ListMultipart(ctx, key, func(mp *Multipart) bool {
-		if mp.Key() == path && mp.UploadID() == uploadID {
+	err := s.ListMultipart(ctx, path, func(mp *Multipart) bool {
+		if mp.Key() == key && mp.UploadID() == uploadID {
 			mps = mp
 		}
 		return true
@@ -317,7 +335,7 @@ func (s *SSS) GetMultipartByUploadID(ctx context.Context, path, uploadID string)
 		return nil, err
 	}
 	if mps == nil {
-		return nil, fmt.Errorf("not found part with upload id: %s, %s", path, uploadID)
+		return nil, &Error{Path: path, Err: ErrUploadNotFound}
 	}
 
 	return mps, nil
@@ -343,6 +361,8 @@ func (s *SSS) newMultipart(ctx context.Context, path string, o writerOption) (*M
 		ServerSideEncryption: s.getEncryptionMode(),
 		SSEKMSKeyId:          s.getSSEKMSKeyID(),
 		StorageClass:         s.getStorageClass(),
+		SSECustomerAlgorithm: s.getSSECustomerAlgorithm(),
+		SSECustomerKey:       s.getSSECustomerKey(),
 	}
 	if o.ContentType != "" {
 		createMultipartUploadInput.ContentType = aws.String(o.ContentType)
@@ -350,15 +370,66 @@ func (s *SSS) newMultipart(ctx context.Context, path string, o writerOption) (*M
 	if o.ContentDisposition != "" {
 		createMultipartUploadInput.ContentDisposition = aws.String(o.ContentDisposition)
 	}
+	createMultipartUploadInput.Metadata = o.Metadata
+	createMultipartUploadInput.Tagging = o.Tagging
 
-	resp, err := s.s3.CreateMultipartUploadWithContext(ctx, createMultipartUploadInput)
+	resp, err := s.backend.CreateMultipartUploadWithContext(ctx, createMultipartUploadInput)
 	if err != nil {
-		return nil, err
+		return nil, parseError(path, err)
 	}
 
 	return &Multipart{
-		uploadID: *resp.UploadId,
-		key:      *resp.Key,
-		driver:   s,
+		uploadID:  *resp.UploadId,
+		key:       *resp.Key,
+		initiated: time.Now(),
+		driver:    s,
 	}, nil
 }
+
+// StaleMultipart is a multipart upload found by AbortStaleMultipart, with the bytes of its parts.
+type StaleMultipart struct {
+	*Multipart
+	Size int64
+}
+
+// AbortStaleMultipart aborts the multipart uploads under prefix initiated more than olderThan ago,
+// returning them with the bytes of their parts reclaimed.
+// With dryRun, it only returns the uploads it would abort.
+// It goes on past the uploads failing to abort and returns their errors joined.
+func (s *SSS) AbortStaleMultipart(ctx context.Context, prefix string, olderThan time.Duration, dryRun bool) ([]StaleMultipart, error) {
+	cutoff := time.Now().Add(-olderThan)
+	var stale []*Multipart
+	err := s.ListMultipart(ctx, prefix, func(mp *Multipart) bool {
+		if !mp.initiated.IsZero() && mp.initiated.Before(cutoff) {
+			stale = append(stale, mp)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var aborted []StaleMultipart
+	var errs []error
+	for _, mp := range stale {
+		parts, err := mp.AllParts(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("abort %s %s: %w", mp.key, mp.uploadID, err))
+			continue
+		}
+		if !dryRun {
+			err = mp.Cancel(ctx)
+			if err != nil {
+				// An upload not found was completed or aborted since listed.
+				if !errors.Is(err, ErrUploadNotFound) {
+					errs = append(errs, fmt.Errorf("abort %s %s: %w", mp.key, mp.uploadID, err))
+				}
+				continue
+			}
+		}
+		s.logger.Info("abort stale multipart upload", "key", mp.key, "upload_id", mp.uploadID,
+			"initiated", mp.initiated, "size", parts.Size(), "dry_run", dryRun)
+		aborted = append(aborted, StaleMultipart{Multipart: mp, Size: parts.Size()})
+	}
+	return aborted, errors.Join(errs...)
+}
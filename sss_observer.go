@@ -0,0 +1,275 @@
+package sss
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Event describes a call to the backend, see WithObserver.
+type Event struct {
+	// Operation is the name of the S3 operation, e.g. "GetObject".
+	Operation string
+	// Key is the key of the object, or the prefix listed.
+	Key string
+	// Bytes is the length of the body sent or, for GetObject, received.
+	Bytes int64
+	// Latency is the time the backend took to answer, which for GetObject excludes reading the body.
+	Latency time.Duration
+	// Status is the HTTP status code of the answer, 0 if none was received.
+	Status int
+	// Retries is the number of times the request was sent again, by the SDK or with the RetryPolicy.
+	Retries int
+	// Err is the error the call failed with, if any.
+	Err error
+}
+
+// WithObserver calls observer with an Event after every call to the backend.
+// Observers are called synchronously, from concurrent goroutines for concurrent calls.
+func WithObserver(observer func(Event)) Option {
+	return func(p *sssOption) error {
+		p.Observers = append(p.Observers, observer)
+		return nil
+	}
+}
+
+// observation collects what the SDK knows of the requests of a call.
+type observation struct {
+	sent    bool
+	status  int
+	retries int
+}
+
+func (o *observation) option(r *request.Request) {
+	r.Handlers.Complete.PushBack(func(r *request.Request) {
+		o.sent = true
+		o.status = r.HTTPResponse.StatusCode
+		o.retries += r.RetryCount
+	})
+}
+
+// observedBackend reports the calls to a Backend to observers.
+type observedBackend struct {
+	Backend
+	observers []func(Event)
+}
+
+func observeBackend(backend Backend, observers []func(Event)) Backend {
+	return &observedBackend{
+		Backend:   backend,
+		observers: observers,
+	}
+}
+
+// call runs fn with the context counting its retries and the option collecting its requests, and reports it.
+func (b *observedBackend) call(ctx aws.Context, operation, key string, opts []request.Option, fn func(ctx aws.Context, opts []request.Option) (int64, error)) {
+	var o observation
+	ctx, retries := withRetries(ctx)
+	start := time.Now()
+	n, err := fn(ctx, append(opts[:len(opts):len(opts)], o.option))
+	e := Event{
+		Operation: operation,
+		Key:       key,
+		Bytes:     n,
+		Latency:   time.Since(start),
+		Status:    o.status,
+		Retries:   o.retries + int(retries.Load()),
+		Err:       err,
+	}
+	if !o.sent {
+		// In-process backends answer with the status of their errors.
+		var reqErr awserr.RequestFailure
+		switch {
+		case errors.As(err, &reqErr):
+			e.Status = reqErr.StatusCode()
+		case err == nil:
+			e.Status = http.StatusOK
+		}
+	}
+	for _, observer := range b.observers {
+		observer(e)
+	}
+}
+
+// bodySize returns the bytes left to read in body.
+func bodySize(body io.ReadSeeker) int64 {
+	if body == nil {
+		return 0
+	}
+	cur, err := body.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0
+	}
+	end, err := body.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0
+	}
+	_, err = body.Seek(cur, io.SeekStart)
+	if err != nil {
+		return 0
+	}
+	return end - cur
+}
+
+func (b *observedBackend) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (out *s3.PutObjectOutput, err error) {
+	size := bodySize(input.Body)
+	b.call(ctx, "PutObject", aws.StringValue(input.Key), opts, func(ctx aws.Context, opts []request.Option) (int64, error) {
+		out, err = b.Backend.PutObjectWithContext(ctx, input, opts...)
+		return size, err
+	})
+	return out, err
+}
+
+func (b *observedBackend) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (out *s3.GetObjectOutput, err error) {
+	b.call(ctx, "GetObject", aws.StringValue(input.Key), opts, func(ctx aws.Context, opts []request.Option) (int64, error) {
+		out, err = b.Backend.GetObjectWithContext(ctx, input, opts...)
+		if err != nil {
+			return 0, err
+		}
+		return aws.Int64Value(out.ContentLength), nil
+	})
+	return out, err
+}
+
+func (b *observedBackend) HeadObjectWithContext(ctx aws.Context, input *s3.HeadObjectInput, opts ...request.Option) (out *s3.HeadObjectOutput, err error) {
+	b.call(ctx, "HeadObject", aws.StringValue(input.Key), opts, func(ctx aws.Context, opts []request.Option) (int64, error) {
+		out, err = b.Backend.HeadObjectWithContext(ctx, input, opts...)
+		return 0, err
+	})
+	return out, err
+}
+
+func (b *observedBackend) ListObjectsV2WithContext(ctx aws.Context, input *s3.ListObjectsV2Input, opts ...request.Option) (out *s3.ListObjectsV2Output, err error) {
+	b.call(ctx, "ListObjectsV2", aws.StringValue(input.Prefix), opts, func(ctx aws.Context, opts []request.Option) (int64, error) {
+		out, err = b.Backend.ListObjectsV2WithContext(ctx, input, opts...)
+		return 0, err
+	})
+	return out, err
+}
+
+func (b *observedBackend) ListObjectsV2PagesWithContext(ctx aws.Context, input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool, opts ...request.Option) (err error) {
+	b.call(ctx, "ListObjectsV2", aws.StringValue(input.Prefix), opts, func(ctx aws.Context, opts []request.Option) (int64, error) {
+		err = b.Backend.ListObjectsV2PagesWithContext(ctx, input, fn, opts...)
+		return 0, err
+	})
+	return err
+}
+
+func (b *observedBackend) DeleteObjectWithContext(ctx aws.Context, input *s3.DeleteObjectInput, opts ...request.Option) (out *s3.DeleteObjectOutput, err error) {
+	b.call(ctx, "DeleteObject", aws.StringValue(input.Key), opts, func(ctx aws.Context, opts []request.Option) (int64, error) {
+		out, err = b.Backend.DeleteObjectWithContext(ctx, input, opts...)
+		return 0, err
+	})
+	return out, err
+}
+
+func (b *observedBackend) DeleteObjectsWithContext(ctx aws.Context, input *s3.DeleteObjectsInput, opts ...request.Option) (out *s3.DeleteObjectsOutput, err error) {
+	b.call(ctx, "DeleteObjects", "", opts, func(ctx aws.Context, opts []request.Option) (int64, error) {
+		out, err = b.Backend.DeleteObjectsWithContext(ctx, input, opts...)
+		return 0, err
+	})
+	return out, err
+}
+
+func (b *observedBackend) CopyObjectWithContext(ctx aws.Context, input *s3.CopyObjectInput, opts ...request.Option) (out *s3.CopyObjectOutput, err error) {
+	b.call(ctx, "CopyObject", aws.StringValue(input.Key), opts, func(ctx aws.Context, opts []request.Option) (int64, error) {
+		out, err = b.Backend.CopyObjectWithContext(ctx, input, opts...)
+		return 0, err
+	})
+	return out, err
+}
+
+func (b *observedBackend) CreateMultipartUploadWithContext(ctx aws.Context, input *s3.CreateMultipartUploadInput, opts ...request.Option) (out *s3.CreateMultipartUploadOutput, err error) {
+	b.call(ctx, "CreateMultipartUpload", aws.StringValue(input.Key), opts, func(ctx aws.Context, opts []request.Option) (int64, error) {
+		out, err = b.Backend.CreateMultipartUploadWithContext(ctx, input, opts...)
+		return 0, err
+	})
+	return out, err
+}
+
+func (b *observedBackend) UploadPartWithContext(ctx aws.Context, input *s3.UploadPartInput, opts ...request.Option) (out *s3.UploadPartOutput, err error) {
+	size := bodySize(input.Body)
+	b.call(ctx, "UploadPart", aws.StringValue(input.Key), opts, func(ctx aws.Context, opts []request.Option) (int64, error) {
+		out, err = b.Backend.UploadPartWithContext(ctx, input, opts...)
+		return size, err
+	})
+	return out, err
+}
+
+func (b *observedBackend) UploadPartCopyWithContext(ctx aws.Context, input *s3.UploadPartCopyInput, opts ...request.Option) (out *s3.UploadPartCopyOutput, err error) {
+	b.call(ctx, "UploadPartCopy", aws.StringValue(input.Key), opts, func(ctx aws.Context, opts []request.Option) (int64, error) {
+		out, err = b.Backend.UploadPartCopyWithContext(ctx, input, opts...)
+		return 0, err
+	})
+	return out, err
+}
+
+func (b *observedBackend) CompleteMultipartUploadWithContext(ctx aws.Context, input *s3.CompleteMultipartUploadInput, opts ...request.Option) (out *s3.CompleteMultipartUploadOutput, err error) {
+	b.call(ctx, "CompleteMultipartUpload", aws.StringValue(input.Key), opts, func(ctx aws.Context, opts []request.Option) (int64, error) {
+		out, err = b.Backend.CompleteMultipartUploadWithContext(ctx, input, opts...)
+		return 0, err
+	})
+	return out, err
+}
+
+func (b *observedBackend) AbortMultipartUploadWithContext(ctx aws.Context, input *s3.AbortMultipartUploadInput, opts ...request.Option) (out *s3.AbortMultipartUploadOutput, err error) {
+	b.call(ctx, "AbortMultipartUpload", aws.StringValue(input.Key), opts, func(ctx aws.Context, opts []request.Option) (int64, error) {
+		out, err = b.Backend.AbortMultipartUploadWithContext(ctx, input, opts...)
+		return 0, err
+	})
+	return out, err
+}
+
+func (b *observedBackend) ListPartsPagesWithContext(ctx aws.Context, input *s3.ListPartsInput, fn func(*s3.ListPartsOutput, bool) bool, opts ...request.Option) (err error) {
+	b.call(ctx, "ListParts", aws.StringValue(input.Key), opts, func(ctx aws.Context, opts []request.Option) (int64, error) {
+		err = b.Backend.ListPartsPagesWithContext(ctx, input, fn, opts...)
+		return 0, err
+	})
+	return err
+}
+
+func (b *observedBackend) ListMultipartUploadsPagesWithContext(ctx aws.Context, input *s3.ListMultipartUploadsInput, fn func(*s3.ListMultipartUploadsOutput, bool) bool, opts ...request.Option) (err error) {
+	b.call(ctx, "ListMultipartUploads", aws.StringValue(input.Prefix), opts, func(ctx aws.Context, opts []request.Option) (int64, error) {
+		err = b.Backend.ListMultipartUploadsPagesWithContext(ctx, input, fn, opts...)
+		return 0, err
+	})
+	return err
+}
+
+func (b *observedBackend) GetObjectTaggingWithContext(ctx aws.Context, input *s3.GetObjectTaggingInput, opts ...request.Option) (out *s3.GetObjectTaggingOutput, err error) {
+	b.call(ctx, "GetObjectTagging", aws.StringValue(input.Key), opts, func(ctx aws.Context, opts []request.Option) (int64, error) {
+		out, err = b.Backend.GetObjectTaggingWithContext(ctx, input, opts...)
+		return 0, err
+	})
+	return out, err
+}
+
+func (b *observedBackend) PutObjectTaggingWithContext(ctx aws.Context, input *s3.PutObjectTaggingInput, opts ...request.Option) (out *s3.PutObjectTaggingOutput, err error) {
+	b.call(ctx, "PutObjectTagging", aws.StringValue(input.Key), opts, func(ctx aws.Context, opts []request.Option) (int64, error) {
+		out, err = b.Backend.PutObjectTaggingWithContext(ctx, input, opts...)
+		return 0, err
+	})
+	return out, err
+}
+
+func (b *observedBackend) DeleteObjectTaggingWithContext(ctx aws.Context, input *s3.DeleteObjectTaggingInput, opts ...request.Option) (out *s3.DeleteObjectTaggingOutput, err error) {
+	b.call(ctx, "DeleteObjectTagging", aws.StringValue(input.Key), opts, func(ctx aws.Context, opts []request.Option) (int64, error) {
+		out, err = b.Backend.DeleteObjectTaggingWithContext(ctx, input, opts...)
+		return 0, err
+	})
+	return out, err
+}
+
+func (b *observedBackend) ListObjectVersionsPagesWithContext(ctx aws.Context, input *s3.ListObjectVersionsInput, fn func(*s3.ListObjectVersionsOutput, bool) bool, opts ...request.Option) (err error) {
+	b.call(ctx, "ListObjectVersions", aws.StringValue(input.Prefix), opts, func(ctx aws.Context, opts []request.Option) (int64, error) {
+		err = b.Backend.ListObjectVersionsPagesWithContext(ctx, input, fn, opts...)
+		return 0, err
+	})
+	return err
+}
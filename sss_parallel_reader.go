@@ -0,0 +1,216 @@
+package sss
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const defaultReadWindow = 4
+
+type parallelReaderOption struct {
+	Offset    int64
+	ChunkSize int64
+	Window    int
+	VersionID string
+}
+
+type ParallelReaderOptions func(*parallelReaderOption)
+
+// WithReadOffset starts reading at offset instead of the beginning of the object.
+func WithReadOffset(offset int64) ParallelReaderOptions {
+	return func(o *parallelReaderOption) {
+		o.Offset = offset
+	}
+}
+
+// WithReadChunkSize sets the size of each ranged request, it defaults to the chunk size of SSS.
+func WithReadChunkSize(size int64) ParallelReaderOptions {
+	return func(o *parallelReaderOption) {
+		o.ChunkSize = size
+	}
+}
+
+// WithReadWindow sets the number of chunks fetched ahead of the reader,
+// which bounds the memory held to window times the chunk size.
+func WithReadWindow(n int) ParallelReaderOptions {
+	return func(o *parallelReaderOption) {
+		o.Window = n
+	}
+}
+
+// WithReadVersionID reads the given version of the object instead of the latest one.
+func WithReadVersionID(versionID string) ParallelReaderOptions {
+	return func(o *parallelReaderOption) {
+		o.VersionID = versionID
+	}
+}
+
+// ParallelReader reads the object at path with concurrent ranged requests,
+// returning the bytes in order.
+func (s *SSS) ParallelReader(ctx context.Context, path string, opts ...ParallelReaderOptions) (io.ReadCloser, error) {
+	o := parallelReaderOption{
+		ChunkSize: int64(s.chunkSize),
+		Window:    defaultReadWindow,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.ChunkSize <= 0 {
+		o.ChunkSize = int64(s.chunkSize)
+	}
+	if o.Window <= 0 {
+		o.Window = 1
+	}
+
+	info, err := s.StatHead(ctx, path, WithVersionID(o.VersionID))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	r := &parallelReader{
+		ctx:       ctx,
+		cancel:    cancel,
+		driver:    s,
+		path:      path,
+		key:       s.s3Path(path),
+		versionID: objectOption{VersionID: o.VersionID}.versionID(),
+		etag:      info.Sys().(FileInfoExpansion).ETag,
+		offset:    o.Offset,
+		size:      info.Size(),
+		chunkSize: o.ChunkSize,
+	}
+	for i := 0; i < o.Window; i++ {
+		r.fetchNext()
+	}
+	return r, nil
+}
+
+type parallelReader struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	driver    *SSS
+	path      string
+	key       string
+	versionID *string
+	// etag pins the chunks to the object that was stated.
+	etag      *string
+	offset    int64
+	size      int64
+	chunkSize int64
+
+	wg      sync.WaitGroup
+	pending []*chunk
+	closed  bool
+}
+
+// chunk is a range of the object being fetched, buf is ready once done is closed.
+type chunk struct {
+	done chan struct{}
+	buf  *bytes.Buffer
+	err  error
+}
+
+// fetchNext starts fetching the chunk after the last pending one, if any is left.
+func (r *parallelReader) fetchNext() {
+	if r.offset >= r.size {
+		return
+	}
+	start := r.offset
+	end := min(start+r.chunkSize, r.size) - 1
+	r.offset = end + 1
+
+	c := &chunk{
+		done: make(chan struct{}),
+		buf:  r.driver.pool.Get().(*bytes.Buffer),
+	}
+	c.buf.Reset()
+	r.pending = append(r.pending, c)
+
+	r.wg.Add(1)
+	go func() {
+		defer func() {
+			close(c.done)
+			r.wg.Done()
+		}()
+
+		resp, err := r.driver.getObject(r.ctx, &s3.GetObjectInput{
+			Bucket:    r.driver.getBucket(),
+			Key:       aws.String(r.key),
+			Range:     aws.String("bytes=" + strconv.FormatInt(start, 10) + "-" + strconv.FormatInt(end, 10)),
+			VersionId: r.versionID,
+			IfMatch:   r.etag,
+
+			SSECustomerAlgorithm: r.driver.getSSECustomerAlgorithm(),
+			SSECustomerKey:       r.driver.getSSECustomerKey(),
+		})
+		if err != nil {
+			c.err = parseReadError(r.path, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		n, err := io.Copy(c.buf, resp.Body)
+		if err != nil {
+			c.err = err
+			return
+		}
+		if n != end-start+1 {
+			c.err = fmt.Errorf("range %d-%d: got %d bytes: %w", start, end, n, io.ErrUnexpectedEOF)
+		}
+	}()
+}
+
+func (r *parallelReader) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, fmt.Errorf("already closed")
+	}
+
+	for len(r.pending) != 0 {
+		c := r.pending[0]
+		select {
+		case <-c.done:
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		}
+		if c.err != nil {
+			return 0, c.err
+		}
+		if c.buf.Len() != 0 {
+			return c.buf.Read(p)
+		}
+
+		r.pending = r.pending[1:]
+		r.release(c)
+		r.fetchNext()
+	}
+	return 0, io.EOF
+}
+
+// Close stops the fetches in flight and releases their buffers.
+func (r *parallelReader) Close() error {
+	if r.closed {
+		return fmt.Errorf("already closed")
+	}
+	r.closed = true
+
+	r.cancel()
+	r.wg.Wait()
+	for _, c := range r.pending {
+		r.release(c)
+	}
+	r.pending = nil
+	return nil
+}
+
+func (r *parallelReader) release(c *chunk) {
+	c.buf.Reset()
+	r.driver.pool.Put(c.buf)
+}
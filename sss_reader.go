@@ -8,23 +8,22 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/s3"
 )
 
+// SignGet returns a pre-signed URL, with WithSSECustomerKey its holder has to send
+// the same x-amz-server-side-encryption-customer-* headers along.
 func (s *SSS) SignGet(path string, expires time.Duration) (string, error) {
-	return s.presign(expires,
-		func(c *s3.S3) *request.Request {
-			req, _ := c.GetObjectRequest(&s3.GetObjectInput{
-				Bucket: s.getBucket(),
-				Key:    aws.String(s.s3Path(path)),
-			})
-			return req
-		})
+	return s.presign(expires, &s3.GetObjectInput{
+		Bucket:               s.getBucket(),
+		Key:                  aws.String(s.s3Path(path)),
+		SSECustomerAlgorithm: s.getSSECustomerAlgorithm(),
+		SSECustomerKey:       s.getSSECustomerKey(),
+	})
 }
 
-func (s *SSS) GetContent(ctx context.Context, path string) ([]byte, error) {
-	reader, err := s.Reader(ctx, path)
+func (s *SSS) GetContent(ctx context.Context, path string, opts ...ObjectOptions) ([]byte, error) {
+	reader, err := s.Reader(ctx, path, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -33,8 +32,8 @@ func (s *SSS) GetContent(ctx context.Context, path string) ([]byte, error) {
 	return io.ReadAll(reader)
 }
 
-func (s *SSS) GetContentAndInfo(ctx context.Context, path string) ([]byte, FileInfo, error) {
-	reader, info, err := s.ReaderAndInfo(ctx, path)
+func (s *SSS) GetContentAndInfo(ctx context.Context, path string, opts ...ObjectOptions) ([]byte, FileInfo, error) {
+	reader, info, err := s.ReaderAndInfo(ctx, path, opts...)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -48,40 +47,50 @@ func (s *SSS) GetContentAndInfo(ctx context.Context, path string) ([]byte, FileI
 	return content, info, nil
 }
 
-func (s *SSS) Reader(ctx context.Context, path string) (io.ReadCloser, error) {
-	return s.ReaderWithOffset(ctx, path, 0)
+func (s *SSS) Reader(ctx context.Context, path string, opts ...ObjectOptions) (io.ReadCloser, error) {
+	return s.ReaderWithOffset(ctx, path, 0, opts...)
 }
 
-func (s *SSS) ReaderAndInfo(ctx context.Context, path string) (io.ReadCloser, FileInfo, error) {
-	return s.ReaderWithOffsetAndInfo(ctx, path, 0)
+func (s *SSS) ReaderAndInfo(ctx context.Context, path string, opts ...ObjectOptions) (io.ReadCloser, FileInfo, error) {
+	return s.ReaderWithOffsetAndInfo(ctx, path, 0, opts...)
 }
 
-func (s *SSS) ReaderWithOffset(ctx context.Context, path string, offset int64) (io.ReadCloser, error) {
+func (s *SSS) ReaderWithOffset(ctx context.Context, path string, offset int64, opts ...ObjectOptions) (io.ReadCloser, error) {
+	o := newObjectOption(opts)
 	getObjectInput := &s3.GetObjectInput{
-		Bucket: s.getBucket(),
-		Key:    aws.String(s.s3Path(path)),
+		Bucket:               s.getBucket(),
+		Key:                  aws.String(s.s3Path(path)),
+		VersionId:            o.versionID(),
+		IfMatch:              o.ifMatch(),
+		SSECustomerAlgorithm: s.getSSECustomerAlgorithm(),
+		SSECustomerKey:       s.getSSECustomerKey(),
 	}
 	if offset > 0 {
 		getObjectInput.Range = aws.String("bytes=" + strconv.FormatInt(offset, 10) + "-")
 	}
-	resp, err := s.s3.GetObjectWithContext(ctx, getObjectInput)
+	resp, err := s.getObject(ctx, getObjectInput)
 	if err != nil {
-		return nil, parseError(path, err)
+		return nil, parseReadError(path, err)
 	}
-	return resp.Body, nil
+	return &readerBody{ReadCloser: resp.Body, path: path}, nil
 }
 
-func (s *SSS) ReaderWithOffsetAndInfo(ctx context.Context, path string, offset int64) (io.ReadCloser, FileInfo, error) {
+func (s *SSS) ReaderWithOffsetAndInfo(ctx context.Context, path string, offset int64, opts ...ObjectOptions) (io.ReadCloser, FileInfo, error) {
+	o := newObjectOption(opts)
 	getObjectInput := &s3.GetObjectInput{
-		Bucket: s.getBucket(),
-		Key:    aws.String(s.s3Path(path)),
+		Bucket:               s.getBucket(),
+		Key:                  aws.String(s.s3Path(path)),
+		VersionId:            o.versionID(),
+		IfMatch:              o.ifMatch(),
+		SSECustomerAlgorithm: s.getSSECustomerAlgorithm(),
+		SSECustomerKey:       s.getSSECustomerKey(),
 	}
 	if offset > 0 {
 		getObjectInput.Range = aws.String("bytes=" + strconv.FormatInt(offset, 10) + "-")
 	}
-	resp, err := s.s3.GetObjectWithContext(ctx, getObjectInput)
+	resp, err := s.getObject(ctx, getObjectInput)
 	if err != nil {
-		return nil, nil, parseError(path, err)
+		return nil, nil, parseReadError(path, err)
 	}
 
 	info := &fileInfo{
@@ -95,31 +104,38 @@ func (s *SSS) ReaderWithOffsetAndInfo(ctx context.Context, path string, offset i
 			AcceptRanges:       resp.AcceptRanges,
 			ETag:               resp.ETag,
 			Expires:            resp.Expires,
+			VersionID:          resp.VersionId,
+			Metadata:           userMetadata(resp.Metadata),
 		},
 	}
 
-	return resp.Body, info, nil
+	return &readerBody{ReadCloser: resp.Body, path: path}, info, nil
 }
 
-func (s *SSS) ReaderWithOffsetAndLimit(ctx context.Context, path string, offset, limit int64) (io.ReadCloser, error) {
+func (s *SSS) ReaderWithOffsetAndLimit(ctx context.Context, path string, offset, limit int64, opts ...ObjectOptions) (io.ReadCloser, error) {
 	if limit <= 0 {
 		return io.NopCloser(bytes.NewBuffer(nil)), nil
 	}
+	o := newObjectOption(opts)
 	getObjectInput := &s3.GetObjectInput{
-		Bucket: s.getBucket(),
-		Key:    aws.String(s.s3Path(path)),
+		Bucket:               s.getBucket(),
+		Key:                  aws.String(s.s3Path(path)),
+		VersionId:            o.versionID(),
+		IfMatch:              o.ifMatch(),
+		SSECustomerAlgorithm: s.getSSECustomerAlgorithm(),
+		SSECustomerKey:       s.getSSECustomerKey(),
 	}
 	if offset > 0 {
 		getObjectInput.Range = aws.String("bytes=" + strconv.FormatInt(offset, 10) + "-" + strconv.FormatInt(offset+limit-1, 10))
 	}
-	resp, err := s.s3.GetObjectWithContext(ctx, getObjectInput)
+	resp, err := s.getObject(ctx, getObjectInput)
 	if err != nil {
-		return nil, parseError(path, err)
+		return nil, parseReadError(path, err)
 	}
-	return resp.Body, nil
+	return &readerBody{ReadCloser: resp.Body, path: path}, nil
 }
 
-func (s *SSS) ReaderWithOffsetAndLimitAndInfo(ctx context.Context, path string, offset, limit int64) (io.ReadCloser, FileInfo, error) {
+func (s *SSS) ReaderWithOffsetAndLimitAndInfo(ctx context.Context, path string, offset, limit int64, opts ...ObjectOptions) (io.ReadCloser, FileInfo, error) {
 	if limit <= 0 {
 		return io.NopCloser(bytes.NewBuffer(nil)), &fileInfo{
 			path:  path,
@@ -127,16 +143,21 @@ func (s *SSS) ReaderWithOffsetAndLimitAndInfo(ctx context.Context, path string,
 			size:  0,
 		}, nil
 	}
+	o := newObjectOption(opts)
 	getObjectInput := &s3.GetObjectInput{
-		Bucket: s.getBucket(),
-		Key:    aws.String(s.s3Path(path)),
+		Bucket:               s.getBucket(),
+		Key:                  aws.String(s.s3Path(path)),
+		VersionId:            o.versionID(),
+		IfMatch:              o.ifMatch(),
+		SSECustomerAlgorithm: s.getSSECustomerAlgorithm(),
+		SSECustomerKey:       s.getSSECustomerKey(),
 	}
 	if offset > 0 {
 		getObjectInput.Range = aws.String("bytes=" + strconv.FormatInt(offset, 10) + "-" + strconv.FormatInt(offset+limit-1, 10))
 	}
-	resp, err := s.s3.GetObjectWithContext(ctx, getObjectInput)
+	resp, err := s.getObject(ctx, getObjectInput)
 	if err != nil {
-		return nil, nil, parseError(path, err)
+		return nil, nil, parseReadError(path, err)
 	}
 
 	info := &fileInfo{
@@ -150,8 +171,24 @@ func (s *SSS) ReaderWithOffsetAndLimitAndInfo(ctx context.Context, path string,
 			AcceptRanges:       resp.AcceptRanges,
 			ETag:               resp.ETag,
 			Expires:            resp.Expires,
+			VersionID:          resp.VersionId,
+			Metadata:           userMetadata(resp.Metadata),
 		},
 	}
 
-	return resp.Body, info, nil
+	return &readerBody{ReadCloser: resp.Body, path: path}, info, nil
+}
+
+// readerBody is the body of the object at path, failing with the errors of parseReadError.
+type readerBody struct {
+	io.ReadCloser
+	path string
+}
+
+func (r *readerBody) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		err = parseReadError(r.path, err)
+	}
+	return n, err
 }
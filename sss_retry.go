@@ -0,0 +1,332 @@
+package sss
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const (
+	defaultRetryMinDelay = 100 * time.Millisecond
+	defaultRetryMaxDelay = 20 * time.Second
+)
+
+// RetryPolicy tells how failed requests are sent again.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a request is sent at most, the first one included.
+	MaxAttempts int
+	// MinDelay is the delay before the first retry, it doubles with each retry up to MaxDelay,
+	// and a random half of it is taken off to spread the retries of concurrent requests.
+	MinDelay time.Duration
+	MaxDelay time.Duration
+	// Retryable reports whether a request failing with the error is worth sending again,
+	// it defaults to DefaultRetryable.
+	Retryable func(error) bool
+}
+
+// WithRetryPolicy retries every request to the backend with the given policy.
+// The requests to S3 are retried by the SDK instead of with its own policy,
+// those to other backends by SSS around each call.
+// Parts of uploads are retried one by one from the data buffered for them,
+// so that a long upload outlives the throttling of a single part.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(p *sssOption) error {
+		p.RetryPolicy = &policy
+		return nil
+	}
+}
+
+// DefaultRetryable reports whether err is a throttling, a server error or a network failure.
+func DefaultRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		switch awsErr.Code() {
+		case "SlowDown", "ServiceUnavailable", "InternalError", "RequestTimeout":
+			return true
+		case request.CanceledErrorCode:
+			return false
+		}
+		if request.IsErrorThrottle(awsErr) || request.IsErrorRetryable(awsErr) {
+			return true
+		}
+	}
+	var reqErr awserr.RequestFailure
+	if errors.As(err, &reqErr) {
+		status := reqErr.StatusCode()
+		return status == http.StatusTooManyRequests || (status >= 500 && status != http.StatusNotImplemented)
+	}
+	return false
+}
+
+func (p RetryPolicy) withDefaults() *RetryPolicy {
+	if p.MaxAttempts < 1 {
+		p.MaxAttempts = 1
+	}
+	if p.MinDelay <= 0 {
+		p.MinDelay = defaultRetryMinDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = max(p.MinDelay, defaultRetryMaxDelay)
+	}
+	if p.Retryable == nil {
+		p.Retryable = DefaultRetryable
+	}
+	return &p
+}
+
+// delay returns how long to wait before the given retry, the first being 0.
+func (p *RetryPolicy) delay(retry int) time.Duration {
+	d := p.MaxDelay
+	if retry < 32 {
+		d = min(p.MinDelay<<retry, p.MaxDelay)
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// do calls fn until it succeeds, fails with an error not worth retrying, or runs out of attempts.
+// A nil policy calls fn once.
+func (p *RetryPolicy) do(ctx context.Context, fn func() error) error {
+	for retry := 0; ; retry++ {
+		err := fn()
+		if err == nil || p == nil || retry+1 >= p.MaxAttempts || !p.Retryable(err) {
+			return err
+		}
+
+		t := time.NewTimer(p.delay(retry))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+		if n, ok := ctx.Value(retriesKey{}).(*atomic.Int64); ok {
+			n.Add(1)
+		}
+	}
+}
+
+type retriesKey struct{}
+
+// withRetries returns ctx counting the retries of do, for the Retries of an Event.
+func withRetries(ctx context.Context) (context.Context, *atomic.Int64) {
+	var n atomic.Int64
+	return context.WithValue(ctx, retriesKey{}, &n), &n
+}
+
+// sdkRetryer has the SDK retry the requests to S3 with the policy.
+type sdkRetryer struct {
+	policy *RetryPolicy
+}
+
+func (r sdkRetryer) MaxRetries() int {
+	return r.policy.MaxAttempts - 1
+}
+
+func (r sdkRetryer) ShouldRetry(req *request.Request) bool {
+	return r.policy.Retryable(req.Error)
+}
+
+func (r sdkRetryer) RetryRules(req *request.Request) time.Duration {
+	return r.policy.delay(req.RetryCount)
+}
+
+// rewinder returns a function seeking body back to where it is now.
+func rewinder(body io.ReadSeeker) (func() error, error) {
+	if body == nil {
+		return func() error { return nil }, nil
+	}
+	start, err := body.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		_, err := body.Seek(start, io.SeekStart)
+		return err
+	}, nil
+}
+
+// retriedBackend retries the calls to a backend without the retries of the SDK with a policy.
+type retriedBackend struct {
+	Backend
+	policy *RetryPolicy
+}
+
+func retryBackend(backend Backend, policy *RetryPolicy) Backend {
+	return &retriedBackend{
+		Backend: backend,
+		policy:  policy,
+	}
+}
+
+// retry calls fn as the policy allows, returning the output of the last attempt.
+func retry[T any](ctx context.Context, policy *RetryPolicy, fn func() (T, error)) (T, error) {
+	var out T
+	err := policy.do(ctx, func() error {
+		var err error
+		out, err = fn()
+		return err
+	})
+	return out, err
+}
+
+// retryBody is retry for a call sending body, which is sought back before each retry.
+func retryBody[T any](ctx context.Context, policy *RetryPolicy, body io.ReadSeeker, fn func() (T, error)) (T, error) {
+	rewind, err := rewinder(body)
+	if err != nil {
+		var out T
+		return out, err
+	}
+	first := true
+	return retry(ctx, policy, func() (T, error) {
+		if !first {
+			err := rewind()
+			if err != nil {
+				var out T
+				return out, err
+			}
+		}
+		first = false
+		return fn()
+	})
+}
+
+// retryPages is retry for a paginated call, which is only sent again until fn is given a page.
+func retryPages[P any](ctx context.Context, policy *RetryPolicy, fn func(P, bool) bool, call func(func(P, bool) bool) error) error {
+	called := false
+	p := *policy
+	p.Retryable = func(err error) bool {
+		return !called && policy.Retryable(err)
+	}
+	return p.do(ctx, func() error {
+		return call(func(page P, lastPage bool) bool {
+			called = true
+			return fn(page, lastPage)
+		})
+	})
+}
+
+func (b *retriedBackend) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	return retryBody(ctx, b.policy, input.Body, func() (*s3.PutObjectOutput, error) {
+		return b.Backend.PutObjectWithContext(ctx, input, opts...)
+	})
+}
+
+func (b *retriedBackend) UploadPartWithContext(ctx aws.Context, input *s3.UploadPartInput, opts ...request.Option) (*s3.UploadPartOutput, error) {
+	return retryBody(ctx, b.policy, input.Body, func() (*s3.UploadPartOutput, error) {
+		return b.Backend.UploadPartWithContext(ctx, input, opts...)
+	})
+}
+
+func (b *retriedBackend) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	return retry(ctx, b.policy, func() (*s3.GetObjectOutput, error) {
+		return b.Backend.GetObjectWithContext(ctx, input, opts...)
+	})
+}
+
+func (b *retriedBackend) HeadObjectWithContext(ctx aws.Context, input *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	return retry(ctx, b.policy, func() (*s3.HeadObjectOutput, error) {
+		return b.Backend.HeadObjectWithContext(ctx, input, opts...)
+	})
+}
+
+func (b *retriedBackend) ListObjectsV2WithContext(ctx aws.Context, input *s3.ListObjectsV2Input, opts ...request.Option) (*s3.ListObjectsV2Output, error) {
+	return retry(ctx, b.policy, func() (*s3.ListObjectsV2Output, error) {
+		return b.Backend.ListObjectsV2WithContext(ctx, input, opts...)
+	})
+}
+
+func (b *retriedBackend) ListObjectsV2PagesWithContext(ctx aws.Context, input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool, opts ...request.Option) error {
+	return retryPages(ctx, b.policy, fn, func(fn func(*s3.ListObjectsV2Output, bool) bool) error {
+		return b.Backend.ListObjectsV2PagesWithContext(ctx, input, fn, opts...)
+	})
+}
+
+func (b *retriedBackend) DeleteObjectWithContext(ctx aws.Context, input *s3.DeleteObjectInput, opts ...request.Option) (*s3.DeleteObjectOutput, error) {
+	return retry(ctx, b.policy, func() (*s3.DeleteObjectOutput, error) {
+		return b.Backend.DeleteObjectWithContext(ctx, input, opts...)
+	})
+}
+
+func (b *retriedBackend) DeleteObjectsWithContext(ctx aws.Context, input *s3.DeleteObjectsInput, opts ...request.Option) (*s3.DeleteObjectsOutput, error) {
+	return retry(ctx, b.policy, func() (*s3.DeleteObjectsOutput, error) {
+		return b.Backend.DeleteObjectsWithContext(ctx, input, opts...)
+	})
+}
+
+func (b *retriedBackend) CopyObjectWithContext(ctx aws.Context, input *s3.CopyObjectInput, opts ...request.Option) (*s3.CopyObjectOutput, error) {
+	return retry(ctx, b.policy, func() (*s3.CopyObjectOutput, error) {
+		return b.Backend.CopyObjectWithContext(ctx, input, opts...)
+	})
+}
+
+func (b *retriedBackend) CreateMultipartUploadWithContext(ctx aws.Context, input *s3.CreateMultipartUploadInput, opts ...request.Option) (*s3.CreateMultipartUploadOutput, error) {
+	return retry(ctx, b.policy, func() (*s3.CreateMultipartUploadOutput, error) {
+		return b.Backend.CreateMultipartUploadWithContext(ctx, input, opts...)
+	})
+}
+
+func (b *retriedBackend) UploadPartCopyWithContext(ctx aws.Context, input *s3.UploadPartCopyInput, opts ...request.Option) (*s3.UploadPartCopyOutput, error) {
+	return retry(ctx, b.policy, func() (*s3.UploadPartCopyOutput, error) {
+		return b.Backend.UploadPartCopyWithContext(ctx, input, opts...)
+	})
+}
+
+func (b *retriedBackend) CompleteMultipartUploadWithContext(ctx aws.Context, input *s3.CompleteMultipartUploadInput, opts ...request.Option) (*s3.CompleteMultipartUploadOutput, error) {
+	return retry(ctx, b.policy, func() (*s3.CompleteMultipartUploadOutput, error) {
+		return b.Backend.CompleteMultipartUploadWithContext(ctx, input, opts...)
+	})
+}
+
+func (b *retriedBackend) AbortMultipartUploadWithContext(ctx aws.Context, input *s3.AbortMultipartUploadInput, opts ...request.Option) (*s3.AbortMultipartUploadOutput, error) {
+	return retry(ctx, b.policy, func() (*s3.AbortMultipartUploadOutput, error) {
+		return b.Backend.AbortMultipartUploadWithContext(ctx, input, opts...)
+	})
+}
+
+func (b *retriedBackend) ListPartsPagesWithContext(ctx aws.Context, input *s3.ListPartsInput, fn func(*s3.ListPartsOutput, bool) bool, opts ...request.Option) error {
+	return retryPages(ctx, b.policy, fn, func(fn func(*s3.ListPartsOutput, bool) bool) error {
+		return b.Backend.ListPartsPagesWithContext(ctx, input, fn, opts...)
+	})
+}
+
+func (b *retriedBackend) ListMultipartUploadsPagesWithContext(ctx aws.Context, input *s3.ListMultipartUploadsInput, fn func(*s3.ListMultipartUploadsOutput, bool) bool, opts ...request.Option) error {
+	return retryPages(ctx, b.policy, fn, func(fn func(*s3.ListMultipartUploadsOutput, bool) bool) error {
+		return b.Backend.ListMultipartUploadsPagesWithContext(ctx, input, fn, opts...)
+	})
+}
+
+func (b *retriedBackend) GetObjectTaggingWithContext(ctx aws.Context, input *s3.GetObjectTaggingInput, opts ...request.Option) (*s3.GetObjectTaggingOutput, error) {
+	return retry(ctx, b.policy, func() (*s3.GetObjectTaggingOutput, error) {
+		return b.Backend.GetObjectTaggingWithContext(ctx, input, opts...)
+	})
+}
+
+func (b *retriedBackend) PutObjectTaggingWithContext(ctx aws.Context, input *s3.PutObjectTaggingInput, opts ...request.Option) (*s3.PutObjectTaggingOutput, error) {
+	return retry(ctx, b.policy, func() (*s3.PutObjectTaggingOutput, error) {
+		return b.Backend.PutObjectTaggingWithContext(ctx, input, opts...)
+	})
+}
+
+func (b *retriedBackend) DeleteObjectTaggingWithContext(ctx aws.Context, input *s3.DeleteObjectTaggingInput, opts ...request.Option) (*s3.DeleteObjectTaggingOutput, error) {
+	return retry(ctx, b.policy, func() (*s3.DeleteObjectTaggingOutput, error) {
+		return b.Backend.DeleteObjectTaggingWithContext(ctx, input, opts...)
+	})
+}
+
+func (b *retriedBackend) ListObjectVersionsPagesWithContext(ctx aws.Context, input *s3.ListObjectVersionsInput, fn func(*s3.ListObjectVersionsOutput, bool) bool, opts ...request.Option) error {
+	return retryPages(ctx, b.policy, fn, func(fn func(*s3.ListObjectVersionsOutput, bool) bool) error {
+		return b.Backend.ListObjectVersionsPagesWithContext(ctx, input, fn, opts...)
+	})
+}
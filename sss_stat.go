@@ -3,38 +3,45 @@ package sss
 import (
 	"context"
 	"errors"
-	"fmt"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/s3"
 )
 
 func (s *SSS) SignHead(path string, expires time.Duration) (string, error) {
-	return s.presign(expires,
-		func(c *s3.S3) *request.Request {
-			req, _ := c.HeadObjectRequest(&s3.HeadObjectInput{
-				Bucket: s.getBucket(),
-				Key:    aws.String(s.s3Path(path)),
-			})
-			return req
-		})
+	return s.presign(expires, &s3.HeadObjectInput{
+		Bucket:               s.getBucket(),
+		Key:                  aws.String(s.s3Path(path)),
+		SSECustomerAlgorithm: s.getSSECustomerAlgorithm(),
+		SSECustomerKey:       s.getSSECustomerKey(),
+	})
 }
 
-func (s *SSS) StatHead(ctx context.Context, path string) (FileInfo, error) {
-	resp, err := s.s3.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
-		Bucket: s.getBucket(),
-		Key:    aws.String(s.s3Path(path)),
+func (s *SSS) StatHead(ctx context.Context, path string, opts ...ObjectOptions) (FileInfo, error) {
+	o := newObjectOption(opts)
+	resp, err := s.backend.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket:               s.getBucket(),
+		Key:                  aws.String(s.s3Path(path)),
+		VersionId:            o.versionID(),
+		SSECustomerAlgorithm: s.getSSECustomerAlgorithm(),
+		SSECustomerKey:       s.getSSECustomerKey(),
 	})
 	if err != nil {
-		return nil, err
+		return nil, parseError(path, err)
+	}
+	size := *resp.ContentLength
+	if s.cse != nil {
+		size, err = cseObjectSize(resp.Metadata, size)
+		if err != nil {
+			return nil, err
+		}
 	}
 	return &fileInfo{
 		path:    path,
 		isDir:   false,
-		size:    *resp.ContentLength,
+		size:    size,
 		modTime: *resp.LastModified,
 		sys: FileInfoExpansion{
 			ContentType:        resp.ContentType,
@@ -42,19 +49,21 @@ func (s *SSS) StatHead(ctx context.Context, path string) (FileInfo, error) {
 			AcceptRanges:       resp.AcceptRanges,
 			ETag:               resp.ETag,
 			Expires:            resp.Expires,
+			VersionID:          resp.VersionId,
+			Metadata:           userMetadata(resp.Metadata),
 		},
 	}, nil
 }
 
 func (s *SSS) StatHeadList(ctx context.Context, path string) (FileInfo, error) {
 	s3Path := s.s3Path(path)
-	resp, err := s.s3.ListObjectsV2WithContext(ctx, &s3.ListObjectsV2Input{
+	resp, err := s.backend.ListObjectsV2WithContext(ctx, &s3.ListObjectsV2Input{
 		Bucket:  s.getBucket(),
 		Prefix:  aws.String(s3Path),
 		MaxKeys: aws.Int64(1),
 	})
 	if err != nil {
-		return nil, err
+		return nil, parseError(path, err)
 	}
 	if len(resp.Contents) == 1 {
 		if *resp.Contents[0].Key != s3Path {
@@ -75,14 +84,19 @@ func (s *SSS) StatHeadList(ctx context.Context, path string) (FileInfo, error) {
 			isDir: true,
 		}, nil
 	}
-	return nil, fmt.Errorf("path not found: %s", path)
+	return nil, &Error{Path: path, Err: ErrNotExist}
 }
 
 // Stat retrieves the FileInfo for the given path, including the current size
 // in bytes and the creation time.
-func (s *SSS) Stat(ctx context.Context, path string) (FileInfo, error) {
-	fi, err := s.StatHead(ctx, path)
+func (s *SSS) Stat(ctx context.Context, path string, opts ...ObjectOptions) (FileInfo, error) {
+	fi, err := s.StatHead(ctx, path, opts...)
 	if err != nil {
+		if newObjectOption(opts).VersionID != "" {
+			// Versions only exist for objects, there is nothing to list.
+			return nil, parseError(path, err)
+		}
+
 		// For AWS errors, we fail over to ListObjects:
 		// Though the official docs https://docs.aws.amazon.com/AmazonS3/latest/API/API_HeadObject.html#API_HeadObject_Errors
 		// are slightly outdated, the HeadObject actually returns NotFound error
@@ -0,0 +1,93 @@
+package sss
+
+import (
+	"context"
+	"errors"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// WithTags sets the tags of the object being written.
+// On Copy it replaces the tags of the source.
+func WithTags(tags map[string]string) WriterOptions {
+	return func(o *writerOption) {
+		o.Tagging = aws.String(encodeTags(tags))
+	}
+}
+
+// encodeTags returns tags in the form of the x-amz-tagging header.
+func encodeTags(tags map[string]string) string {
+	values := url.Values{}
+	for k, v := range tags {
+		values.Set(k, v)
+	}
+	return values.Encode()
+}
+
+// GetTags returns the tags of the object at path.
+func (s *SSS) GetTags(ctx context.Context, path string, opts ...ObjectOptions) (map[string]string, error) {
+	o := newObjectOption(opts)
+	resp, err := s.backend.GetObjectTaggingWithContext(ctx, &s3.GetObjectTaggingInput{
+		Bucket:    s.getBucket(),
+		Key:       aws.String(s.s3Path(path)),
+		VersionId: o.versionID(),
+	})
+	if err != nil {
+		return nil, parseError(path, err)
+	}
+
+	tags := make(map[string]string, len(resp.TagSet))
+	for _, tag := range resp.TagSet {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return tags, nil
+}
+
+// copyTags returns the tags of versionID of the object at path to carry over to a copy of it,
+// none if the storage doesn't support tagging or the credentials can't read them.
+func (s *SSS) copyTags(ctx context.Context, path, versionID string) (map[string]string, error) {
+	tags, err := s.GetTags(ctx, path, WithVersionID(versionID))
+	if err != nil {
+		var awsErr awserr.Error
+		if errors.Is(err, ErrPermission) || errors.As(err, &awsErr) && awsErr.Code() == "NotImplemented" {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return tags, nil
+}
+
+// SetTags replaces the tags of the object at path.
+func (s *SSS) SetTags(ctx context.Context, path string, tags map[string]string, opts ...ObjectOptions) error {
+	o := newObjectOption(opts)
+	tagSet := make([]*s3.Tag, 0, len(tags))
+	for k, v := range tags {
+		tagSet = append(tagSet, &s3.Tag{
+			Key:   aws.String(k),
+			Value: aws.String(v),
+		})
+	}
+	_, err := s.backend.PutObjectTaggingWithContext(ctx, &s3.PutObjectTaggingInput{
+		Bucket:    s.getBucket(),
+		Key:       aws.String(s.s3Path(path)),
+		VersionId: o.versionID(),
+		Tagging: &s3.Tagging{
+			TagSet: tagSet,
+		},
+	})
+	return parseError(path, err)
+}
+
+// DeleteTags removes all tags of the object at path.
+func (s *SSS) DeleteTags(ctx context.Context, path string, opts ...ObjectOptions) error {
+	o := newObjectOption(opts)
+	_, err := s.backend.DeleteObjectTaggingWithContext(ctx, &s3.DeleteObjectTaggingInput{
+		Bucket:    s.getBucket(),
+		Key:       aws.String(s.s3Path(path)),
+		VersionId: o.versionID(),
+	})
+	return parseError(path, err)
+}
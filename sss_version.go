@@ -0,0 +1,160 @@
+package sss
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+type objectOption struct {
+	VersionID string
+	ETag      string
+}
+
+// ObjectOptions are the options of the requests reading or deleting a single object.
+type ObjectOptions func(*objectOption)
+
+// WithVersionID targets the given version of the object instead of the latest one.
+func WithVersionID(versionID string) ObjectOptions {
+	return func(o *objectOption) {
+		o.VersionID = versionID
+	}
+}
+
+// WithETag pins a read to the given ETag of the object, sending it as If-Match,
+// so that once the object is overwritten the read fails with ErrObjectChanged
+// instead of returning a part of another content.
+func WithETag(etag string) ObjectOptions {
+	return func(o *objectOption) {
+		o.ETag = etag
+	}
+}
+
+func newObjectOption(opts []ObjectOptions) objectOption {
+	var o objectOption
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func (o objectOption) versionID() *string {
+	if o.VersionID == "" {
+		return nil
+	}
+	return aws.String(o.VersionID)
+}
+
+func (o objectOption) ifMatch() *string {
+	if o.ETag == "" {
+		return nil
+	}
+	return aws.String(o.ETag)
+}
+
+// Version is a version of an object, or a delete marker hiding the object.
+type Version struct {
+	FileInfo
+
+	VersionID      string
+	IsLatest       bool
+	IsDeleteMarker bool
+}
+
+// ListVersions calls fn with every version of the object at path and of the objects below it,
+// ordered by path and then from the newest, until fn returns false.
+func (s *SSS) ListVersions(ctx context.Context, path string, fn func(Version) bool) error {
+	s3Path := s.s3Path("")
+	prefix := ""
+	if s3Path == "" {
+		prefix = "/"
+	}
+
+	key := s.s3Path(strings.TrimRight(path, "/"))
+	version := func(v *s3.ObjectVersion) Version {
+		return Version{
+			FileInfo: &fileInfo{
+				path:    strings.Replace(*v.Key, s3Path, prefix, 1),
+				size:    aws.Int64Value(v.Size),
+				modTime: aws.TimeValue(v.LastModified),
+				sys: FileInfoExpansion{
+					ETag:      v.ETag,
+					VersionID: v.VersionId,
+				},
+			},
+			VersionID: aws.StringValue(v.VersionId),
+			IsLatest:  aws.BoolValue(v.IsLatest),
+		}
+	}
+	deleteMarker := func(m *s3.DeleteMarkerEntry) Version {
+		return Version{
+			FileInfo: &fileInfo{
+				path:    strings.Replace(*m.Key, s3Path, prefix, 1),
+				modTime: aws.TimeValue(m.LastModified),
+				sys: FileInfoExpansion{
+					VersionID: m.VersionId,
+				},
+			},
+			VersionID:      aws.StringValue(m.VersionId),
+			IsLatest:       aws.BoolValue(m.IsLatest),
+			IsDeleteMarker: true,
+		}
+	}
+
+	err := s.backend.ListObjectVersionsPagesWithContext(ctx, &s3.ListObjectVersionsInput{
+		Bucket: s.getBucket(),
+		Prefix: aws.String(key),
+	}, func(resp *s3.ListObjectVersionsOutput, lastPage bool) bool {
+		// Versions and delete markers come back in separate lists, each ordered by key
+		// and then from the newest, so merge them.
+		versions, markers := resp.Versions, resp.DeleteMarkers
+		for len(versions) != 0 || len(markers) != 0 {
+			var v Version
+			var name string
+			if len(markers) == 0 || len(versions) != 0 && versionFirst(versions[0], markers[0]) {
+				name = *versions[0].Key
+				v = version(versions[0])
+				versions = versions[1:]
+			} else {
+				name = *markers[0].Key
+				v = deleteMarker(markers[0])
+				markers = markers[1:]
+			}
+			if !underKey(name, key) {
+				continue
+			}
+			if !fn(v) {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return parseError(path, err)
+	}
+	return nil
+}
+
+// versionFirst reports whether v comes before the delete marker m in a listing.
+func versionFirst(v *s3.ObjectVersion, m *s3.DeleteMarkerEntry) bool {
+	if *v.Key != *m.Key {
+		return *v.Key < *m.Key
+	}
+	if aws.BoolValue(v.IsLatest) != aws.BoolValue(m.IsLatest) {
+		return aws.BoolValue(v.IsLatest)
+	}
+	return !aws.TimeValue(m.LastModified).After(aws.TimeValue(v.LastModified))
+}
+
+// underKey reports whether name is key itself or below it, every name being below the root.
+func underKey(name, key string) bool {
+	return key == "" || name == key || strings.HasPrefix(name, key+"/")
+}
+
+// RestoreVersion makes the given version of the object at path its latest version again,
+// by copying it on top of the object as Copy does.
+func (s *SSS) RestoreVersion(ctx context.Context, path, versionID string) error {
+	return s.copyFrom(ctx, s, path, versionID, path, writerOption{})
+}
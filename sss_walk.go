@@ -89,7 +89,8 @@ func (s *SSS) doWalk(ctx context.Context, objectCount *int64, from, startAfter s
 	// ErrSkipDir is handled by explicitly skipping over any files under the skipped directory. This may be sub-optimal
 	// for extreme edge cases but for the general use case in a registry, this is orders of magnitude
 	// faster than a more explicit recursive implementation.
-	listObjectErr := s.s3.ListObjectsV2PagesWithContext(ctx, listObjectsInput, func(objects *s3.ListObjectsV2Output, lastPage bool) bool {
+	listObjectErr := s.backend.ListObjectsV2PagesWithContext(ctx, listObjectsInput, func(objects *s3.ListObjectsV2Output, lastPage bool) bool {
+		s.logger.Debug("walk page", "prefix", aws.StringValue(listObjectsInput.Prefix), "objects", len(objects.Contents), "last", lastPage)
 		walkInfos := make([]fileInfo, 0, len(objects.Contents))
 
 		for _, file := range objects.Contents {
@@ -119,7 +120,7 @@ func (s *SSS) doWalk(ctx context.Context, objectCount *int64, from, startAfter s
 
 		for _, walkInfo := range walkInfos {
 			// skip any results under the last skip directory
-			if prevSkipDir != "" && strings.HasPrefix(walkInfo.Path(), prevSkipDir) {
+			if prevSkipDir != "" && strings.HasPrefix(walkInfo.Path(), prevSkipDir+"/") {
 				continue
 			}
 
@@ -146,7 +147,7 @@ func (s *SSS) doWalk(ctx context.Context, objectCount *int64, from, startAfter s
 	}
 
 	if listObjectErr != nil {
-		return listObjectErr
+		return parseError(from, listObjectErr)
 	}
 
 	return nil
@@ -5,10 +5,12 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -16,21 +18,26 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3"
 )
 
+// SignPut returns a pre-signed URL, with WithSSECustomerKey its holder has to send
+// the same x-amz-server-side-encryption-customer-* headers along.
 func (s *SSS) SignPut(path string, expires time.Duration) (string, error) {
-	return s.presign(expires,
-		func(c *s3.S3) *request.Request {
-			req, _ := c.PutObjectRequest(&s3.PutObjectInput{
-				Bucket: s.getBucket(),
-				Key:    aws.String(s.s3Path(path)),
-			})
-			return req
-		})
+	return s.presign(expires, &s3.PutObjectInput{
+		Bucket:               s.getBucket(),
+		Key:                  aws.String(s.s3Path(path)),
+		SSECustomerAlgorithm: s.getSSECustomerAlgorithm(),
+		SSECustomerKey:       s.getSSECustomerKey(),
+	})
 }
 
 type writerOption struct {
 	SHA256             string
 	ContentType        string
 	ContentDisposition string
+	Metadata           map[string]*string
+	Tagging            *string
+	Concurrency        int
+	IfMatch            string
+	IfNoneMatch        string
 }
 
 type WriterOptions func(*writerOption)
@@ -66,6 +73,55 @@ func WithContentDisposition(contentDisposition string) WriterOptions {
 	}
 }
 
+// WithMetadata sets the user metadata, sent as x-amz-meta-* headers, for the object being written.
+// On Copy it replaces the metadata of the source.
+func WithMetadata(metadata map[string]string) WriterOptions {
+	return func(o *writerOption) {
+		o.Metadata = aws.StringMap(metadata)
+	}
+}
+
+// WithConcurrency keeps up to n parts uploading at the same time,
+// each holding a chunk sized buffer until its upload finishes.
+func WithConcurrency(n int) WriterOptions {
+	return func(o *writerOption) {
+		o.Concurrency = n
+	}
+}
+
+// WithIfNoneMatch only completes the write if the object doesn't have the given ETag,
+// "*" only creates the object if it doesn't exist yet.
+// A Writer aborts its upload when the precondition fails.
+func WithIfNoneMatch(etag string) WriterOptions {
+	return func(o *writerOption) {
+		o.IfNoneMatch = etag
+	}
+}
+
+// WithIfMatch only completes the write if the object still has the given ETag,
+// replacing it the way a compare-and-swap does.
+// A Writer aborts its upload when the precondition fails.
+func WithIfMatch(etag string) WriterOptions {
+	return func(o *writerOption) {
+		o.IfMatch = etag
+	}
+}
+
+// requestOptions sends the conditions of the write along with the request completing it.
+func (o writerOption) requestOptions() []request.Option {
+	h := map[string]string{}
+	if o.IfMatch != "" {
+		h["If-Match"] = o.IfMatch
+	}
+	if o.IfNoneMatch != "" {
+		h["If-None-Match"] = o.IfNoneMatch
+	}
+	if len(h) == 0 {
+		return nil
+	}
+	return []request.Option{request.WithSetRequestHeaders(h)}
+}
+
 func (s *SSS) PutContent(ctx context.Context, path string, contents []byte, opts ...WriterOptions) error {
 	putObjectInput := &s3.PutObjectInput{
 		Bucket:               s.getBucket(),
@@ -75,6 +131,8 @@ func (s *SSS) PutContent(ctx context.Context, path string, contents []byte, opts
 		ServerSideEncryption: s.getEncryptionMode(),
 		SSEKMSKeyId:          s.getSSEKMSKeyID(),
 		StorageClass:         s.getStorageClass(),
+		SSECustomerAlgorithm: s.getSSECustomerAlgorithm(),
+		SSECustomerKey:       s.getSSECustomerKey(),
 		Body:                 bytes.NewReader(contents),
 	}
 
@@ -82,17 +140,28 @@ func (s *SSS) PutContent(ctx context.Context, path string, contents []byte, opts
 	for _, opt := range opts {
 		opt(&o)
 	}
-	if o.SHA256 != "" {
-		putObjectInput.ChecksumSHA256 = aws.String(o.SHA256)
-	}
 	if o.ContentType != "" {
 		putObjectInput.ContentType = aws.String(o.ContentType)
 	}
 	if o.ContentDisposition != "" {
 		putObjectInput.ContentDisposition = aws.String(o.ContentDisposition)
 	}
+	putObjectInput.Metadata = o.Metadata
+	putObjectInput.Tagging = o.Tagging
+
+	if s.cse.encrypting() {
+		c, params, err := s.cse.newObject()
+		if err != nil {
+			return err
+		}
+		putObjectInput.Body = bytes.NewReader(c.encrypt(contents))
+		putObjectInput.Metadata = withMetadata(o.Metadata, params)
+	} else if o.SHA256 != "" {
+		// The checksum is the one of the content, not of what is stored when encrypted.
+		putObjectInput.ChecksumSHA256 = aws.String(o.SHA256)
+	}
 
-	_, err := s.s3.PutObjectWithContext(ctx, putObjectInput)
+	_, err := s.backend.PutObjectWithContext(ctx, putObjectInput, o.requestOptions()...)
 	return parseError(path, err)
 }
 
@@ -102,15 +171,37 @@ func (s *SSS) Writer(ctx context.Context, path string, opts ...WriterOptions) (F
 		opt(&o)
 	}
 
+	if s.cse.encrypting() {
+		c, params, err := s.cse.newObject()
+		if err != nil {
+			return nil, err
+		}
+		o.Metadata = withMetadata(o.Metadata, params)
+		// The checksum is the one of the content, not of what is stored.
+		o.SHA256 = ""
+
+		mp, err := s.newMultipart(ctx, path, o)
+		if err != nil {
+			return nil, err
+		}
+		return newEncryptWriter(s.newWriter(ctx, path, mp.UploadID(), nil, o), c), nil
+	}
+
 	mp, err := s.newMultipart(ctx, path, o)
 	if err != nil {
 		return nil, err
 	}
-	return s.newWriter(ctx, mp.Key(), mp.UploadID(), nil, o), nil
+	return s.newWriter(ctx, path, mp.UploadID(), nil, o), nil
 }
 
+// errAppendEncrypted is returned when resuming an upload with client-side encryption,
+// the data key of the upload being out of reach until it completes.
+var errAppendEncrypted = errors.New("appending to an upload is not supported with client-side encryption")
+
 func (s *SSS) WriterWithAppend(ctx context.Context, path string, opts ...WriterOptions) (FileWriter, error) {
-	key := s.s3Path(path)
+	if s.cse.encrypting() {
+		return nil, errAppendEncrypted
+	}
 
 	var o writerOption
 	for _, opt := range opts {
@@ -123,18 +214,20 @@ func (s *SSS) WriterWithAppend(ctx context.Context, path string, opts ...WriterO
 	}
 
 	if m.UploadID() == "" {
-		return nil, fmt.Errorf("multipart upload not found: %s", path)
+		return nil, &Error{Path: path, Err: ErrUploadNotFound}
 	}
 
 	parts, err := m.OrderParts(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return s.newWriter(ctx, key, m.UploadID(), parts.Items(), o), nil
+	return s.newWriter(ctx, path, m.UploadID(), parts.Items(), o), nil
 }
 
 func (s *SSS) WriterWithAppendByUploadID(ctx context.Context, path, uploadID string, opts ...WriterOptions) (FileWriter, error) {
-	key := s.s3Path(path)
+	if s.cse.encrypting() {
+		return nil, errAppendEncrypted
+	}
 
 	var o writerOption
 	for _, opt := range opts {
@@ -146,7 +239,7 @@ func (s *SSS) WriterWithAppendByUploadID(ctx context.Context, path, uploadID str
 	if err != nil {
 		return nil, err
 	}
-	return s.newWriter(ctx, key, uploadID, parts.Items(), o), nil
+	return s.newWriter(ctx, path, uploadID, parts.Items(), o), nil
 }
 
 type FileWriter interface {
@@ -159,6 +252,7 @@ type FileWriter interface {
 type writer struct {
 	ctx       context.Context
 	driver    *SSS
+	path      string
 	key       string
 	uploadID  string
 	parts     []*s3.Part
@@ -169,9 +263,15 @@ type writer struct {
 	committed bool
 	cancelled bool
 	opt       writerOption
+
+	// sem bounds the parts in flight when uploading concurrently.
+	sem    chan struct{}
+	wg     sync.WaitGroup
+	errMut sync.Mutex
+	err    error
 }
 
-func (s *SSS) newWriter(ctx context.Context, key, uploadID string, parts []*s3.Part, opt writerOption) FileWriter {
+func (s *SSS) newWriter(ctx context.Context, path, uploadID string, parts []*s3.Part, opt writerOption) FileWriter {
 	var chunkSize = s.chunkSize
 	var size int64
 	if len(parts) > 0 {
@@ -191,10 +291,11 @@ func (s *SSS) newWriter(ctx context.Context, key, uploadID string, parts []*s3.P
 		}
 	}
 
-	return &writer{
+	w := &writer{
 		ctx:       ctx,
 		driver:    s,
-		key:       key,
+		path:      path,
+		key:       s.s3Path(path),
 		uploadID:  uploadID,
 		parts:     parts,
 		size:      size,
@@ -202,12 +303,19 @@ func (s *SSS) newWriter(ctx context.Context, key, uploadID string, parts []*s3.P
 		opt:       opt,
 		buf:       s.pool.Get().(*bytes.Buffer),
 	}
+	if opt.Concurrency > 1 {
+		w.sem = make(chan struct{}, opt.Concurrency)
+	}
+	return w
 }
 
 func (w *writer) Write(p []byte) (int, error) {
 	if err := w.done(); err != nil {
 		return 0, err
 	}
+	if err := w.uploadErr(); err != nil {
+		return 0, err
+	}
 
 	n, _ := w.buf.Write(p)
 	for w.buf.Len() >= w.chunkSize {
@@ -228,6 +336,7 @@ func (w *writer) Close() error {
 	}
 
 	w.closed = true
+	w.wg.Wait()
 
 	defer w.releaseBuffer()
 
@@ -247,12 +356,13 @@ func (w *writer) Cancel(ctx context.Context) error {
 	}
 
 	w.cancelled = true
-	_, err := w.driver.s3.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+	w.wg.Wait()
+	_, err := w.driver.backend.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
 		Bucket:   aws.String(w.driver.bucket),
 		Key:      aws.String(w.key),
 		UploadId: aws.String(w.uploadID),
 	})
-	return err
+	return parseError(w.path, err)
 }
 
 // Commit flushes any remaining data in the buffer and completes the multipart upload.
@@ -265,6 +375,11 @@ func (w *writer) Commit(ctx context.Context) error {
 		return err
 	}
 
+	w.wg.Wait()
+	if err := w.uploadErr(); err != nil {
+		return err
+	}
+
 	w.committed = true
 
 	if len(w.parts) == 0 {
@@ -293,32 +408,52 @@ func (w *writer) Commit(ctx context.Context) error {
 		completeMultipartUploadInput.ChecksumSHA256 = aws.String(w.opt.SHA256)
 	}
 
-	_, err := w.driver.s3.CompleteMultipartUploadWithContext(ctx, completeMultipartUploadInput)
+	_, err := w.driver.backend.CompleteMultipartUploadWithContext(ctx, completeMultipartUploadInput, w.opt.requestOptions()...)
 	if err != nil {
+		err = parseError(w.path, err)
+		if errors.Is(err, ErrPreconditionFailed) {
+			// The parts are of no use anymore.
+			_, abortErr := w.driver.backend.AbortMultipartUploadWithContext(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+				Bucket:   aws.String(w.driver.bucket),
+				Key:      aws.String(w.key),
+				UploadId: aws.String(w.uploadID),
+			})
+			if abortErr != nil {
+				return errors.Join(err, abortErr)
+			}
+		}
 		return err
 	}
+	w.driver.logger.Info("complete multipart upload", "key", w.key, "upload_id", w.uploadID, "parts", len(w.parts), "size", w.size)
 	return nil
 }
 
 func (w *writer) flush() error {
-	if w.buf.Len() == 0 {
+	// An empty object still needs one part to complete the upload.
+	if w.buf.Len() == 0 && len(w.parts) != 0 {
 		return nil
 	}
 
+	if w.sem != nil {
+		return w.flushAsync()
+	}
+
 	r := bytes.NewReader(w.buf.Next(w.chunkSize))
 
 	partSize := r.Len()
 	partNumber := aws.Int64(int64(len(w.parts)) + 1)
 
-	resp, err := w.driver.s3.UploadPartWithContext(w.ctx, &s3.UploadPartInput{
-		Bucket:     aws.String(w.driver.bucket),
-		Key:        aws.String(w.key),
-		PartNumber: partNumber,
-		UploadId:   aws.String(w.uploadID),
-		Body:       r,
+	resp, err := w.driver.backend.UploadPartWithContext(w.ctx, &s3.UploadPartInput{
+		Bucket:               aws.String(w.driver.bucket),
+		Key:                  aws.String(w.key),
+		PartNumber:           partNumber,
+		UploadId:             aws.String(w.uploadID),
+		SSECustomerAlgorithm: w.driver.getSSECustomerAlgorithm(),
+		SSECustomerKey:       w.driver.getSSECustomerKey(),
+		Body:                 r,
 	})
 	if err != nil {
-		return fmt.Errorf("upload part: %w", err)
+		return fmt.Errorf("upload part: %w", parseError(w.path, err))
 	}
 
 	w.parts = append(w.parts, &s3.Part{
@@ -326,12 +461,76 @@ func (w *writer) flush() error {
 		PartNumber: partNumber,
 		Size:       aws.Int64(int64(partSize)),
 	})
+	w.driver.logger.Debug("upload part", "key", w.key, "upload_id", w.uploadID, "part", *partNumber, "size", partSize)
 
 	w.size += int64(partSize)
 
 	return nil
 }
 
+// flushAsync hands the next chunk to a goroutine, blocking while the maximum
+// number of parts are in flight. The part's ETag is filled in once uploaded.
+func (w *writer) flushAsync() error {
+	select {
+	case w.sem <- struct{}{}:
+	case <-w.ctx.Done():
+		return w.ctx.Err()
+	}
+	if err := w.uploadErr(); err != nil {
+		<-w.sem
+		return err
+	}
+
+	buf := w.driver.pool.Get().(*bytes.Buffer)
+	buf.Reset()
+	buf.Write(w.buf.Next(w.chunkSize))
+
+	part := &s3.Part{
+		PartNumber: aws.Int64(int64(len(w.parts)) + 1),
+		Size:       aws.Int64(int64(buf.Len())),
+	}
+	w.parts = append(w.parts, part)
+	w.size += *part.Size
+
+	w.wg.Add(1)
+	go func() {
+		defer func() {
+			buf.Reset()
+			w.driver.pool.Put(buf)
+			<-w.sem
+			w.wg.Done()
+		}()
+
+		resp, err := w.driver.backend.UploadPartWithContext(w.ctx, &s3.UploadPartInput{
+			Bucket:               aws.String(w.driver.bucket),
+			Key:                  aws.String(w.key),
+			PartNumber:           part.PartNumber,
+			UploadId:             aws.String(w.uploadID),
+			SSECustomerAlgorithm: w.driver.getSSECustomerAlgorithm(),
+			SSECustomerKey:       w.driver.getSSECustomerKey(),
+			Body:                 bytes.NewReader(buf.Bytes()),
+		})
+		if err != nil {
+			w.errMut.Lock()
+			if w.err == nil {
+				w.err = fmt.Errorf("upload part %d: %w", *part.PartNumber, parseError(w.path, err))
+			}
+			w.errMut.Unlock()
+			return
+		}
+		part.ETag = resp.ETag
+		w.driver.logger.Debug("upload part", "key", w.key, "upload_id", w.uploadID, "part", *part.PartNumber, "size", *part.Size)
+	}()
+	return nil
+}
+
+// uploadErr returns the first error of the concurrent part uploads.
+func (w *writer) uploadErr() error {
+	w.errMut.Lock()
+	defer w.errMut.Unlock()
+	return w.err
+}
+
 func (w *writer) done() error {
 	switch {
 	case w.closed:
@@ -0,0 +1,470 @@
+package ssstest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/wzshiming/sss"
+)
+
+// RunConformance runs the behaviours SSS relies on against a storage provider.
+//
+// newSSS returns an SSS for the storage under test with opts applied on top of its
+// configuration, every SSS it returns must share the same storage.
+// The suite works below a random path and deletes it when finished, so it can be
+// pointed at a bucket in use.
+func RunConformance(t *testing.T, newSSS func(opts ...sss.Option) *sss.SSS) {
+	s := newSSS()
+
+	var id [8]byte
+	rand.Read(id[:])
+	root := "/sss-conformance-" + hex.EncodeToString(id[:])
+	t.Cleanup(func() {
+		err := s.DeleteAll(context.Background(), root)
+		if err != nil {
+			t.Errorf("failed to clean up %s: %v", root, err)
+		}
+	})
+
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s *sss.SSS, dir string)
+	}{
+		{"ZeroByte", testZeroByte},
+		{"SpecialKeys", testSpecialKeys},
+		{"WalkSkipDir", testWalkSkipDir},
+		{"WalkStartAfterHint", testWalkStartAfterHint},
+		{"AppendResume", testAppendResume},
+		{"MultipartDuplicatePart", testMultipartDuplicatePart},
+		{"RootDirectory", func(t *testing.T, s *sss.SSS, dir string) {
+			testRootDirectory(t, s, newSSS(sss.WithRootDirectory(dir+"/root")), dir)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, s, root+"/"+tt.name)
+		})
+	}
+}
+
+func testZeroByte(t *testing.T, s *sss.SSS, dir string) {
+	ctx := t.Context()
+
+	key := dir + "/put"
+	err := s.PutContent(ctx, key, nil)
+	if err != nil {
+		t.Fatalf("failed to put empty object: %v", err)
+	}
+	checkContent(t, s, key, nil)
+
+	key = dir + "/writer"
+	w, err := s.Writer(ctx, key)
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	err = w.Commit(ctx)
+	if err != nil {
+		t.Fatalf("failed to commit empty writer: %v", err)
+	}
+	w.Close()
+	checkContent(t, s, key, nil)
+
+	for _, key := range []string{dir + "/put", dir + "/writer"} {
+		info, err := s.Stat(ctx, key)
+		if err != nil {
+			t.Fatalf("failed to stat %s: %v", key, err)
+		}
+		if info.IsDir() || info.Size() != 0 {
+			t.Errorf("expected %s to be an empty file, got dir %v size %d", key, info.IsDir(), info.Size())
+		}
+	}
+
+	got := list(t, s, dir)
+	want := []string{dir + "/put", dir + "/writer"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected list %q, got %q", want, got)
+	}
+}
+
+func testSpecialKeys(t *testing.T, s *sss.SSS, dir string) {
+	ctx := t.Context()
+
+	names := []string{
+		"with space.txt",
+		"unicode-文件-ü.txt",
+		"plus+sign.txt",
+		"a&b=c;d.txt",
+	}
+	var want []string
+	for _, name := range names {
+		key := dir + "/" + name
+		content := []byte("content of " + name)
+		err := s.PutContent(ctx, key, content)
+		if err != nil {
+			t.Fatalf("failed to put %q: %v", key, err)
+		}
+		checkContent(t, s, key, content)
+
+		info, err := s.Stat(ctx, key)
+		if err != nil {
+			t.Fatalf("failed to stat %q: %v", key, err)
+		}
+		if info.Name() != name {
+			t.Errorf("expected name %q, got %q", name, info.Name())
+		}
+
+		err = s.Copy(ctx, key, key+".copy")
+		if err != nil {
+			t.Fatalf("failed to copy %q: %v", key, err)
+		}
+		checkContent(t, s, key+".copy", content)
+
+		want = append(want, key, key+".copy")
+	}
+	slices.Sort(want)
+
+	got := list(t, s, dir)
+	if !slices.Equal(got, want) {
+		t.Errorf("expected list %q, got %q", want, got)
+	}
+
+	got = walkFiles(t, s, dir)
+	if !slices.Equal(got, want) {
+		t.Errorf("expected walk %q, got %q", want, got)
+	}
+
+	err := s.DeleteBatch(ctx, want)
+	if err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	if got := list(t, s, dir); len(got) != 0 {
+		t.Errorf("expected nothing left, got %q", got)
+	}
+}
+
+func testWalkSkipDir(t *testing.T, s *sss.SSS, dir string) {
+	ctx := t.Context()
+
+	keys := []string{
+		"a/b/c/d/e/file",
+		"a/b/c/file",
+		"a/b/x/file",
+		"a/bc/file",
+		"a/z/y/file",
+		"top",
+	}
+	for _, key := range keys {
+		err := s.PutContent(ctx, dir+"/"+key, []byte(key))
+		if err != nil {
+			t.Fatalf("failed to put %q: %v", key, err)
+		}
+	}
+
+	var got []string
+	err := s.Walk(ctx, dir, func(info sss.FileInfo) error {
+		got = append(got, strings.TrimPrefix(info.Path(), dir+"/"))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to walk: %v", err)
+	}
+	want := []string{
+		"a",
+		"a/b",
+		"a/b/c",
+		"a/b/c/d",
+		"a/b/c/d/e",
+		"a/b/c/d/e/file",
+		"a/b/c/file",
+		"a/b/x",
+		"a/b/x/file",
+		"a/bc",
+		"a/bc/file",
+		"a/z",
+		"a/z/y",
+		"a/z/y/file",
+		"top",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected walk %q, got %q", want, got)
+	}
+
+	got = nil
+	err = s.Walk(ctx, dir, func(info sss.FileInfo) error {
+		p := strings.TrimPrefix(info.Path(), dir+"/")
+		got = append(got, p)
+		if info.IsDir() && (p == "a/b" || p == "a/z/y") {
+			return sss.ErrSkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to walk: %v", err)
+	}
+	want = []string{
+		"a",
+		"a/b",
+		"a/bc",
+		"a/bc/file",
+		"a/z",
+		"a/z/y",
+		"top",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected walk with skipped dirs %q, got %q", want, got)
+	}
+}
+
+func testWalkStartAfterHint(t *testing.T, s *sss.SSS, dir string) {
+	ctx := t.Context()
+
+	keys := []string{
+		"f/01", "f/02", "f/03", "f/04", "f/05",
+		"f/06", "f/07", "f/08", "f/09", "f/10",
+		"g/01",
+	}
+	for _, key := range keys {
+		err := s.PutContent(ctx, dir+"/"+key, []byte(key))
+		if err != nil {
+			t.Fatalf("failed to put %q: %v", key, err)
+		}
+	}
+
+	var got []string
+	err := s.Walk(ctx, dir, func(info sss.FileInfo) error {
+		if !info.IsDir() {
+			got = append(got, strings.TrimPrefix(info.Path(), dir+"/"))
+		}
+		return nil
+	}, sss.WithStartAfterHint(dir+"/f/05"))
+	if err != nil {
+		t.Fatalf("failed to walk: %v", err)
+	}
+
+	want := keys[5:]
+	if !slices.Equal(got, want) {
+		t.Errorf("expected walk after hint %q, got %q", want, got)
+	}
+}
+
+func testAppendResume(t *testing.T, s *sss.SSS, dir string) {
+	ctx := t.Context()
+
+	key := dir + "/file"
+	chunkSize := s.ChunkSize()
+	content := make([]byte, 2*chunkSize+chunkSize/2)
+	rand.Read(content)
+
+	w, err := s.Writer(ctx, key)
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	// The tail after the last full chunk is still buffered and lost on close.
+	_, err = w.Write(content[:2*chunkSize+10])
+	if err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("failed to close writer: %v", err)
+	}
+
+	w, err = s.WriterWithAppend(ctx, key)
+	if err != nil {
+		t.Fatalf("failed to resume writer: %v", err)
+	}
+	if w.Size() != int64(2*chunkSize) {
+		t.Fatalf("expected resumed size %d, got %d", 2*chunkSize, w.Size())
+	}
+	_, err = w.Write(content[w.Size():])
+	if err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	err = w.Commit(ctx)
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	w.Close()
+
+	checkContent(t, s, key, content)
+}
+
+func testMultipartDuplicatePart(t *testing.T, s *sss.SSS, dir string) {
+	ctx := t.Context()
+
+	key := dir + "/file"
+	mp, err := s.NewMultipart(ctx, key)
+	if err != nil {
+		t.Fatalf("failed to create multipart: %v", err)
+	}
+
+	for _, content := range []string{"first upload", "second upload"} {
+		err = mp.UploadPart(ctx, 1, strings.NewReader(content))
+		if err != nil {
+			t.Fatalf("failed to upload part: %v", err)
+		}
+	}
+
+	mp, err = s.GetMultipartByUploadID(ctx, key, mp.UploadID())
+	if err != nil {
+		t.Fatalf("failed to get multipart: %v", err)
+	}
+	err = mp.Resume(ctx)
+	if err != nil {
+		t.Fatalf("failed to resume: %v", err)
+	}
+	parts, err := mp.AllParts(ctx)
+	if err != nil {
+		t.Fatalf("failed to get parts: %v", err)
+	}
+	if parts.Count() != 1 || parts.Size() != int64(len("second upload")) {
+		t.Fatalf("expected 1 part of %d bytes, got %d parts of %d bytes", len("second upload"), parts.Count(), parts.Size())
+	}
+
+	err = mp.Commit(ctx)
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	checkContent(t, s, key, []byte("second upload"))
+}
+
+func testRootDirectory(t *testing.T, s, rooted *sss.SSS, dir string) {
+	ctx := t.Context()
+
+	content := []byte("rooted")
+	err := rooted.PutContent(ctx, "/a/b.txt", content)
+	if err != nil {
+		t.Fatalf("failed to put: %v", err)
+	}
+	checkContent(t, s, dir+"/root/a/b.txt", content)
+	checkContent(t, rooted, "/a/b.txt", content)
+
+	info, err := rooted.Stat(ctx, "/a/b.txt")
+	if err != nil {
+		t.Fatalf("failed to stat: %v", err)
+	}
+	if info.Path() != "/a/b.txt" {
+		t.Errorf("expected stat path %q, got %q", "/a/b.txt", info.Path())
+	}
+
+	if got, want := list(t, rooted, "/"), []string{"/a"}; !slices.Equal(got, want) {
+		t.Errorf("expected list %q, got %q", want, got)
+	}
+	if got, want := list(t, rooted, "/a"), []string{"/a/b.txt"}; !slices.Equal(got, want) {
+		t.Errorf("expected list %q, got %q", want, got)
+	}
+
+	var got []string
+	err = rooted.Walk(ctx, "/", func(info sss.FileInfo) error {
+		got = append(got, info.Path())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to walk: %v", err)
+	}
+	if want := []string{"/a", "/a/b.txt"}; !slices.Equal(got, want) {
+		t.Errorf("expected walk %q, got %q", want, got)
+	}
+
+	chunkSize := rooted.ChunkSize()
+	content = make([]byte, chunkSize+10)
+	rand.Read(content)
+	w, err := rooted.Writer(ctx, "/c")
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	_, err = w.Write(content[:chunkSize])
+	if err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	w.Close()
+
+	w, err = rooted.WriterWithAppend(ctx, "/c")
+	if err != nil {
+		t.Fatalf("failed to resume writer: %v", err)
+	}
+	if w.Size() != int64(chunkSize) {
+		t.Fatalf("expected resumed size %d, got %d", chunkSize, w.Size())
+	}
+	_, err = w.Write(content[chunkSize:])
+	if err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	err = w.Commit(ctx)
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	w.Close()
+	checkContent(t, s, dir+"/root/c", content)
+
+	mp, err := rooted.NewMultipart(ctx, "/d")
+	if err != nil {
+		t.Fatalf("failed to create multipart: %v", err)
+	}
+	err = mp.UploadPart(ctx, 1, bytes.NewReader(content[:10]))
+	if err != nil {
+		t.Fatalf("failed to upload part: %v", err)
+	}
+	aborted, err := rooted.AbortStaleMultipart(ctx, "/", 0, false)
+	if err != nil {
+		t.Fatalf("failed to abort stale multipart: %v", err)
+	}
+	if len(aborted) != 1 || aborted[0].UploadID() != mp.UploadID() || aborted[0].Size != 10 {
+		t.Fatalf("expected the upload %s of 10 bytes aborted, got %+v", mp.UploadID(), aborted)
+	}
+	_, err = rooted.GetMultipart(ctx, "/d")
+	if !errors.Is(err, sss.ErrUploadNotFound) {
+		t.Fatalf("expected the aborted upload to be gone, got %v", err)
+	}
+}
+
+func checkContent(t *testing.T, s *sss.SSS, key string, want []byte) {
+	t.Helper()
+
+	got, err := s.GetContent(t.Context(), key)
+	if err != nil {
+		t.Fatalf("failed to get %q: %v", key, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("unexpected content of %q, got %d bytes, want %d bytes", key, len(got), len(want))
+	}
+}
+
+// list returns the sorted paths directly under dir.
+func list(t *testing.T, s *sss.SSS, dir string) []string {
+	t.Helper()
+
+	var paths []string
+	err := s.List(t.Context(), dir, func(info sss.FileInfo) bool {
+		paths = append(paths, info.Path())
+		return true
+	})
+	if err != nil {
+		t.Fatalf("failed to list %q: %v", dir, err)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// walkFiles returns the paths of the files below dir in walk order.
+func walkFiles(t *testing.T, s *sss.SSS, dir string) []string {
+	t.Helper()
+
+	var paths []string
+	err := s.Walk(t.Context(), dir, func(info sss.FileInfo) error {
+		if !info.IsDir() {
+			paths = append(paths, info.Path())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to walk %q: %v", dir, err)
+	}
+	return paths
+}
@@ -0,0 +1,826 @@
+package ssstest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/wzshiming/sss"
+)
+
+// handler translates the S3 REST protocol into calls on a sss.Backend.
+// Only path style addressing is supported and signatures are not verified,
+// but pre-signed URLs are rejected once they expire.
+type handler struct {
+	backend sss.Backend
+}
+
+// NewHandler returns an http.Handler speaking the subset of the S3 REST protocol used by SSS,
+// backed by backend.
+func NewHandler(backend sss.Backend) http.Handler {
+	return &handler{
+		backend: backend,
+	}
+}
+
+func (h *handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	err := checkExpires(r.URL.Query())
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket == "" {
+		writeError(rw, r, errNotImplemented)
+		return
+	}
+	if key == "" {
+		h.serveBucket(rw, r, bucket)
+		return
+	}
+	h.serveObject(rw, r, bucket, key)
+}
+
+func (h *handler) serveBucket(rw http.ResponseWriter, r *http.Request, bucket string) {
+	query := r.URL.Query()
+	switch r.Method {
+	case http.MethodHead, http.MethodPut:
+		// Buckets exist as soon as they are used.
+		rw.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		switch {
+		case query.Has("uploads"):
+			h.listMultipartUploads(rw, r, bucket)
+		case query.Has("versions"):
+			h.listObjectVersions(rw, r, bucket)
+		case query.Get("list-type") == "2":
+			h.listObjectsV2(rw, r, bucket)
+		default:
+			h.listObjects(rw, r, bucket)
+		}
+	case http.MethodPost:
+		if !query.Has("delete") {
+			writeError(rw, r, errNotImplemented)
+			return
+		}
+		h.deleteObjects(rw, r, bucket)
+	default:
+		writeError(rw, r, errNotImplemented)
+	}
+}
+
+func (h *handler) serveObject(rw http.ResponseWriter, r *http.Request, bucket, key string) {
+	query := r.URL.Query()
+	switch r.Method {
+	case http.MethodGet:
+		switch {
+		case query.Has("uploadId"):
+			h.listParts(rw, r, bucket, key)
+		case query.Has("tagging"):
+			h.getObjectTagging(rw, r, bucket, key)
+		default:
+			h.getObject(rw, r, bucket, key)
+		}
+	case http.MethodHead:
+		h.headObject(rw, r, bucket, key)
+	case http.MethodPut:
+		switch {
+		case query.Has("uploadId") && r.Header.Get("X-Amz-Copy-Source") != "":
+			h.uploadPartCopy(rw, r, bucket, key)
+		case query.Has("uploadId"):
+			h.uploadPart(rw, r, bucket, key)
+		case r.Header.Get("X-Amz-Copy-Source") != "":
+			h.copyObject(rw, r, bucket, key)
+		case query.Has("tagging"):
+			h.putObjectTagging(rw, r, bucket, key)
+		default:
+			h.putObject(rw, r, bucket, key)
+		}
+	case http.MethodPost:
+		switch {
+		case query.Has("uploads"):
+			h.createMultipartUpload(rw, r, bucket, key)
+		case query.Has("uploadId"):
+			h.completeMultipartUpload(rw, r, bucket, key)
+		default:
+			writeError(rw, r, errNotImplemented)
+		}
+	case http.MethodDelete:
+		switch {
+		case query.Has("uploadId"):
+			h.abortMultipartUpload(rw, r, bucket, key)
+		case query.Has("tagging"):
+			h.deleteObjectTagging(rw, r, bucket, key)
+		default:
+			h.deleteObject(rw, r, bucket, key)
+		}
+	default:
+		writeError(rw, r, errNotImplemented)
+	}
+}
+
+func (h *handler) putObject(rw http.ResponseWriter, r *http.Request, bucket, key string) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+	algorithm, sseKey, sseKeyMD5 := sseCustomerKey(r, sseCustomerPrefix)
+	out, err := h.backend.PutObjectWithContext(r.Context(), &s3.PutObjectInput{
+		Bucket:               aws.String(bucket),
+		Key:                  aws.String(key),
+		Body:                 bytes.NewReader(body),
+		ContentType:          header(r, "Content-Type"),
+		ContentDisposition:   header(r, "Content-Disposition"),
+		Metadata:             metadata(r),
+		Tagging:              header(r, "X-Amz-Tagging"),
+		SSECustomerAlgorithm: algorithm,
+		SSECustomerKey:       sseKey,
+		SSECustomerKeyMD5:    sseKeyMD5,
+	}, conditions(r)...)
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+	setSSECustomerKey(rw, out.SSECustomerAlgorithm, out.SSECustomerKeyMD5)
+	setHeader(rw, "ETag", out.ETag)
+	setHeader(rw, "X-Amz-Version-Id", out.VersionId)
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (h *handler) getObject(rw http.ResponseWriter, r *http.Request, bucket, key string) {
+	algorithm, sseKey, sseKeyMD5 := sseCustomerKey(r, sseCustomerPrefix)
+	out, err := h.backend.GetObjectWithContext(r.Context(), &s3.GetObjectInput{
+		Bucket:               aws.String(bucket),
+		Key:                  aws.String(key),
+		Range:                header(r, "Range"),
+		IfMatch:              header(r, "If-Match"),
+		VersionId:            queryValue(r.URL.Query(), "versionId"),
+		SSECustomerAlgorithm: algorithm,
+		SSECustomerKey:       sseKey,
+		SSECustomerKeyMD5:    sseKeyMD5,
+	})
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+	defer out.Body.Close()
+
+	setHeader(rw, "Content-Length", aws.String(strconv.FormatInt(aws.Int64Value(out.ContentLength), 10)))
+	setHeader(rw, "Content-Type", out.ContentType)
+	setHeader(rw, "Content-Disposition", out.ContentDisposition)
+	setMetadata(rw, out.Metadata)
+	setSSECustomerKey(rw, out.SSECustomerAlgorithm, out.SSECustomerKeyMD5)
+	setHeader(rw, "Content-Range", out.ContentRange)
+	setHeader(rw, "Accept-Ranges", out.AcceptRanges)
+	setHeader(rw, "ETag", out.ETag)
+	setHeader(rw, "X-Amz-Version-Id", out.VersionId)
+	setTimeHeader(rw, "Last-Modified", out.LastModified)
+	if out.ContentRange != nil {
+		rw.WriteHeader(http.StatusPartialContent)
+	} else {
+		rw.WriteHeader(http.StatusOK)
+	}
+	io.Copy(rw, out.Body)
+}
+
+func (h *handler) headObject(rw http.ResponseWriter, r *http.Request, bucket, key string) {
+	algorithm, sseKey, sseKeyMD5 := sseCustomerKey(r, sseCustomerPrefix)
+	out, err := h.backend.HeadObjectWithContext(r.Context(), &s3.HeadObjectInput{
+		Bucket:               aws.String(bucket),
+		Key:                  aws.String(key),
+		VersionId:            queryValue(r.URL.Query(), "versionId"),
+		SSECustomerAlgorithm: algorithm,
+		SSECustomerKey:       sseKey,
+		SSECustomerKeyMD5:    sseKeyMD5,
+	})
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+
+	setHeader(rw, "Content-Length", aws.String(strconv.FormatInt(aws.Int64Value(out.ContentLength), 10)))
+	setHeader(rw, "Content-Type", out.ContentType)
+	setHeader(rw, "Content-Disposition", out.ContentDisposition)
+	setMetadata(rw, out.Metadata)
+	setSSECustomerKey(rw, out.SSECustomerAlgorithm, out.SSECustomerKeyMD5)
+	setHeader(rw, "Accept-Ranges", out.AcceptRanges)
+	setHeader(rw, "ETag", out.ETag)
+	setHeader(rw, "X-Amz-Version-Id", out.VersionId)
+	setTimeHeader(rw, "Last-Modified", out.LastModified)
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (h *handler) deleteObject(rw http.ResponseWriter, r *http.Request, bucket, key string) {
+	out, err := h.backend.DeleteObjectWithContext(r.Context(), &s3.DeleteObjectInput{
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(key),
+		VersionId: queryValue(r.URL.Query(), "versionId"),
+	})
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+	setHeader(rw, "X-Amz-Version-Id", out.VersionId)
+	if aws.BoolValue(out.DeleteMarker) {
+		rw.Header().Set("X-Amz-Delete-Marker", "true")
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) copyObject(rw http.ResponseWriter, r *http.Request, bucket, key string) {
+	algorithm, sseKey, sseKeyMD5 := sseCustomerKey(r, sseCustomerPrefix)
+	srcAlgorithm, srcKey, srcKeyMD5 := sseCustomerKey(r, copySourceSSECustomerPrefix)
+	out, err := h.backend.CopyObjectWithContext(r.Context(), &s3.CopyObjectInput{
+		Bucket:               aws.String(bucket),
+		Key:                  aws.String(key),
+		CopySource:           header(r, "X-Amz-Copy-Source"),
+		MetadataDirective:    header(r, "X-Amz-Metadata-Directive"),
+		TaggingDirective:     header(r, "X-Amz-Tagging-Directive"),
+		ContentType:          header(r, "Content-Type"),
+		ContentDisposition:   header(r, "Content-Disposition"),
+		Metadata:             metadata(r),
+		Tagging:              header(r, "X-Amz-Tagging"),
+		SSECustomerAlgorithm: algorithm,
+		SSECustomerKey:       sseKey,
+		SSECustomerKeyMD5:    sseKeyMD5,
+
+		CopySourceSSECustomerAlgorithm: srcAlgorithm,
+		CopySourceSSECustomerKey:       srcKey,
+		CopySourceSSECustomerKeyMD5:    srcKeyMD5,
+	})
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+	setHeader(rw, "X-Amz-Version-Id", out.VersionId)
+	setHeader(rw, "X-Amz-Copy-Source-Version-Id", out.CopySourceVersionId)
+	writeXML(rw, http.StatusOK, copyObjectResult{
+		ETag:         aws.StringValue(out.CopyObjectResult.ETag),
+		LastModified: aws.TimeValue(out.CopyObjectResult.LastModified),
+	})
+}
+
+func (h *handler) getObjectTagging(rw http.ResponseWriter, r *http.Request, bucket, key string) {
+	out, err := h.backend.GetObjectTaggingWithContext(r.Context(), &s3.GetObjectTaggingInput{
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(key),
+		VersionId: queryValue(r.URL.Query(), "versionId"),
+	})
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+	var result tagging
+	for _, t := range out.TagSet {
+		result.TagSet = append(result.TagSet, tag{
+			Key:   aws.StringValue(t.Key),
+			Value: aws.StringValue(t.Value),
+		})
+	}
+	setHeader(rw, "X-Amz-Version-Id", out.VersionId)
+	writeXML(rw, http.StatusOK, result)
+}
+
+func (h *handler) putObjectTagging(rw http.ResponseWriter, r *http.Request, bucket, key string) {
+	var req tagging
+	err := xml.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeError(rw, r, errMalformedXML)
+		return
+	}
+
+	input := &s3.PutObjectTaggingInput{
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(key),
+		VersionId: queryValue(r.URL.Query(), "versionId"),
+		Tagging:   &s3.Tagging{TagSet: []*s3.Tag{}},
+	}
+	for _, t := range req.TagSet {
+		input.Tagging.TagSet = append(input.Tagging.TagSet, &s3.Tag{
+			Key:   aws.String(t.Key),
+			Value: aws.String(t.Value),
+		})
+	}
+	out, err := h.backend.PutObjectTaggingWithContext(r.Context(), input)
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+	setHeader(rw, "X-Amz-Version-Id", out.VersionId)
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (h *handler) deleteObjectTagging(rw http.ResponseWriter, r *http.Request, bucket, key string) {
+	out, err := h.backend.DeleteObjectTaggingWithContext(r.Context(), &s3.DeleteObjectTaggingInput{
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(key),
+		VersionId: queryValue(r.URL.Query(), "versionId"),
+	})
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+	setHeader(rw, "X-Amz-Version-Id", out.VersionId)
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) listObjectsV2(rw http.ResponseWriter, r *http.Request, bucket string) {
+	query := r.URL.Query()
+	input := &s3.ListObjectsV2Input{
+		Bucket:            aws.String(bucket),
+		Prefix:            queryValue(query, "prefix"),
+		Delimiter:         queryValue(query, "delimiter"),
+		StartAfter:        queryValue(query, "start-after"),
+		ContinuationToken: queryValue(query, "continuation-token"),
+	}
+	if maxKeys := query.Get("max-keys"); maxKeys != "" {
+		n, err := strconv.ParseInt(maxKeys, 10, 64)
+		if err != nil {
+			writeError(rw, r, errInvalidArgument("invalid max-keys"))
+			return
+		}
+		input.MaxKeys = aws.Int64(n)
+	}
+
+	out, err := h.backend.ListObjectsV2WithContext(r.Context(), input)
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+
+	result := newListBucketResult(bucket, out)
+	result.KeyCount = aws.Int64Value(out.KeyCount)
+	result.StartAfter = aws.StringValue(out.StartAfter)
+	result.ContinuationToken = aws.StringValue(out.ContinuationToken)
+	result.NextContinuationToken = aws.StringValue(out.NextContinuationToken)
+	writeXML(rw, http.StatusOK, result)
+}
+
+// listObjects serves the version 1 listing, which is what SignList URLs use.
+func (h *handler) listObjects(rw http.ResponseWriter, r *http.Request, bucket string) {
+	query := r.URL.Query()
+	input := &s3.ListObjectsV2Input{
+		Bucket:     aws.String(bucket),
+		Prefix:     queryValue(query, "prefix"),
+		Delimiter:  queryValue(query, "delimiter"),
+		StartAfter: queryValue(query, "marker"),
+	}
+	if maxKeys := query.Get("max-keys"); maxKeys != "" {
+		n, err := strconv.ParseInt(maxKeys, 10, 64)
+		if err != nil {
+			writeError(rw, r, errInvalidArgument("invalid max-keys"))
+			return
+		}
+		input.MaxKeys = aws.Int64(n)
+	}
+
+	out, err := h.backend.ListObjectsV2WithContext(r.Context(), input)
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+
+	result := newListBucketResult(bucket, out)
+	result.Marker = query.Get("marker")
+	result.NextMarker = aws.StringValue(out.NextContinuationToken)
+	writeXML(rw, http.StatusOK, result)
+}
+
+func (h *handler) deleteObjects(rw http.ResponseWriter, r *http.Request, bucket string) {
+	var req deleteRequest
+	err := xml.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeError(rw, r, errMalformedXML)
+		return
+	}
+
+	input := &s3.DeleteObjectsInput{
+		Bucket: aws.String(bucket),
+		Delete: &s3.Delete{
+			Quiet: aws.Bool(req.Quiet),
+		},
+	}
+	for _, obj := range req.Objects {
+		input.Delete.Objects = append(input.Delete.Objects, &s3.ObjectIdentifier{
+			Key:       aws.String(obj.Key),
+			VersionId: obj.VersionID,
+		})
+	}
+
+	out, err := h.backend.DeleteObjectsWithContext(r.Context(), input)
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+
+	var result deleteResult
+	for _, deleted := range out.Deleted {
+		result.Deleted = append(result.Deleted, deletedObject{
+			Key:                   aws.StringValue(deleted.Key),
+			VersionID:             aws.StringValue(deleted.VersionId),
+			DeleteMarker:          aws.BoolValue(deleted.DeleteMarker),
+			DeleteMarkerVersionID: aws.StringValue(deleted.DeleteMarkerVersionId),
+		})
+	}
+	for _, e := range out.Errors {
+		result.Errors = append(result.Errors, deleteError{
+			Key:     aws.StringValue(e.Key),
+			Code:    aws.StringValue(e.Code),
+			Message: aws.StringValue(e.Message),
+		})
+	}
+	writeXML(rw, http.StatusOK, result)
+}
+
+func (h *handler) createMultipartUpload(rw http.ResponseWriter, r *http.Request, bucket, key string) {
+	algorithm, sseKey, sseKeyMD5 := sseCustomerKey(r, sseCustomerPrefix)
+	out, err := h.backend.CreateMultipartUploadWithContext(r.Context(), &s3.CreateMultipartUploadInput{
+		Bucket:               aws.String(bucket),
+		Key:                  aws.String(key),
+		ContentType:          header(r, "Content-Type"),
+		ContentDisposition:   header(r, "Content-Disposition"),
+		Metadata:             metadata(r),
+		Tagging:              header(r, "X-Amz-Tagging"),
+		SSECustomerAlgorithm: algorithm,
+		SSECustomerKey:       sseKey,
+		SSECustomerKeyMD5:    sseKeyMD5,
+	})
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+	writeXML(rw, http.StatusOK, initiateMultipartUploadResult{
+		Bucket:   bucket,
+		Key:      key,
+		UploadID: aws.StringValue(out.UploadId),
+	})
+}
+
+func (h *handler) uploadPart(rw http.ResponseWriter, r *http.Request, bucket, key string) {
+	query := r.URL.Query()
+	partNumber, err := strconv.ParseInt(query.Get("partNumber"), 10, 64)
+	if err != nil {
+		writeError(rw, r, errInvalidArgument("invalid partNumber"))
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+	algorithm, sseKey, sseKeyMD5 := sseCustomerKey(r, sseCustomerPrefix)
+	out, err := h.backend.UploadPartWithContext(r.Context(), &s3.UploadPartInput{
+		Bucket:               aws.String(bucket),
+		Key:                  aws.String(key),
+		UploadId:             aws.String(query.Get("uploadId")),
+		PartNumber:           aws.Int64(partNumber),
+		Body:                 bytes.NewReader(body),
+		SSECustomerAlgorithm: algorithm,
+		SSECustomerKey:       sseKey,
+		SSECustomerKeyMD5:    sseKeyMD5,
+	})
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+	setHeader(rw, "ETag", out.ETag)
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (h *handler) uploadPartCopy(rw http.ResponseWriter, r *http.Request, bucket, key string) {
+	query := r.URL.Query()
+	partNumber, err := strconv.ParseInt(query.Get("partNumber"), 10, 64)
+	if err != nil {
+		writeError(rw, r, errInvalidArgument("invalid partNumber"))
+		return
+	}
+	algorithm, sseKey, sseKeyMD5 := sseCustomerKey(r, sseCustomerPrefix)
+	srcAlgorithm, srcKey, srcKeyMD5 := sseCustomerKey(r, copySourceSSECustomerPrefix)
+	out, err := h.backend.UploadPartCopyWithContext(r.Context(), &s3.UploadPartCopyInput{
+		Bucket:               aws.String(bucket),
+		Key:                  aws.String(key),
+		UploadId:             aws.String(query.Get("uploadId")),
+		PartNumber:           aws.Int64(partNumber),
+		CopySource:           header(r, "X-Amz-Copy-Source"),
+		CopySourceRange:      header(r, "X-Amz-Copy-Source-Range"),
+		SSECustomerAlgorithm: algorithm,
+		SSECustomerKey:       sseKey,
+		SSECustomerKeyMD5:    sseKeyMD5,
+
+		CopySourceSSECustomerAlgorithm: srcAlgorithm,
+		CopySourceSSECustomerKey:       srcKey,
+		CopySourceSSECustomerKeyMD5:    srcKeyMD5,
+	})
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+	writeXML(rw, http.StatusOK, copyPartResult{
+		ETag:         aws.StringValue(out.CopyPartResult.ETag),
+		LastModified: aws.TimeValue(out.CopyPartResult.LastModified).UTC(),
+	})
+}
+
+func (h *handler) completeMultipartUpload(rw http.ResponseWriter, r *http.Request, bucket, key string) {
+	var req completeMultipartUploadRequest
+	err := xml.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeError(rw, r, errMalformedXML)
+		return
+	}
+
+	input := &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(r.URL.Query().Get("uploadId")),
+		MultipartUpload: &s3.CompletedMultipartUpload{},
+	}
+	for _, part := range req.Parts {
+		input.MultipartUpload.Parts = append(input.MultipartUpload.Parts, &s3.CompletedPart{
+			PartNumber: aws.Int64(part.PartNumber),
+			ETag:       aws.String(part.ETag),
+		})
+	}
+
+	out, err := h.backend.CompleteMultipartUploadWithContext(r.Context(), input, conditions(r)...)
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+	setHeader(rw, "X-Amz-Version-Id", out.VersionId)
+	writeXML(rw, http.StatusOK, completeMultipartUploadResult{
+		Bucket: bucket,
+		Key:    key,
+		ETag:   aws.StringValue(out.ETag),
+	})
+}
+
+func (h *handler) abortMultipartUpload(rw http.ResponseWriter, r *http.Request, bucket, key string) {
+	_, err := h.backend.AbortMultipartUploadWithContext(r.Context(), &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(r.URL.Query().Get("uploadId")),
+	})
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) listParts(rw http.ResponseWriter, r *http.Request, bucket, key string) {
+	uploadID := r.URL.Query().Get("uploadId")
+	result := listPartsResult{
+		Bucket:   bucket,
+		Key:      key,
+		UploadID: uploadID,
+	}
+	err := h.backend.ListPartsPagesWithContext(r.Context(), &s3.ListPartsInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	}, func(out *s3.ListPartsOutput, lastPage bool) bool {
+		for _, p := range out.Parts {
+			result.Parts = append(result.Parts, part{
+				PartNumber:   aws.Int64Value(p.PartNumber),
+				ETag:         aws.StringValue(p.ETag),
+				Size:         aws.Int64Value(p.Size),
+				LastModified: aws.TimeValue(p.LastModified).UTC(),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+	writeXML(rw, http.StatusOK, result)
+}
+
+func (h *handler) listMultipartUploads(rw http.ResponseWriter, r *http.Request, bucket string) {
+	prefix := r.URL.Query().Get("prefix")
+	result := listMultipartUploadsResult{
+		Bucket: bucket,
+		Prefix: prefix,
+	}
+	err := h.backend.ListMultipartUploadsPagesWithContext(r.Context(), &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}, func(out *s3.ListMultipartUploadsOutput, lastPage bool) bool {
+		for _, u := range out.Uploads {
+			result.Uploads = append(result.Uploads, upload{
+				Key:       aws.StringValue(u.Key),
+				UploadID:  aws.StringValue(u.UploadId),
+				Initiated: aws.TimeValue(u.Initiated).UTC(),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+	writeXML(rw, http.StatusOK, result)
+}
+
+func (h *handler) listObjectVersions(rw http.ResponseWriter, r *http.Request, bucket string) {
+	query := r.URL.Query()
+	input := &s3.ListObjectVersionsInput{
+		Bucket:          aws.String(bucket),
+		Prefix:          queryValue(query, "prefix"),
+		KeyMarker:       queryValue(query, "key-marker"),
+		VersionIdMarker: queryValue(query, "version-id-marker"),
+	}
+
+	// Only the first page is served, the client asks for the next one with the markers.
+	var result *listVersionsResult
+	err := h.backend.ListObjectVersionsPagesWithContext(r.Context(), input, func(out *s3.ListObjectVersionsOutput, lastPage bool) bool {
+		result = newListVersionsResult(bucket, out)
+		return false
+	})
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+	if result == nil {
+		result = newListVersionsResult(bucket, &s3.ListObjectVersionsOutput{
+			Prefix:          input.Prefix,
+			KeyMarker:       input.KeyMarker,
+			VersionIdMarker: input.VersionIdMarker,
+		})
+	}
+	writeXML(rw, http.StatusOK, result)
+}
+
+var (
+	errNotImplemented = awserr.NewRequestFailure(awserr.New("NotImplemented", "A header you provided implies functionality that is not implemented", nil), http.StatusNotImplemented, "")
+	errMalformedXML   = awserr.NewRequestFailure(awserr.New("MalformedXML", "The XML you provided was not well-formed", nil), http.StatusBadRequest, "")
+	errExpired        = awserr.NewRequestFailure(awserr.New("AccessDenied", "Request has expired", nil), http.StatusForbidden, "")
+)
+
+func errInvalidArgument(msg string) error {
+	return awserr.NewRequestFailure(awserr.New("InvalidArgument", msg, nil), http.StatusBadRequest, "")
+}
+
+// checkExpires rejects pre-signed requests past their expiry.
+func checkExpires(query url.Values) error {
+	date := query.Get("X-Amz-Date")
+	expires := query.Get("X-Amz-Expires")
+	if date == "" || expires == "" {
+		return nil
+	}
+	t, err := time.Parse("20060102T150405Z", date)
+	if err != nil {
+		return errInvalidArgument("invalid X-Amz-Date")
+	}
+	seconds, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return errInvalidArgument("invalid X-Amz-Expires")
+	}
+	if time.Now().After(t.Add(time.Duration(seconds) * time.Second)) {
+		return errExpired
+	}
+	return nil
+}
+
+func writeError(rw http.ResponseWriter, r *http.Request, err error) {
+	code := "InternalError"
+	status := http.StatusInternalServerError
+	message := err.Error()
+
+	var reqErr awserr.RequestFailure
+	var awsErr awserr.Error
+	switch {
+	case errors.As(err, &reqErr):
+		code = reqErr.Code()
+		status = reqErr.StatusCode()
+		message = reqErr.Message()
+	case errors.As(err, &awsErr):
+		code = awsErr.Code()
+		status = http.StatusBadRequest
+		message = awsErr.Message()
+	}
+
+	if r.Method == http.MethodHead {
+		rw.WriteHeader(status)
+		return
+	}
+	writeXML(rw, status, errorResponse{
+		Code:     code,
+		Message:  message,
+		Resource: r.URL.Path,
+	})
+}
+
+func writeXML(rw http.ResponseWriter, status int, v any) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/xml")
+	rw.Header().Set("Content-Length", strconv.Itoa(len(xml.Header)+len(data)))
+	rw.WriteHeader(status)
+	fmt.Fprint(rw, xml.Header)
+	rw.Write(data)
+}
+
+// header returns the request header, or nil if it is not set.
+func header(r *http.Request, name string) *string {
+	v := r.Header.Get(name)
+	if v == "" {
+		return nil
+	}
+	return aws.String(v)
+}
+
+// queryValue returns the query value, or nil if it is not set.
+func queryValue(query url.Values, name string) *string {
+	if !query.Has(name) {
+		return nil
+	}
+	return aws.String(query.Get(name))
+}
+
+// conditions passes the If-Match and If-None-Match headers of a write on to the backend.
+func conditions(r *http.Request) []request.Option {
+	h := map[string]string{}
+	for _, name := range []string{"If-Match", "If-None-Match"} {
+		if v := r.Header.Get(name); v != "" {
+			h[name] = v
+		}
+	}
+	if len(h) == 0 {
+		return nil
+	}
+	return []request.Option{request.WithSetRequestHeaders(h)}
+}
+
+// metadata returns the x-amz-meta-* headers of the request.
+func metadata(r *http.Request) map[string]*string {
+	var m map[string]*string
+	for name, values := range r.Header {
+		if k, ok := strings.CutPrefix(strings.ToLower(name), "x-amz-meta-"); ok {
+			if m == nil {
+				m = map[string]*string{}
+			}
+			m[k] = aws.String(values[0])
+		}
+	}
+	return m
+}
+
+const (
+	sseCustomerPrefix           = "X-Amz-Server-Side-Encryption-Customer-"
+	copySourceSSECustomerPrefix = "X-Amz-Copy-Source-Server-Side-Encryption-Customer-"
+)
+
+// sseCustomerKey returns the SSE-C headers of the request with the given prefix,
+// the key decoded from its base64 form.
+func sseCustomerKey(r *http.Request, prefix string) (algorithm, key, keyMD5 *string) {
+	key = header(r, prefix+"Key")
+	if key != nil {
+		if raw, err := base64.StdEncoding.DecodeString(*key); err == nil {
+			key = aws.String(string(raw))
+		}
+	}
+	return header(r, prefix+"Algorithm"), key, header(r, prefix+"Key-MD5")
+}
+
+func setSSECustomerKey(rw http.ResponseWriter, algorithm, keyMD5 *string) {
+	setHeader(rw, sseCustomerPrefix+"Algorithm", algorithm)
+	setHeader(rw, sseCustomerPrefix+"Key-MD5", keyMD5)
+}
+
+func setMetadata(rw http.ResponseWriter, m map[string]*string) {
+	for k, v := range m {
+		setHeader(rw, "X-Amz-Meta-"+k, v)
+	}
+}
+
+func setHeader(rw http.ResponseWriter, name string, v *string) {
+	if v != nil {
+		rw.Header().Set(name, *v)
+	}
+}
+
+func setTimeHeader(rw http.ResponseWriter, name string, t *time.Time) {
+	if t != nil {
+		rw.Header().Set(name, t.UTC().Format(http.TimeFormat))
+	}
+}
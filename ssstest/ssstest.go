@@ -0,0 +1,73 @@
+// Package ssstest provides an in-process S3 compatible endpoint for testing SSS
+// without a real object storage.
+package ssstest
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/wzshiming/sss"
+)
+
+const (
+	// Bucket is the bucket used by the SSS returned from Server.NewSSS.
+	Bucket = "ssstest"
+
+	accessKey = "ssstest"
+	secretKey = "ssstest"
+)
+
+// Server is a fake S3 endpoint listening on a local address.
+type Server struct {
+	*httptest.Server
+}
+
+// NewServer starts a Server keeping its objects in memory,
+// the caller should call Close when finished.
+func NewServer() *Server {
+	return NewServerWithBackend(sss.NewMemBackend())
+}
+
+// NewServerWithBackend starts a Server serving the objects of backend,
+// the caller should call Close when finished.
+func NewServerWithBackend(backend sss.Backend) *Server {
+	return &Server{
+		Server: httptest.NewServer(NewHandler(backend)),
+	}
+}
+
+// SSSURL returns the URL for sss.WithURL that points at bucket on the server.
+func (s *Server) SSSURL(bucket string) string {
+	query := url.Values{}
+	query.Set("regionendpoint", s.URL)
+	query.Set("forcepathstyle", "true")
+	u := url.URL{
+		Scheme:   "sss",
+		User:     url.UserPassword(accessKey, secretKey),
+		Host:     bucket + ".local",
+		Path:     "/",
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// NewSSS returns an SSS using the S3 backend against the server,
+// opts are applied after the URL of the server.
+func (s *Server) NewSSS(opts ...sss.Option) (*sss.SSS, error) {
+	return sss.NewSSS(append([]sss.Option{sss.WithURL(s.SSSURL(Bucket))}, opts...)...)
+}
+
+// NewSSS starts a Server for the duration of the test and returns an SSS wired to it.
+func NewSSS(tb testing.TB, opts ...sss.Option) *sss.SSS {
+	tb.Helper()
+
+	srv := NewServer()
+	tb.Cleanup(srv.Close)
+
+	s, err := srv.NewSSS(opts...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return s
+}
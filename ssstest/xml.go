@@ -0,0 +1,236 @@
+package ssstest
+
+import (
+	"encoding/xml"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// The types in this file are the XML documents of the S3 REST protocol.
+
+type errorResponse struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string
+	Message  string
+	Resource string
+}
+
+type object struct {
+	Key          string
+	LastModified time.Time
+	ETag         string
+	Size         int64
+	StorageClass string
+}
+
+type commonPrefix struct {
+	Prefix string
+}
+
+type listBucketResult struct {
+	XMLName               xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name                  string
+	Prefix                string
+	Delimiter             string `xml:",omitempty"`
+	MaxKeys               int64
+	KeyCount              int64  `xml:",omitempty"`
+	Marker                string `xml:",omitempty"`
+	NextMarker            string `xml:",omitempty"`
+	StartAfter            string `xml:",omitempty"`
+	ContinuationToken     string `xml:",omitempty"`
+	NextContinuationToken string `xml:",omitempty"`
+	IsTruncated           bool
+	Contents              []object
+	CommonPrefixes        []commonPrefix
+}
+
+func newListBucketResult(bucket string, out *s3.ListObjectsV2Output) *listBucketResult {
+	result := &listBucketResult{
+		Name:        bucket,
+		Prefix:      aws.StringValue(out.Prefix),
+		Delimiter:   aws.StringValue(out.Delimiter),
+		MaxKeys:     aws.Int64Value(out.MaxKeys),
+		IsTruncated: aws.BoolValue(out.IsTruncated),
+	}
+	for _, obj := range out.Contents {
+		result.Contents = append(result.Contents, object{
+			Key:          aws.StringValue(obj.Key),
+			LastModified: aws.TimeValue(obj.LastModified).UTC(),
+			ETag:         aws.StringValue(obj.ETag),
+			Size:         aws.Int64Value(obj.Size),
+			StorageClass: aws.StringValue(obj.StorageClass),
+		})
+	}
+	for _, p := range out.CommonPrefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{
+			Prefix: aws.StringValue(p.Prefix),
+		})
+	}
+	return result
+}
+
+type copyObjectResult struct {
+	XMLName      xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CopyObjectResult"`
+	ETag         string
+	LastModified time.Time
+}
+
+type copyPartResult struct {
+	XMLName      xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CopyPartResult"`
+	ETag         string
+	LastModified time.Time
+}
+
+type deleteRequest struct {
+	Quiet   bool
+	Objects []struct {
+		Key       string
+		VersionID *string `xml:"VersionId"`
+	} `xml:"Object"`
+}
+
+type deletedObject struct {
+	Key                   string
+	VersionID             string `xml:"VersionId,omitempty"`
+	DeleteMarker          bool   `xml:",omitempty"`
+	DeleteMarkerVersionID string `xml:"DeleteMarkerVersionId,omitempty"`
+}
+
+type deleteError struct {
+	Key     string
+	Code    string
+	Message string
+}
+
+type deleteResult struct {
+	XMLName xml.Name        `xml:"http://s3.amazonaws.com/doc/2006-03-01/ DeleteResult"`
+	Deleted []deletedObject `xml:"Deleted"`
+	Errors  []deleteError   `xml:"Error"`
+}
+
+type version struct {
+	Key          string
+	VersionID    string `xml:"VersionId"`
+	IsLatest     bool
+	LastModified time.Time
+	ETag         string
+	Size         int64
+	StorageClass string
+}
+
+type deleteMarker struct {
+	Key          string
+	VersionID    string `xml:"VersionId"`
+	IsLatest     bool
+	LastModified time.Time
+}
+
+type listVersionsResult struct {
+	XMLName             xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListVersionsResult"`
+	Name                string
+	Prefix              string
+	KeyMarker           string
+	VersionIDMarker     string `xml:"VersionIdMarker"`
+	NextKeyMarker       string `xml:",omitempty"`
+	NextVersionIDMarker string `xml:"NextVersionIdMarker,omitempty"`
+	MaxKeys             int64
+	IsTruncated         bool
+	Versions            []version      `xml:"Version"`
+	DeleteMarkers       []deleteMarker `xml:"DeleteMarker"`
+}
+
+func newListVersionsResult(bucket string, out *s3.ListObjectVersionsOutput) *listVersionsResult {
+	result := &listVersionsResult{
+		Name:                bucket,
+		Prefix:              aws.StringValue(out.Prefix),
+		KeyMarker:           aws.StringValue(out.KeyMarker),
+		VersionIDMarker:     aws.StringValue(out.VersionIdMarker),
+		NextKeyMarker:       aws.StringValue(out.NextKeyMarker),
+		NextVersionIDMarker: aws.StringValue(out.NextVersionIdMarker),
+		MaxKeys:             aws.Int64Value(out.MaxKeys),
+		IsTruncated:         aws.BoolValue(out.IsTruncated),
+	}
+	for _, v := range out.Versions {
+		result.Versions = append(result.Versions, version{
+			Key:          aws.StringValue(v.Key),
+			VersionID:    aws.StringValue(v.VersionId),
+			IsLatest:     aws.BoolValue(v.IsLatest),
+			LastModified: aws.TimeValue(v.LastModified).UTC(),
+			ETag:         aws.StringValue(v.ETag),
+			Size:         aws.Int64Value(v.Size),
+			StorageClass: aws.StringValue(v.StorageClass),
+		})
+	}
+	for _, m := range out.DeleteMarkers {
+		result.DeleteMarkers = append(result.DeleteMarkers, deleteMarker{
+			Key:          aws.StringValue(m.Key),
+			VersionID:    aws.StringValue(m.VersionId),
+			IsLatest:     aws.BoolValue(m.IsLatest),
+			LastModified: aws.TimeValue(m.LastModified).UTC(),
+		})
+	}
+	return result
+}
+
+type tag struct {
+	Key   string
+	Value string
+}
+
+type tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	TagSet  []tag    `xml:"TagSet>Tag"`
+}
+
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ InitiateMultipartUploadResult"`
+	Bucket   string
+	Key      string
+	UploadID string `xml:"UploadId"`
+}
+
+type completeMultipartUploadRequest struct {
+	Parts []struct {
+		PartNumber int64
+		ETag       string
+	} `xml:"Part"`
+}
+
+type completeMultipartUploadResult struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CompleteMultipartUploadResult"`
+	Bucket  string
+	Key     string
+	ETag    string
+}
+
+type part struct {
+	PartNumber   int64
+	LastModified time.Time
+	ETag         string
+	Size         int64
+}
+
+type listPartsResult struct {
+	XMLName     xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListPartsResult"`
+	Bucket      string
+	Key         string
+	UploadID    string `xml:"UploadId"`
+	IsTruncated bool
+	Parts       []part `xml:"Part"`
+}
+
+type upload struct {
+	Key       string
+	UploadID  string `xml:"UploadId"`
+	Initiated time.Time
+}
+
+type listMultipartUploadsResult struct {
+	XMLName     xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListMultipartUploadsResult"`
+	Bucket      string
+	Prefix      string
+	IsTruncated bool
+	Uploads     []upload `xml:"Upload"`
+}
@@ -0,0 +1,68 @@
+package sss_test
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/wzshiming/sss"
+	"github.com/wzshiming/sss/ssstest"
+)
+
+const bandwidthLimit = 128 << 10
+
+func TestBandwidthLimit(t *testing.T) {
+	srv := &ssstest.Server{Server: httptest.NewServer(ssstest.NewHandler(sss.NewMemBackend()))}
+	defer srv.Close()
+
+	s, err := srv.NewSSS(sss.WithChunkSize(32<<10), sss.WithBandwidthLimit(bandwidthLimit, bandwidthLimit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testBandwidthLimit(t, s)
+}
+
+func TestBandwidthLimitBackend(t *testing.T) {
+	s, err := sss.NewSSS(sss.WithBackend(sss.NewMemBackend()), sss.WithBucket("limited"), sss.WithChunkSize(32<<10), sss.WithBandwidthLimit(bandwidthLimit, bandwidthLimit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testBandwidthLimit(t, s)
+}
+
+func testBandwidthLimit(t *testing.T, s *sss.SSS) {
+	// The first 32KiB pass at once, the rest at the limit.
+	content := bytes.Repeat([]byte("0123456789abcdef"), (160<<10)/16)
+	minElapsed := 900 * time.Millisecond
+
+	start := time.Now()
+	w, err := s.Writer(t.Context(), "limited", sss.WithConcurrency(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.Write(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Commit(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if elapsed := time.Since(start); elapsed < minElapsed {
+		t.Fatalf("expected the upload to take at least %s, took %s", minElapsed, elapsed)
+	}
+
+	start = time.Now()
+	got, err := s.GetContent(t.Context(), "limited")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < minElapsed {
+		t.Fatalf("expected the download to take at least %s, took %s", minElapsed, elapsed)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatal("expected the uploaded content")
+	}
+}
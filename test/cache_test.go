@@ -0,0 +1,335 @@
+package sss_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wzshiming/sss"
+	"github.com/wzshiming/sss/fs"
+)
+
+func TestCache(t *testing.T) {
+	var gets atomic.Int64
+	countGets := sss.WithObserver(func(e sss.Event) {
+		if e.Operation == "GetObject" {
+			gets.Add(1)
+		}
+	})
+	dir := t.TempDir()
+	cached, err := newSSS(sss.WithCache(dir, 64<<20), countGets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := "test-cache"
+	t.Cleanup(func() {
+		s.Delete(context.Background(), key)
+	})
+
+	const blockSize = 4 << 20
+	content := make([]byte, 2*blockSize+123)
+	rand.New(rand.NewSource(1)).Read(content)
+	err = s.PutContent(t.Context(), key, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		got, err := cached.GetContent(t.Context(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Fatal("expected the content through the cache")
+		}
+		if n := gets.Load(); n != 1 {
+			t.Fatalf("expected the 3 blocks to be fetched once by one GET, got %d GETs", n)
+		}
+	}
+
+	r, err := cached.ReaderWithOffsetAndLimit(t.Context(), key, blockSize-10, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content[blockSize-10:blockSize+10]) {
+		t.Fatal("expected the range across blocks")
+	}
+
+	f, err := fs.NewFS(t.Context(), cached, "/").Open(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.(io.Seeker).Seek(2*blockSize, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content[2*blockSize:]) {
+		t.Fatal("expected the content after seeking")
+	}
+	if n := gets.Load(); n != 1 {
+		t.Fatalf("expected the ranges to be read from the cache, got %d GETs", n)
+	}
+
+	info, err := cached.Stat(t.Context(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := *info.Sys().(sss.FileInfoExpansion).ETag
+
+	updated := append([]byte("updated"), content...)
+	err = s.PutContent(t.Context(), key, updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = cached.GetContent(t.Context(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, updated) {
+		t.Fatal("expected the cache to revalidate the ETag")
+	}
+	_, err = cached.GetContent(t.Context(), key, sss.WithETag(etag))
+	if !errors.Is(err, sss.ErrObjectChanged) {
+		t.Fatalf("expected ErrObjectChanged for the old ETag, got %v", err)
+	}
+
+	small := t.TempDir()
+	evicting, err := newSSS(sss.WithCache(small, blockSize+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = evicting.GetContent(t.Context(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, updated) {
+		t.Fatal("expected the content through a small cache")
+	}
+	entries, err := os.ReadDir(small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var size int64
+	for _, entry := range entries {
+		fi, err := os.Stat(filepath.Join(small, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		size += fi.Size()
+	}
+	if size > blockSize+1 {
+		t.Fatalf("expected the cache to be evicted down to %d bytes, got %d", blockSize+1, size)
+	}
+
+	abandoned := t.TempDir()
+	abandoning, err := newSSS(sss.WithCache(abandoned, 64<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err = abandoning.Reader(t.Context(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.ReadFull(r, make([]byte, 10))
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err = os.ReadDir(abandoned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected the blocks of an abandoned read to be dropped, got %d files", len(entries))
+	}
+}
+
+func TestCacheWithMetadataCache(t *testing.T) {
+	var heads, gets atomic.Int64
+	count := sss.WithObserver(func(e sss.Event) {
+		switch e.Operation {
+		case "HeadObject":
+			heads.Add(1)
+		case "GetObject":
+			gets.Add(1)
+		}
+	})
+	cached, err := newSSS(sss.WithCache(t.TempDir(), 64<<20), sss.WithMetadataCache(time.Minute), count)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := "test-cache-metadata"
+	t.Cleanup(func() {
+		s.Delete(context.Background(), key)
+	})
+	content := make([]byte, 5<<20)
+	rand.New(rand.NewSource(1)).Read(content)
+	err = cached.PutContent(t.Context(), key, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		r, err := cached.ReaderWithOffsetAndLimit(t.Context(), key, 1<<20, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, content[1<<20:1<<20+10]) {
+			t.Fatal("expected the range through the caches")
+		}
+	}
+	if n := heads.Load(); n != 1 {
+		t.Fatalf("expected the ETag to be revalidated once, got %d HEADs", n)
+	}
+	if n := gets.Load(); n != 1 {
+		t.Fatalf("expected the block to be fetched once, got %d GETs", n)
+	}
+
+	updated := append([]byte("updated"), content...)
+	err = cached.PutContent(t.Context(), key, updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := cached.GetContent(t.Context(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, updated) {
+		t.Fatal("expected the content written through the SSS")
+	}
+}
+
+func TestCacheReadAfterOverwrite(t *testing.T) {
+	cached, err := newSSS(sss.WithCache(t.TempDir(), 64<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := "test-cache-overwrite"
+	t.Cleanup(func() {
+		s.Delete(context.Background(), key)
+	})
+	const blockSize = 4 << 20
+	content := make([]byte, 2*blockSize)
+	rand.New(rand.NewSource(1)).Read(content)
+	err = s.PutContent(t.Context(), key, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := s.StatHead(t.Context(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := *info.Sys().(sss.FileInfoExpansion).ETag
+
+	// Cache the first block only, the second is fetched once read.
+	r, err := cached.ReaderWithOffsetAndLimit(t.Context(), key, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err = cached.Reader(t.Context(), key, sss.WithETag(etag))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	unpinned, err := cached.Reader(t.Context(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unpinned.Close()
+	err = s.PutContent(t.Context(), key, []byte("Bye, Cache!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.ReadAll(r)
+	if !errors.Is(err, sss.ErrObjectChanged) {
+		t.Fatalf("expected reading after an overwrite to fail with ErrObjectChanged, got %v", err)
+	}
+	_, err = io.ReadAll(unpinned)
+	if !errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, sss.ErrPreconditionFailed) {
+		t.Fatalf("expected reading without an ETag after an overwrite to fail with io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestCacheWithMetadataCacheOverwrittenByOthers(t *testing.T) {
+	backend := sss.NewMemBackend()
+	other, err := sss.NewSSS(sss.WithURL(memURL), sss.WithBackend(backend))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached, err := sss.NewSSS(sss.WithURL(memURL), sss.WithBackend(backend),
+		sss.WithCache(t.TempDir(), 64<<20), sss.WithMetadataCache(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := "test-cache-overwritten"
+	err = other.PutContent(t.Context(), key, []byte("Hello, Cache!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := cached.StatHead(t.Context(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := *info.Sys().(sss.FileInfoExpansion).ETag
+
+	updated := []byte("Bye, Cache!")
+	err = other.PutContent(t.Context(), key, updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := cached.GetContent(t.Context(), key)
+	if err != nil {
+		t.Fatalf("expected reading past the stale ETag to succeed, got %v", err)
+	}
+	if !bytes.Equal(got, updated) {
+		t.Fatalf("expected %q, got %q", updated, got)
+	}
+
+	info, err = other.StatHead(t.Context(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = cached.GetContent(t.Context(), key, sss.WithETag(*info.Sys().(sss.FileInfoExpansion).ETag))
+	if err != nil {
+		t.Fatalf("expected reading pinned to the new ETag to succeed, got %v", err)
+	}
+	if !bytes.Equal(got, updated) {
+		t.Fatalf("expected %q, got %q", updated, got)
+	}
+	_, err = cached.GetContent(t.Context(), key, sss.WithETag(etag))
+	if !errors.Is(err, sss.ErrObjectChanged) {
+		t.Fatalf("expected ErrObjectChanged for the old ETag, got %v", err)
+	}
+}
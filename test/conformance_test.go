@@ -0,0 +1,18 @@
+package sss_test
+
+import (
+	"testing"
+
+	"github.com/wzshiming/sss"
+	"github.com/wzshiming/sss/ssstest"
+)
+
+func TestConformance(t *testing.T) {
+	ssstest.RunConformance(t, func(opts ...sss.Option) *sss.SSS {
+		s, err := newSSS(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
@@ -0,0 +1,98 @@
+package sss_test
+
+import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/wzshiming/sss"
+)
+
+func TestCopyEscapedKey(t *testing.T) {
+	src := "test-copy-escaped/a b+c ü?d"
+	dst := "test-copy-escaped/copy"
+	t.Cleanup(func() {
+		s.Delete(context.Background(), src)
+		s.Delete(context.Background(), dst)
+	})
+
+	err := s.PutContent(t.Context(), src, []byte("escaped"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Copy(t.Context(), src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetContent(t.Context(), dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "escaped" {
+		t.Fatalf("expected %q, got %q", "escaped", got)
+	}
+}
+
+type partCopyBackend struct {
+	sss.Backend
+
+	mut    sync.Mutex
+	ranges []string
+}
+
+func (b *partCopyBackend) UploadPartCopyWithContext(ctx aws.Context, input *s3.UploadPartCopyInput, opts ...request.Option) (*s3.UploadPartCopyOutput, error) {
+	b.mut.Lock()
+	b.ranges = append(b.ranges, aws.StringValue(input.CopySourceRange))
+	b.mut.Unlock()
+	return b.Backend.UploadPartCopyWithContext(ctx, input, opts...)
+}
+
+func TestCopySmallThreshold(t *testing.T) {
+	b := &partCopyBackend{}
+	fs, err := newSSS(sss.WithMultipartCopyThreshold(1024*1024), sss.WithBackendMiddleware(func(backend sss.Backend) sss.Backend {
+		b.Backend = backend
+		return b
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := "test-copy-small-threshold"
+	t.Cleanup(func() {
+		s.Delete(context.Background(), src)
+		s.Delete(context.Background(), src+"-copy")
+	})
+	want := make([]byte, 6*1024*1024)
+	_, err = crand.Read(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fs.PutContent(t.Context(), src, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fs.Copy(t.Context(), src, src+"-copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(b.ranges)
+	wantRanges := []string{"bytes=0-5242879", "bytes=5242880-6291455"}
+	if !reflect.DeepEqual(b.ranges, wantRanges) {
+		t.Fatalf("expected the parts %v, got %v", wantRanges, b.ranges)
+	}
+	got, err := fs.GetContent(t.Context(), src+"-copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("expected %d bytes, got %d bytes with different content", len(want), len(got))
+	}
+}
@@ -0,0 +1,168 @@
+package sss_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/wzshiming/sss"
+	"github.com/wzshiming/sss/fs"
+)
+
+func TestClientEncryption(t *testing.T) {
+	key1 := bytes.Repeat([]byte{1}, 32)
+	key2 := bytes.Repeat([]byte{2}, 32)
+	enc, err := newSSS(sss.WithClientEncryption("k1", key1), sss.WithClientSegmentSize(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := "test-cse"
+	t.Cleanup(func() {
+		ctx := context.Background()
+		for _, p := range []string{src, src + "-writer", src + "-empty", src + "-copy", src + "-rotated"} {
+			s.Delete(ctx, p)
+		}
+	})
+
+	content := make([]byte, 10*1000+123)
+	rand.New(rand.NewSource(1)).Read(content)
+
+	err = enc.PutContent(t.Context(), src, content, sss.WithMetadata(map[string]string{"owner": "ci"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := enc.Writer(t.Context(), src+"-writer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(content); i += 777 {
+		_, err = w.Write(content[i:min(i+777, len(content))])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = w.Commit(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	err = enc.PutContent(t.Context(), src+"-empty", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := s.GetContent(t.Context(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) <= len(content) || bytes.Contains(stored, content[:64]) {
+		t.Fatal("expected the stored object to be encrypted")
+	}
+
+	for _, p := range []string{src, src + "-writer"} {
+		got, info, err := enc.GetContentAndInfo(t.Context(), p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Fatalf("%s: expected the decrypted content", p)
+		}
+		if info.Size() != int64(len(content)) {
+			t.Fatalf("%s: expected size %d, got %d", p, len(content), info.Size())
+		}
+		info, err = enc.Stat(t.Context(), p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != int64(len(content)) {
+			t.Fatalf("%s: expected stat size %d, got %d", p, len(content), info.Size())
+		}
+	}
+	got, err := enc.GetContent(t.Context(), src+"-empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("expected empty content, got %d bytes", len(got))
+	}
+
+	for _, rng := range [][2]int64{{1, 10}, {999, 2}, {1000, 1000}, {2500, 5000}, {10000, 123}, {10100, 1000}} {
+		r, err := enc.ReaderWithOffsetAndLimit(t.Context(), src+"-writer", rng[0], rng[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := content[rng[0]:min(rng[0]+rng[1], int64(len(content)))]
+		if !bytes.Equal(got, want) {
+			t.Fatalf("range %v: expected %d bytes of the content, got %d", rng, len(want), len(got))
+		}
+	}
+
+	f, err := fs.NewFS(t.Context(), enc, "/").Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	seeker := f.(io.ReadSeeker)
+	_, err = seeker.Seek(4321, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 100)
+	_, err = io.ReadFull(seeker, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, content[4321:4421]) {
+		t.Fatal("expected the content after seeking")
+	}
+
+	err = enc.Copy(t.Context(), src, src+"-copy", sss.WithMetadata(map[string]string{"owner": "release"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, info, err := enc.GetContentAndInfo(t.Context(), src+"-copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatal("expected the copy to decrypt")
+	}
+	if m := info.Sys().(sss.FileInfoExpansion).Metadata; len(m) != 1 || m["owner"] != "release" {
+		t.Fatalf("expected only the replaced metadata, got %v", m)
+	}
+
+	rotated, err := newSSS(sss.WithClientEncryption("k2", key2), sss.WithClientKeyring(map[string][]byte{"k1": key1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sss.CopyBetween(t.Context(), enc, src, rotated, src+"-rotated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = rotated.GetContent(t.Context(), src+"-rotated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatal("expected the rotated copy to decrypt")
+	}
+	got, err = rotated.GetContent(t.Context(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatal("expected the keyring to decrypt objects of the old key")
+	}
+	_, err = enc.GetContent(t.Context(), src+"-rotated")
+	if err == nil {
+		t.Fatal("expected reading without the key to fail")
+	}
+}
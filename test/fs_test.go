@@ -0,0 +1,124 @@
+package sss_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	iofs "io/fs"
+	"testing"
+
+	"github.com/wzshiming/sss"
+	"github.com/wzshiming/sss/fs"
+)
+
+func TestFS(t *testing.T) {
+	content := []byte("Hello, FS!")
+	for _, key := range []string{"fs/a/file", "fs/b/file"} {
+		err := s.PutContent(t.Context(), key, content)
+		if err != nil {
+			t.Fatalf("failed to put object: %v", err)
+		}
+	}
+
+	fsys := fs.NewFS(t.Context(), s, "/fs")
+
+	got, err := iofs.ReadFile(fsys, "a/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(content) {
+		t.Fatalf("expected %s, got %s", content, got)
+	}
+
+	f, err := fsys.Open("b/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len(content)) {
+		t.Fatalf("expected size %d, got %d", len(content), info.Size())
+	}
+
+	_, err = f.(io.Seeker).Seek(7, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(content[7:]) {
+		t.Fatalf("expected %s, got %s", content[7:], got)
+	}
+
+	entries, err := iofs.ReadDir(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Name() != "a" || entries[1].Name() != "b" {
+		t.Fatalf("expected directories a and b, got %v", entries)
+	}
+
+	err = s.DeleteAll(t.Context(), "fs")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFSObjectChanged(t *testing.T) {
+	key := "fs-changed/file"
+	err := s.PutContent(t.Context(), key, []byte("Hello, FS!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		s.Delete(context.Background(), key)
+	})
+
+	f, err := fs.NewFS(t.Context(), s, "/").Open(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(f, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.PutContent(t.Context(), key, []byte("Bye, FS!!!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.(io.Seeker).Seek(7, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.ReadAll(f)
+	if !errors.Is(err, sss.ErrObjectChanged) {
+		t.Fatalf("expected reading after an overwrite to fail with ErrObjectChanged, got %v", err)
+	}
+}
+
+func TestFSNotExist(t *testing.T) {
+	fsys := fs.NewFS(t.Context(), s, "/fs-not-exist")
+	_, err := fsys.Open("file")
+	if !errors.Is(err, iofs.ErrNotExist) {
+		t.Fatalf("expected opening a missing file to fail with fs.ErrNotExist, got %v", err)
+	}
+	_, err = iofs.Stat(fsys, "file")
+	if !errors.Is(err, iofs.ErrNotExist) {
+		t.Fatalf("expected stating a missing file to fail with fs.ErrNotExist, got %v", err)
+	}
+	_, err = iofs.ReadFile(fsys, "file")
+	var pathErr *iofs.PathError
+	if !errors.As(err, &pathErr) || !errors.Is(err, sss.ErrNotExist) {
+		t.Fatalf("expected reading a missing file to fail with a *fs.PathError, got %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package sss_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/wzshiming/sss"
+)
+
+func TestListEmptyFile(t *testing.T) {
+	dir := "/test-list-empty"
+	t.Cleanup(func() {
+		s.Delete(context.Background(), dir+"/empty")
+	})
+
+	err := s.PutContent(t.Context(), dir+"/empty", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	err = s.List(t.Context(), dir, func(fileInfo sss.FileInfo) bool {
+		if fileInfo.IsDir() {
+			got = append(got, fileInfo.Path()+"/")
+		} else {
+			got = append(got, fileInfo.Path())
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{dir + "/empty"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
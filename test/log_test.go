@@ -0,0 +1,98 @@
+package sss_test
+
+import (
+	"bytes"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wzshiming/sss"
+	"github.com/wzshiming/sss/ssstest"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: sss.LevelTrace}))
+
+	info, err := sss.NewSSS(sss.WithURL("mem://"+bucket+".local/?loglevel=info"), sss.WithLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = info.PutContent(t.Context(), "logged", []byte("logged"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := info.Writer(t.Context(), "logged")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("logged"))
+	err = w.Commit(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if !strings.Contains(buf.String(), `"msg":"complete multipart upload","key":"logged"`) {
+		t.Fatalf("expected the commit to be logged, got:\n%s", buf.String())
+	}
+	if strings.Contains(buf.String(), `"level":"DEBUG"`) {
+		t.Fatalf("expected no debug record at loglevel=info, got:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	trace := ssstest.NewSSS(t, sss.WithLogger(logger))
+	err = trace.PutContent(t.Context(), "traced", []byte("traced"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = trace.SignGet("traced", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := buf.String()
+	for _, want := range []string{`"msg":"aws sdk"`, `Authorization: REDACTED`, `"msg":"presign","operation":"GetObject"`, `X-Amz-Signature=REDACTED`} {
+		if !strings.Contains(logs, want) {
+			t.Fatalf("expected %q in the logs:\n%s", want, logs)
+		}
+	}
+	if regexp.MustCompile(`Signature=[0-9a-f]{64}|Credential=ssstest`).MatchString(logs) {
+		t.Fatalf("expected the credentials to be redacted:\n%s", logs)
+	}
+}
+
+func TestLoggerLevels(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: sss.LevelTrace}))
+
+	for level, want := range map[string]slog.Level{
+		"warn":                    slog.LevelWarn,
+		"ERROR":                   slog.LevelError,
+		"debugwithhttpbody":       sss.LevelTrace,
+		"debugwithrequestretries": sss.LevelTrace,
+	} {
+		leveled, err := sss.NewSSS(sss.WithURL("mem://"+bucket+".local/?loglevel="+level), sss.WithLogger(logger))
+		if err != nil {
+			t.Fatalf("expected loglevel=%s to be accepted, got %v", level, err)
+		}
+		ctx := t.Context()
+		if !leveled.Logger().Enabled(ctx, want) || leveled.Logger().Enabled(ctx, want-1) {
+			t.Fatalf("expected loglevel=%s to log from %s", level, want)
+		}
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected no warning for known levels, got:\n%s", buf.String())
+	}
+
+	unknown, err := sss.NewSSS(sss.WithURL("mem://"+bucket+".local/?loglevel=verbose"), sss.WithLogger(logger))
+	if err != nil {
+		t.Fatalf("expected an unknown loglevel to be ignored, got %v", err)
+	}
+	if !unknown.Logger().Enabled(t.Context(), sss.LevelTrace) {
+		t.Fatal("expected an unknown loglevel to leave the level to the logger")
+	}
+	if !strings.Contains(buf.String(), `"level":"WARN","msg":"ignore unknown loglevel`) || !strings.Contains(buf.String(), `"loglevel":"verbose"`) {
+		t.Fatalf("expected a warning for the unknown loglevel, got:\n%s", buf.String())
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"testing"
 	"time"
@@ -12,23 +13,81 @@ import (
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/wzshiming/sss"
+	"github.com/wzshiming/sss/ssstest"
 )
 
 var (
 	s      *sss.SSS
 	bucket = "sss-test-bucket"
 
-	url = `sss://minioadmin:minioadmin@` + bucket + `.region?forcepathstyle=true&secure=false&chunksize=` + strconv.Itoa(5*1024*1024) + `&regionendpoint=http://127.0.0.1:9000`
+	// newSSS returns another SSS sharing the storage of s.
+	newSSS func(opts ...sss.Option) (*sss.SSS, error)
+
+	chunkSize = strconv.Itoa(5 * 1024 * 1024)
+
+	minioURL = `sss://minioadmin:minioadmin@` + bucket + `.region?forcepathstyle=true&secure=false&chunksize=` + chunkSize + `&regionendpoint=http://127.0.0.1:9000`
+	memURL   = `mem://` + bucket + `.local/?chunksize=` + chunkSize
 )
 
+// TestMain runs the tests against the backend named by SSS_TEST_BACKEND,
+// "mem" (the default), "file" in a temporary directory, "fake" for the S3 backend against
+// an ssstest.Server or "minio" started with docker compose.
 func TestMain(m *testing.M) {
+	switch backend := os.Getenv("SSS_TEST_BACKEND"); backend {
+	case "", "mem":
+		os.Exit(runMem(m))
+	case "file":
+		os.Exit(runFile(m))
+	case "fake":
+		os.Exit(runFake(m))
+	case "minio":
+		os.Exit(runMinIO(m))
+	default:
+		log.Fatalf("unknown SSS_TEST_BACKEND %q", backend)
+	}
+}
+
+func setup(opts ...sss.Option) {
+	newSSS = func(more ...sss.Option) (*sss.SSS, error) {
+		return sss.NewSSS(append(opts[:len(opts):len(opts)], more...)...)
+	}
+
 	var err error
-	s, err = sss.NewSSS(sss.WithURL(url))
+	s, err = newSSS()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runMem(m *testing.M) int {
+	// Every mem:// URL gets its own storage, so share one backend explicitly.
+	setup(sss.WithURL(memURL), sss.WithBackend(sss.NewMemBackend()))
+	return m.Run()
+}
+
+func runFile(m *testing.M) int {
+	dir, err := os.MkdirTemp("", "sss-test-")
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer os.RemoveAll(dir)
+
+	setup(sss.WithURL(`file://` + filepath.ToSlash(dir) + `?chunksize=` + chunkSize))
+	return m.Run()
+}
+
+func runFake(m *testing.M) int {
+	srv := ssstest.NewServer()
+	defer srv.Close()
+
+	setup(sss.WithURL(srv.SSSURL(bucket)), sss.WithChunkSize(5*1024*1024))
+	return m.Run()
+}
+
+func runMinIO(m *testing.M) int {
+	setup(sss.WithURL(minioURL))
 
-	err = exec.Command("docker", "compose", "up", "-d").Run()
+	err := exec.Command("docker", "compose", "up", "-d").Run()
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -58,11 +117,12 @@ func TestMain(m *testing.M) {
 
 	code := m.Run()
 	if code != 0 {
-		os.Exit(code)
+		return code
 	}
 
 	err = exec.Command("docker", "compose", "down").Run()
 	if err != nil {
 		log.Fatal(err)
 	}
+	return code
 }
@@ -0,0 +1,169 @@
+package sss_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wzshiming/sss"
+)
+
+func TestMetadataCache(t *testing.T) {
+	var mut sync.Mutex
+	calls := map[string]int{}
+	cached, err := newSSS(sss.WithMetadataCache(time.Minute), sss.WithObserver(func(e sss.Event) {
+		mut.Lock()
+		defer mut.Unlock()
+		calls[e.Operation]++
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := func(operation string) int {
+		mut.Lock()
+		defer mut.Unlock()
+		return calls[operation]
+	}
+
+	dir := "test-metadata-cache"
+	key := dir + "/object"
+	t.Cleanup(func() {
+		ctx := context.Background()
+		s.Delete(ctx, key)
+		s.Delete(ctx, dir+"/copy")
+		s.Delete(ctx, dir+"/external")
+	})
+
+	for i := 0; i < 2; i++ {
+		_, err = cached.Stat(t.Context(), key)
+		if !errors.Is(err, sss.ErrNotExist) {
+			t.Fatalf("expected ErrNotExist, got %v", err)
+		}
+	}
+	if n := count("HeadObject"); n != 1 {
+		t.Fatalf("expected the missing object to be cached, got %d HEADs", n)
+	}
+
+	err = cached.PutContent(t.Context(), key, []byte("cached"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		info, err := cached.Stat(t.Context(), key)
+		if err != nil {
+			t.Fatalf("expected the put to invalidate the cache, got %v", err)
+		}
+		if info.Size() != 6 {
+			t.Fatalf("expected size 6, got %d", info.Size())
+		}
+	}
+	if n := count("HeadObject"); n != 2 {
+		t.Fatalf("expected the object to be cached, got %d HEADs", n)
+	}
+
+	list := func() []string {
+		t.Helper()
+		var paths []string
+		err := cached.List(t.Context(), dir, func(fi sss.FileInfo) bool {
+			paths = append(paths, fi.Path())
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return paths
+	}
+	list()
+	lists := count("ListObjectsV2")
+	if paths := list(); len(paths) != 1 || count("ListObjectsV2") != lists {
+		t.Fatalf("expected the listing to be cached, got %v", paths)
+	}
+
+	err = cached.Copy(t.Context(), key, dir+"/copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paths := list(); len(paths) != 2 {
+		t.Fatalf("expected the copy to invalidate the listing, got %v", paths)
+	}
+
+	err = s.PutContent(t.Context(), dir+"/external", []byte("external"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paths := list(); len(paths) != 2 {
+		t.Fatalf("expected the listing to stay cached for writes of others, got %v", paths)
+	}
+
+	err = cached.Delete(t.Context(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cached.Stat(t.Context(), key)
+	if !errors.Is(err, sss.ErrNotExist) {
+		t.Fatalf("expected the delete to invalidate the cache, got %v", err)
+	}
+	if paths := list(); len(paths) != 2 {
+		t.Fatalf("expected the copy and the external object, got %v", paths)
+	}
+
+	expiring, err := newSSS(sss.WithMetadataCache(10 * time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = expiring.Stat(t.Context(), key)
+	if !errors.Is(err, sss.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist, got %v", err)
+	}
+	err = s.PutContent(t.Context(), key, []byte("external"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	_, err = expiring.Stat(t.Context(), key)
+	if err != nil {
+		t.Fatalf("expected the negative entry to expire, got %v", err)
+	}
+}
+
+func TestMetadataCacheSize(t *testing.T) {
+	var mut sync.Mutex
+	heads := map[string]int{}
+	cached, err := newSSS(sss.WithMetadataCache(time.Minute), sss.WithObserver(func(e sss.Event) {
+		if e.Operation == "HeadObject" {
+			mut.Lock()
+			defer mut.Unlock()
+			heads[e.Key]++
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One more missing object than the cache keeps.
+	const n = 10001
+	for i := 0; i < n; i++ {
+		_, err = cached.StatHead(t.Context(), fmt.Sprintf("test-metadata-cache-size/%05d", i))
+		if !errors.Is(err, sss.ErrNotExist) {
+			t.Fatalf("expected ErrNotExist, got %v", err)
+		}
+	}
+	for _, i := range []int{0, n - 1} {
+		_, err = cached.StatHead(t.Context(), fmt.Sprintf("test-metadata-cache-size/%05d", i))
+		if !errors.Is(err, sss.ErrNotExist) {
+			t.Fatalf("expected ErrNotExist, got %v", err)
+		}
+	}
+
+	mut.Lock()
+	defer mut.Unlock()
+	if got := heads["test-metadata-cache-size/00000"]; got != 2 {
+		t.Fatalf("expected the oldest answer to be dropped, got %d HEADs", got)
+	}
+	if got := heads[fmt.Sprintf("test-metadata-cache-size/%05d", n-1)]; got != 1 {
+		t.Fatalf("expected the newest answer to be kept, got %d HEADs", got)
+	}
+}
@@ -0,0 +1,216 @@
+package sss_test
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/wzshiming/sss"
+)
+
+// interruptBackend fails copies, batch deletes and saves of the move record once their limit is used up.
+type interruptBackend struct {
+	sss.Backend
+	copies  *atomic.Int64
+	deletes *atomic.Int64
+	records *atomic.Int64
+}
+
+func (b interruptBackend) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	if strings.HasSuffix(aws.StringValue(input.Key), ".sss-move") && b.records.Add(-1) < 0 {
+		return nil, errors.New("interrupted save")
+	}
+	return b.Backend.PutObjectWithContext(ctx, input, opts...)
+}
+
+func (b interruptBackend) CopyObjectWithContext(ctx aws.Context, input *s3.CopyObjectInput, opts ...request.Option) (*s3.CopyObjectOutput, error) {
+	if b.copies.Add(-1) < 0 {
+		return nil, errors.New("interrupted copy")
+	}
+	return b.Backend.CopyObjectWithContext(ctx, input, opts...)
+}
+
+func (b interruptBackend) DeleteObjectsWithContext(ctx aws.Context, input *s3.DeleteObjectsInput, opts ...request.Option) (*s3.DeleteObjectsOutput, error) {
+	if b.deletes.Add(-1) < 0 {
+		return nil, errors.New("interrupted delete")
+	}
+	return b.Backend.DeleteObjectsWithContext(ctx, input, opts...)
+}
+
+func newInterrupted(t *testing.T, copies, deletes, records int64) *sss.SSS {
+	b := interruptBackend{
+		copies:  &atomic.Int64{},
+		deletes: &atomic.Int64{},
+		records: &atomic.Int64{},
+	}
+	b.copies.Store(copies)
+	b.deletes.Store(deletes)
+	b.records.Store(records)
+	fs, err := newSSS(sss.WithBackendMiddleware(func(backend sss.Backend) sss.Backend {
+		b.Backend = backend
+		return b
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
+
+func putFiles(t *testing.T, prefix string, n int) []string {
+	var files []string
+	for i := 0; i != n; i++ {
+		file := fmt.Sprintf("%s/%d/%04d", prefix, i%3, i)
+		err := s.PutContent(t.Context(), file, []byte(file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file[len(prefix):])
+	}
+	slices.Sort(files)
+	return files
+}
+
+func walkFiles(t *testing.T, prefix string) []string {
+	var files []string
+	err := s.Walk(t.Context(), prefix, func(fileInfo sss.FileInfo) error {
+		if !fileInfo.IsDir() {
+			files = append(files, fileInfo.Path()[len(prefix):])
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestMove(t *testing.T) {
+	err := s.PutContent(t.Context(), "/move/file", []byte("move"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Move(t.Context(), "/move/file", "/move/moved")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetContent(t.Context(), "/move/moved")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "move" {
+		t.Fatalf("expected %q, got %q", "move", got)
+	}
+	_, err = s.Stat(t.Context(), "/move/file")
+	if err == nil {
+		t.Fatal("expected source to be deleted")
+	}
+
+	err = s.DeleteAll(t.Context(), "/move")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMoveAll(t *testing.T) {
+	want := putFiles(t, "/move-all/src", 250)
+
+	err := newInterrupted(t, 150, 1, 100).MoveAll(t.Context(), "/move-all/src", "/move-all/dst")
+	if err == nil {
+		t.Fatal("expected interrupted move to fail")
+	}
+
+	err = s.MoveAll(t.Context(), "/move-all/src", "/move-all/other")
+	if err == nil {
+		t.Fatal("expected another move of the same source to fail")
+	}
+
+	err = s.MoveAll(t.Context(), "/move-all/src", "/move-all/dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := walkFiles(t, "/move-all/dst"); !slices.Equal(got, want) {
+		t.Fatalf("expected %d files in destination, got %d", len(want), len(got))
+	}
+	if got := walkFiles(t, "/move-all/src"); len(got) != 0 {
+		t.Fatalf("expected source to be empty, got %d files", len(got))
+	}
+	_, err = s.Stat(t.Context(), "/move-all/src.sss-move")
+	if err == nil {
+		t.Fatal("expected move record to be deleted")
+	}
+
+	err = s.DeleteAll(t.Context(), "/move-all")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMoveAllRollback(t *testing.T) {
+	want := putFiles(t, "/move-rollback/src", 1005)
+
+	// The first batch of deletes goes through, the second is interrupted.
+	err := newInterrupted(t, 2000, 1, 100).MoveAll(t.Context(), "/move-rollback/src", "/move-rollback/dst")
+	if err == nil {
+		t.Fatal("expected interrupted move to fail")
+	}
+	if got := walkFiles(t, "/move-rollback/src"); len(got) != len(want)-1000 {
+		t.Fatalf("expected %d files left in source, got %d", len(want)-1000, len(got))
+	}
+
+	err = s.RollbackMove(t.Context(), "/move-rollback/src", "/move-rollback/dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := walkFiles(t, "/move-rollback/src"); !slices.Equal(got, want) {
+		t.Fatalf("expected %d files in source, got %d", len(want), len(got))
+	}
+	if got := walkFiles(t, "/move-rollback/dst"); len(got) != 0 {
+		t.Fatalf("expected destination to be empty, got %d files", len(got))
+	}
+
+	err = s.DeleteAll(t.Context(), "/move-rollback")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMoveAllRollbackUnsaved(t *testing.T) {
+	want := putFiles(t, "/move-unsaved/src", 3)
+
+	// The record is saved when the move starts, after the copies and when deleting starts,
+	// so the deletes go through but the record is not updated after them.
+	err := newInterrupted(t, 2000, 1, 3).MoveAll(t.Context(), "/move-unsaved/src", "/move-unsaved/dst")
+	if err == nil {
+		t.Fatal("expected interrupted move to fail")
+	}
+	if got := walkFiles(t, "/move-unsaved/src"); len(got) != 0 {
+		t.Fatalf("expected source to be empty, got %d files", len(got))
+	}
+
+	err = s.RollbackMove(t.Context(), "/move-unsaved/src", "/move-unsaved/dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := walkFiles(t, "/move-unsaved/src"); !slices.Equal(got, want) {
+		t.Fatalf("expected %d files in source, got %d", len(want), len(got))
+	}
+	if got := walkFiles(t, "/move-unsaved/dst"); len(got) != 0 {
+		t.Fatalf("expected destination to be empty, got %d files", len(got))
+	}
+
+	err = s.DeleteAll(t.Context(), "/move-unsaved")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,63 @@
+package sss_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/wzshiming/sss"
+)
+
+func TestGetMultipartRootDirectory(t *testing.T) {
+	rooted, err := sss.NewSSS(sss.WithURL(memURL), sss.WithRootDirectory("test-multipart-root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "upload"
+
+	m, err := rooted.NewMultipart(t.Context(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		m.Cancel(context.Background())
+	})
+
+	got, err := rooted.GetMultipart(t.Context(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.UploadID() != m.UploadID() {
+		t.Fatalf("expected the upload %s, got %v", m.UploadID(), got)
+	}
+}
+
+func TestMemMultipartOtherKey(t *testing.T) {
+	mem, err := sss.NewSSS(sss.WithURL(memURL), sss.WithBackend(sss.NewMemBackend()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := mem.NewMultipart(t.Context(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		m.Cancel(context.Background())
+	})
+
+	other := mem.GetMultipartWithUploadID("b", m.UploadID())
+	_, err = other.AllParts(t.Context())
+	if !errors.Is(err, sss.ErrUploadNotFound) {
+		t.Fatalf("expected listing the parts of another key to fail with ErrUploadNotFound, got %v", err)
+	}
+	err = other.UploadPart(t.Context(), 1, bytes.NewReader([]byte("part")))
+	if !errors.Is(err, sss.ErrUploadNotFound) {
+		t.Fatalf("expected uploading a part to another key to fail with ErrUploadNotFound, got %v", err)
+	}
+	err = other.Cancel(t.Context())
+	if !errors.Is(err, sss.ErrUploadNotFound) {
+		t.Fatalf("expected aborting the upload of another key to fail with ErrUploadNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package sss_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wzshiming/sss"
+	"github.com/wzshiming/sss/metrics"
+	"github.com/wzshiming/sss/ssstest"
+)
+
+func TestObserver(t *testing.T) {
+	var mut sync.Mutex
+	var events []sss.Event
+	observed, err := newSSS(sss.WithObserver(func(e sss.Event) {
+		mut.Lock()
+		defer mut.Unlock()
+		events = append(events, e)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := "test-observer"
+	t.Cleanup(func() {
+		s.Delete(context.Background(), key)
+	})
+
+	err = observed.PutContent(t.Context(), key, []byte("observed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = observed.GetContent(t.Context(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = observed.Stat(t.Context(), key+"-missing")
+	if err == nil {
+		t.Fatal("expected an error for a missing object")
+	}
+
+	find := func(operation string) sss.Event {
+		t.Helper()
+		for _, e := range events {
+			if e.Operation == operation {
+				return e
+			}
+		}
+		t.Fatalf("expected a %s event in %v", operation, events)
+		return sss.Event{}
+	}
+
+	put := find("PutObject")
+	if put.Status != http.StatusOK || put.Bytes != 8 || !strings.HasSuffix(put.Key, key) || put.Err != nil {
+		t.Fatalf("unexpected put event %+v", put)
+	}
+	get := find("GetObject")
+	if get.Status != http.StatusOK || get.Bytes != 8 || get.Latency <= 0 {
+		t.Fatalf("unexpected get event %+v", get)
+	}
+	head := find("HeadObject")
+	if head.Status != http.StatusNotFound || head.Err == nil {
+		t.Fatalf("unexpected head event %+v", head)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	collector := metrics.NewCollector()
+
+	h := &slowDown{
+		handler:  ssstest.NewHandler(sss.NewMemBackend()),
+		attempts: map[string]int{},
+	}
+	srv := &ssstest.Server{Server: httptest.NewServer(h)}
+	defer srv.Close()
+
+	retried, err := srv.NewSSS(
+		sss.WithRetryPolicy(sss.RetryPolicy{MaxAttempts: 2, MinDelay: time.Millisecond}),
+		sss.WithObserver(collector.Observe),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = retried.PutContent(t.Context(), "metrics", []byte("measured"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	served := httptest.NewServer(collector.Handler(http.NotFoundHandler()))
+	defer served.Close()
+	resp, err := http.Get(served.URL + "/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	rec := httptest.NewRecorder()
+	collector.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	for _, want := range []string{
+		`sss_backend_requests_total{operation="PutObject",status="200"} 1`,
+		`sss_backend_bytes_total{operation="PutObject"} 8`,
+		`sss_backend_retries_total{operation="PutObject"} 1`,
+		`sss_backend_request_duration_seconds_count{operation="PutObject"} 1`,
+		`sss_serve_requests_total{method="GET",code="404"} 1`,
+		`sss_serve_request_duration_seconds_bucket{method="GET",le="+Inf"} 1`,
+	} {
+		if !strings.Contains(string(body), want+"\n") {
+			t.Fatalf("expected %q in the metrics:\n%s", want, body)
+		}
+	}
+}
+
+func TestObserverRetries(t *testing.T) {
+	b := &flakyBackend{
+		Backend:  sss.NewMemBackend(),
+		attempts: map[string]int{},
+	}
+	var mut sync.Mutex
+	var events []sss.Event
+	observed, err := sss.NewSSS(sss.WithBackend(b), sss.WithBucket("retry"), sss.WithChunkSize(1024),
+		sss.WithRetryPolicy(sss.RetryPolicy{MaxAttempts: 3, MinDelay: time.Millisecond}),
+		sss.WithObserver(func(e sss.Event) {
+			mut.Lock()
+			defer mut.Unlock()
+			events = append(events, e)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = observed.PutContent(t.Context(), "retry/put", []byte("observed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := observed.Writer(t.Context(), "retry/writer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.Write([]byte(strings.Repeat("0123456789", 150)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Commit(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	var puts, parts int
+	for _, e := range events {
+		switch e.Operation {
+		case "PutObject":
+			puts++
+		case "UploadPart":
+			parts++
+		default:
+			continue
+		}
+		if e.Retries != 1 || e.Err != nil {
+			t.Fatalf("expected %s to succeed after 1 retry, got %+v", e.Operation, e)
+		}
+	}
+	if puts != 1 || parts != 2 {
+		t.Fatalf("expected 1 PutObject and 2 UploadPart events, got %d and %d", puts, parts)
+	}
+}
@@ -0,0 +1,209 @@
+package sss_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wzshiming/sss"
+	"github.com/wzshiming/sss/serve"
+	"github.com/wzshiming/sss/ssstest"
+)
+
+func TestFilePresign(t *testing.T) {
+	var handler http.Handler
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(rw, r)
+	}))
+	defer srv.Close()
+
+	fs, err := sss.NewSSS(sss.WithURL(`file://` + filepath.ToSlash(t.TempDir()) + `?signsecret=secret&signendpoint=` + srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler = serve.NewServe(serve.WithSSS(fs))
+
+	content := "Hello, Presign!"
+	key := "/presign/hello.txt"
+
+	put := func(u string) int {
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodPut, u, strings.NewReader(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := put(srv.URL + key); code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected unsigned put to get %d, got %d", http.StatusMethodNotAllowed, code)
+	}
+
+	u, err := fs.SignPut(key, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := put(strings.Replace(u, "X-Sss-Signature=", "X-Sss-Signature=00", 1)); code != http.StatusForbidden {
+		t.Fatalf("expected tampered put to get %d, got %d", http.StatusForbidden, code)
+	}
+	if code := put(u); code != http.StatusCreated {
+		t.Fatalf("expected signed put to get %d, got %d", http.StatusCreated, code)
+	}
+
+	u, err = fs.SignGet(key, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != content {
+		t.Fatalf("expected %q, got %q", content, body)
+	}
+
+	u, err = fs.SignGet(key, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = http.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected expired get to get %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+}
+
+func TestFilePresignRootDirectory(t *testing.T) {
+	var handler http.Handler
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(rw, r)
+	}))
+	defer srv.Close()
+
+	dir := filepath.ToSlash(t.TempDir())
+	rooted, err := sss.NewSSS(sss.WithURL(`file://` + dir + `?rootdirectory=/root&signsecret=secret&signendpoint=` + srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler = serve.NewServe(serve.WithSSS(rooted))
+
+	content := "Hello, Presign!"
+	key := "/presign/hello.txt"
+
+	u, err := rooted.SignPut(key, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodPut, u, strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected signed put to get %d, got %d", http.StatusCreated, resp.StatusCode)
+	}
+
+	u, err = rooted.SignGet(key, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = http.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != content {
+		t.Fatalf("expected %q, got %q", content, body)
+	}
+
+	fs, err := sss.NewSSS(sss.WithURL(`file://` + dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err = fs.GetContent(t.Context(), "/root"+key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != content {
+		t.Fatalf("expected %q below the root directory, got %q", content, body)
+	}
+}
+
+func TestFakePresign(t *testing.T) {
+	fake := ssstest.NewSSS(t)
+
+	content := "Hello, Presign!"
+	key := "/presign/hello.txt"
+
+	u, err := fake.SignPut(key, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodPut, u, strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected signed put to get %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	u, err = fake.SignGet(key, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = http.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content {
+		t.Fatalf("expected %q, got %q", content, got)
+	}
+
+	u, err = fake.SignGet(key, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Second)
+	resp, err = http.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected expired get to get %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+}
@@ -0,0 +1,235 @@
+package sss_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/wzshiming/sss"
+	"github.com/wzshiming/sss/ssstest"
+)
+
+// slowDown fails the first attempt of every upload with 503 SlowDown.
+type slowDown struct {
+	handler http.Handler
+
+	mut      sync.Mutex
+	attempts map[string]int
+}
+
+func (h *slowDown) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut {
+		key := r.URL.Path + "?" + r.URL.Query().Get("uploadId") + "&" + r.URL.Query().Get("partNumber")
+		h.mut.Lock()
+		h.attempts[key]++
+		attempt := h.attempts[key]
+		h.mut.Unlock()
+		if attempt == 1 {
+			rw.Header().Set("Content-Type", "application/xml")
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(rw, `<Error><Code>SlowDown</Code><Message>Please reduce your request rate.</Message></Error>`)
+			return
+		}
+	}
+	h.handler.ServeHTTP(rw, r)
+}
+
+func TestRetryPolicy(t *testing.T) {
+	h := &slowDown{
+		handler:  ssstest.NewHandler(sss.NewMemBackend()),
+		attempts: map[string]int{},
+	}
+	srv := &ssstest.Server{Server: httptest.NewServer(h)}
+	defer srv.Close()
+
+	s, err := srv.NewSSS(sss.WithChunkSize(1024), sss.WithRetryPolicy(sss.RetryPolicy{
+		MaxAttempts: 3,
+		MinDelay:    time.Millisecond,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.PutContent(t.Context(), "retry/put", []byte("put"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := bytes.Repeat([]byte("0123456789"), 300)
+	for _, concurrency := range []int{1, 2} {
+		key := fmt.Sprintf("retry/writer-%d", concurrency)
+		w, err := s.Writer(t.Context(), key, sss.WithConcurrency(concurrency))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = w.Write(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = w.Commit(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Close()
+
+		got, err := s.GetContent(t.Context(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Fatalf("expected the content written with concurrency %d", concurrency)
+		}
+	}
+
+	m, err := s.NewMultipart(t.Context(), "retry/multipart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.UploadPart(t.Context(), 1, bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Commit(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, attempts := range h.attempts {
+		if attempts != 2 {
+			t.Fatalf("expected 2 attempts of %s, got %d", key, attempts)
+		}
+	}
+
+	noRetry, err := srv.NewSSS(sss.WithRetryPolicy(sss.RetryPolicy{
+		MaxAttempts: 3,
+		MinDelay:    time.Millisecond,
+		Retryable: func(err error) bool {
+			return false
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = noRetry.PutContent(t.Context(), "retry/not-retryable", []byte("put"))
+	if err == nil {
+		t.Fatal("expected an error not retryable to fail the put")
+	}
+}
+
+// flakyBackend fails the first attempt of every call with 503 SlowDown.
+type flakyBackend struct {
+	sss.Backend
+
+	mut      sync.Mutex
+	attempts map[string]int
+}
+
+func (b *flakyBackend) fail(call string) error {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	b.attempts[call]++
+	if b.attempts[call] == 1 {
+		return awserr.NewRequestFailure(awserr.New("SlowDown", "Please reduce your request rate.", nil), http.StatusServiceUnavailable, "")
+	}
+	return nil
+}
+
+func (b *flakyBackend) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	if err := b.fail("PutObject " + *input.Key); err != nil {
+		// Fail after reading the body, as a request cut short does.
+		io.Copy(io.Discard, input.Body)
+		return nil, err
+	}
+	return b.Backend.PutObjectWithContext(ctx, input, opts...)
+}
+
+func (b *flakyBackend) UploadPartWithContext(ctx aws.Context, input *s3.UploadPartInput, opts ...request.Option) (*s3.UploadPartOutput, error) {
+	if err := b.fail(fmt.Sprintf("UploadPart %s %d", *input.Key, *input.PartNumber)); err != nil {
+		io.Copy(io.Discard, input.Body)
+		return nil, err
+	}
+	return b.Backend.UploadPartWithContext(ctx, input, opts...)
+}
+
+func (b *flakyBackend) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	if err := b.fail("GetObject " + *input.Key); err != nil {
+		return nil, err
+	}
+	return b.Backend.GetObjectWithContext(ctx, input, opts...)
+}
+
+func (b *flakyBackend) ListObjectsV2PagesWithContext(ctx aws.Context, input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool, opts ...request.Option) error {
+	if err := b.fail("ListObjectsV2 " + *input.Prefix); err != nil {
+		return err
+	}
+	return b.Backend.ListObjectsV2PagesWithContext(ctx, input, fn, opts...)
+}
+
+func TestRetryPolicyBackend(t *testing.T) {
+	b := &flakyBackend{
+		Backend:  sss.NewMemBackend(),
+		attempts: map[string]int{},
+	}
+	s, err := sss.NewSSS(sss.WithBackend(b), sss.WithBucket("retry"), sss.WithChunkSize(1024), sss.WithRetryPolicy(sss.RetryPolicy{
+		MaxAttempts: 3,
+		MinDelay:    time.Millisecond,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := bytes.Repeat([]byte("0123456789"), 300)
+	err = s.PutContent(t.Context(), "retry/put", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := s.Writer(t.Context(), "retry/writer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.Write(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Commit(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	for _, key := range []string{"retry/put", "retry/writer"} {
+		got, err := s.GetContent(t.Context(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Fatalf("expected the content of %s", key)
+		}
+	}
+	n := 0
+	err = s.Walk(t.Context(), "retry", func(sss.FileInfo) error {
+		n++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n == 0 {
+		t.Fatal("expected the walk to find the objects")
+	}
+
+	for call, attempts := range b.attempts {
+		if attempts != 2 {
+			t.Fatalf("expected 2 attempts of %s, got %d", call, attempts)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package sss_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wzshiming/sss/serve"
+)
+
+func TestServe(t *testing.T) {
+	srv := httptest.NewServer(serve.NewServe(
+		serve.WithSSS(s),
+		serve.WithAllowList(true),
+		serve.WithAllowPut(true),
+		serve.WithAllowDelete(true),
+	))
+	defer srv.Close()
+
+	content := "Hello, Serve!"
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodPut, srv.URL+"/serve/hello.txt", strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, resp.StatusCode)
+	}
+
+	req, err = http.NewRequestWithContext(t.Context(), http.MethodGet, srv.URL+"/serve/hello.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Range", "bytes=7-")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		t.Fatalf("expected status %d, got %d", http.StatusPartialContent, resp.StatusCode)
+	}
+	if string(body) != content[7:] {
+		t.Fatalf("expected %q, got %q", content[7:], body)
+	}
+
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag")
+	}
+	req, err = http.NewRequestWithContext(t.Context(), http.MethodGet, srv.URL+"/serve/hello.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-None-Match", etag)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("expected status %d, got %d", http.StatusNotModified, resp.StatusCode)
+	}
+
+	resp, err = http.Get(srv.URL + "/serve/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), "hello.txt") {
+		t.Fatalf("expected listing to contain hello.txt, got %s", body)
+	}
+
+	req, err = http.NewRequestWithContext(t.Context(), http.MethodPut, srv.URL+"/serve/hello.txt", strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-None-Match", "*")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("expected status %d, got %d", http.StatusPreconditionFailed, resp.StatusCode)
+	}
+
+	req, err = http.NewRequestWithContext(t.Context(), http.MethodDelete, srv.URL+"/serve/hello.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	resp, err = http.Get(srv.URL + "/serve/hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
@@ -0,0 +1,150 @@
+package sss_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/wzshiming/sss"
+	"github.com/wzshiming/sss/ssstest"
+)
+
+var sseKey = bytes.Repeat([]byte("k"), 32)
+
+func testSSECustomerKey(t *testing.T, enc, plain *sss.SSS) {
+	src := "test-ssec"
+	content := []byte("Hello, SSE-C!")
+
+	err := enc.PutContent(t.Context(), src, content)
+	if err != nil {
+		var aerr awserr.Error
+		if errors.As(err, &aerr) && aerr.Code() == "ConfigError" {
+			t.Skip("SSE-C requires https:", err)
+		}
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx := context.Background()
+		enc.Delete(ctx, src)
+		enc.Delete(ctx, src+"-copy")
+		enc.Delete(ctx, src+"-multipart")
+	})
+
+	got, err := enc.GetContent(t.Context(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("expected %q, got %q", content, got)
+	}
+
+	_, err = plain.GetContent(t.Context(), src)
+	if err == nil {
+		t.Fatal("expected reading without the key to fail")
+	}
+
+	err = enc.Copy(t.Context(), src, src+"-copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = enc.GetContent(t.Context(), src+"-copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("expected copied %q, got %q", content, got)
+	}
+
+	w, err := enc.Writer(t.Context(), src+"-multipart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Repeat(content, 1<<20)
+	_, err = w.Write(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Commit(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	r, err := enc.ReaderWithOffsetAndLimit(t.Context(), src+"-multipart", int64(len(content)), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("expected ranged %q, got %q", content, got)
+	}
+}
+
+func TestSSECustomerKey(t *testing.T) {
+	enc, err := newSSS(sss.WithSSECustomerKey(sseKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSSECustomerKey(t, enc, s)
+}
+
+func TestFakeSSECustomerKey(t *testing.T) {
+	srv := &ssstest.Server{
+		Server: httptest.NewTLSServer(ssstest.NewHandler(sss.NewMemBackend())),
+	}
+	defer srv.Close()
+	// A CA bundle from the environment would replace the root of the test certificate.
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	enc, err := srv.NewSSS(sss.WithHTTPClient(srv.Client()), sss.WithSSECustomerKey(sseKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := enc.SignPut("/presign/ssec.txt", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodPut, u, strings.NewReader("signed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := md5.Sum(sseKey)
+	req.Header.Set("X-Amz-Server-Side-Encryption-Customer-Algorithm", "AES256")
+	req.Header.Set("X-Amz-Server-Side-Encryption-Customer-Key", base64.StdEncoding.EncodeToString(sseKey))
+	req.Header.Set("X-Amz-Server-Side-Encryption-Customer-Key-Md5", base64.StdEncoding.EncodeToString(sum[:]))
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected signed put to get %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	got, err := enc.GetContent(t.Context(), "/presign/ssec.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "signed" {
+		t.Fatalf("expected %q, got %q", "signed", got)
+	}
+
+	plain, err := srv.NewSSS(sss.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSSECustomerKey(t, enc, plain)
+}
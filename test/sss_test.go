@@ -2,16 +2,26 @@ package sss_test
 
 import (
 	"bytes"
+	"context"
 	crand "crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"io"
+	iofs "io/fs"
+	"maps"
 	"math/rand"
+	"net/http"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/wzshiming/sss"
 )
 
@@ -21,7 +31,7 @@ func TestBasic(t *testing.T) {
 
 	_, err := s.StatHead(t.Context(), key)
 	if err != nil {
-		if s3Err, ok := err.(awserr.Error); ok && s3Err.Code() != "NotFound" {
+		if !errors.Is(err, sss.ErrNotExist) || !errors.Is(err, iofs.ErrNotExist) {
 			t.Fatalf("failed to stat head: %v", err)
 		}
 	} else {
@@ -63,7 +73,7 @@ func TestBasic(t *testing.T) {
 
 	_, err = s.StatHead(t.Context(), key)
 	if err != nil {
-		if s3Err, ok := err.(awserr.Error); ok && s3Err.Code() != "NotFound" {
+		if !errors.Is(err, sss.ErrNotExist) {
 			t.Fatalf("failed to stat head: %v", err)
 		}
 	} else {
@@ -400,3 +410,592 @@ func TestMultipartFileWriter(t *testing.T) {
 		t.Fatalf("expected %s, got %s", wantHex, gotHex)
 	}
 }
+
+func TestConcurrentFileWriter(t *testing.T) {
+	key := "test-concurrent-object"
+	want := make([]byte, rand.Intn(1024*1024)+8*s.ChunkSize())
+	_, err := crand.Read(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := s.Writer(t.Context(), key, sss.WithConcurrency(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = io.Copy(w, bytes.NewReader(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = w.Commit(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Size() != int64(len(want)) {
+		t.Fatalf("expected size %d, got %d", len(want), w.Size())
+	}
+
+	got, err := s.GetContent(t.Context(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Fatalf("expected %d bytes, got %d bytes with different content", len(want), len(got))
+	}
+}
+
+type failPartBackend struct {
+	sss.Backend
+	partNumber int64
+}
+
+func (b failPartBackend) UploadPartWithContext(ctx aws.Context, input *s3.UploadPartInput, opts ...request.Option) (*s3.UploadPartOutput, error) {
+	if *input.PartNumber == b.partNumber {
+		return nil, errors.New("injected failure")
+	}
+	return b.Backend.UploadPartWithContext(ctx, input, opts...)
+}
+
+func TestConcurrentFileWriterError(t *testing.T) {
+	fs, err := newSSS(sss.WithBackendMiddleware(func(b sss.Backend) sss.Backend {
+		return failPartBackend{Backend: b, partNumber: 2}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := fs.Writer(t.Context(), "test-concurrent-error-object", sss.WithConcurrency(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Cancel(t.Context())
+
+	chunk := make([]byte, fs.ChunkSize())
+	var writeErr error
+	for i := 0; i < 16 && writeErr == nil; i++ {
+		_, writeErr = w.Write(chunk)
+	}
+
+	err = w.Commit(t.Context())
+	if err == nil || !strings.Contains(err.Error(), "injected failure") {
+		t.Fatalf("expected injected failure on commit, got %v", err)
+	}
+	if writeErr != nil && !strings.Contains(writeErr.Error(), "injected failure") {
+		t.Fatalf("expected injected failure on write, got %v", writeErr)
+	}
+}
+
+func TestParallelReader(t *testing.T) {
+	key := "test-parallel-object"
+	want := make([]byte, rand.Intn(1024*1024)+3*1024*1024)
+	_, err := crand.Read(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.PutContent(t.Context(), key, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, offset := range []int64{0, 1, 1024*1024 + 7, int64(len(want))} {
+		r, err := s.ParallelReader(t.Context(), key,
+			sss.WithReadOffset(offset),
+			sss.WithReadChunkSize(256*1024),
+			sss.WithReadWindow(3),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(got, want[offset:]) {
+			t.Fatalf("offset %d: expected %d bytes, got %d bytes with different content", offset, len(want[offset:]), len(got))
+		}
+	}
+
+	r, err := s.ParallelReader(t.Context(), key, sss.WithReadChunkSize(256*1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.Read(make([]byte, 1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMultipartCopy(t *testing.T) {
+	fs, err := newSSS(sss.WithMultipartCopyThreshold(6 * 1024 * 1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := "test-multipart-copy-source"
+	dst := "test-multipart-copy-dest"
+	want := make([]byte, rand.Intn(1024*1024)+13*1024*1024)
+	_, err = crand.Read(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fs.PutContent(t.Context(), src, want, sss.WithContentType("application/x-test"), sss.WithContentDisposition("attachment"), sss.WithMetadata(map[string]string{"build-id": "42"}), sss.WithTags(map[string]string{"team": "storage"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fs.Copy(t.Context(), src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := fs.GetContent(t.Context(), dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("expected %d bytes, got %d bytes with different content", len(want), len(got))
+	}
+
+	info, err := fs.StatHead(t.Context(), dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fie := info.Sys().(sss.FileInfoExpansion)
+	if aws.StringValue(fie.ContentType) != "application/x-test" {
+		t.Fatalf("expected content type %q, got %q", "application/x-test", aws.StringValue(fie.ContentType))
+	}
+	if aws.StringValue(fie.ContentDisposition) != "attachment" {
+		t.Fatalf("expected content disposition %q, got %q", "attachment", aws.StringValue(fie.ContentDisposition))
+	}
+	if fie.Metadata["build-id"] != "42" {
+		t.Fatalf("expected metadata build-id %q, got %v", "42", fie.Metadata)
+	}
+
+	tags, err := fs.GetTags(t.Context(), dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags["team"] != "storage" {
+		t.Fatalf("expected tag team %q, got %v", "storage", tags)
+	}
+}
+
+func TestCopyBetween(t *testing.T) {
+	src := "test-copy-between"
+	want := []byte("Hello, CopyBetween!")
+	err := s.PutContent(t.Context(), src, want, sss.WithContentType("application/x-test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	same, err := newSSS(sss.WithRootDirectory("/copy-between"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := sss.NewSSS(sss.WithBackend(sss.NewMemBackend()), sss.WithBucket("other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		s.DeleteAll(context.Background(), "/copy-between")
+	})
+
+	for _, dst := range []*sss.SSS{same, other} {
+		err = sss.CopyBetween(t.Context(), s, src, dst, "/dest")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, info, err := dst.GetContentAndInfo(t.Context(), "/dest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("expected %q, got %q", want, got)
+		}
+		fie := info.Sys().(sss.FileInfoExpansion)
+		if aws.StringValue(fie.ContentType) != "application/x-test" {
+			t.Fatalf("expected content type %q, got %q", "application/x-test", aws.StringValue(fie.ContentType))
+		}
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	src := "test-metadata"
+	want := map[string]string{"build-id": "42", "owner": "ci"}
+
+	w, err := s.Writer(t.Context(), src, sss.WithContentType("application/x-test"), sss.WithMetadata(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.Write([]byte("Hello, Metadata!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Commit(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	_, info, err := s.GetContentAndInfo(t.Context(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Sys().(sss.FileInfoExpansion).Metadata; !maps.Equal(got, want) {
+		t.Fatalf("expected metadata %v, got %v", want, got)
+	}
+
+	err = s.Copy(t.Context(), src, src+"-preserved")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err = s.StatHead(t.Context(), src+"-preserved")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Sys().(sss.FileInfoExpansion).Metadata; !maps.Equal(got, want) {
+		t.Fatalf("expected preserved metadata %v, got %v", want, got)
+	}
+
+	replaced := map[string]string{"owner": "release"}
+	err = s.Copy(t.Context(), src, src+"-replaced", sss.WithMetadata(replaced))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err = s.StatHead(t.Context(), src+"-replaced")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fie := info.Sys().(sss.FileInfoExpansion)
+	if !maps.Equal(fie.Metadata, replaced) {
+		t.Fatalf("expected replaced metadata %v, got %v", replaced, fie.Metadata)
+	}
+	if aws.StringValue(fie.ContentType) != "application/x-test" {
+		t.Fatalf("expected content type %q, got %q", "application/x-test", aws.StringValue(fie.ContentType))
+	}
+}
+
+func TestTags(t *testing.T) {
+	src := "test-tags"
+	want := map[string]string{"team": "storage", "cost-center": "42"}
+
+	err := s.PutContent(t.Context(), src, []byte("Hello, Tags!"), sss.WithTags(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetTags(t.Context(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(got, want) {
+		t.Fatalf("expected tags %v, got %v", want, got)
+	}
+
+	err = s.Copy(t.Context(), src, src+"-copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = s.GetTags(t.Context(), src+"-copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(got, want) {
+		t.Fatalf("expected copied tags %v, got %v", want, got)
+	}
+
+	want = map[string]string{"team": "release"}
+	err = s.SetTags(t.Context(), src, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = s.GetTags(t.Context(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(got, want) {
+		t.Fatalf("expected tags %v, got %v", want, got)
+	}
+
+	err = s.DeleteTags(t.Context(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = s.GetTags(t.Context(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("expected no tags, got %v", got)
+	}
+}
+
+// noTaggingBackend is a backend without object tagging, like some S3 compatible storages.
+type noTaggingBackend struct {
+	sss.Backend
+	tagging *string
+}
+
+func (b *noTaggingBackend) GetObjectTaggingWithContext(ctx aws.Context, input *s3.GetObjectTaggingInput, opts ...request.Option) (*s3.GetObjectTaggingOutput, error) {
+	return nil, awserr.NewRequestFailure(awserr.New("NotImplemented", "tagging is not implemented", nil), http.StatusNotImplemented, "")
+}
+
+func (b *noTaggingBackend) CreateMultipartUploadWithContext(ctx aws.Context, input *s3.CreateMultipartUploadInput, opts ...request.Option) (*s3.CreateMultipartUploadOutput, error) {
+	b.tagging = input.Tagging
+	return b.Backend.CreateMultipartUploadWithContext(ctx, input, opts...)
+}
+
+func TestCopyWithoutTagging(t *testing.T) {
+	b := &noTaggingBackend{}
+	fs, err := newSSS(sss.WithMultipartCopyThreshold(6*1024*1024), sss.WithBackendMiddleware(func(backend sss.Backend) sss.Backend {
+		b.Backend = backend
+		return b
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := sss.NewSSS(sss.WithBackend(sss.NewMemBackend()), sss.WithBucket("other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := "test-copy-without-tagging"
+	t.Cleanup(func() {
+		s.Delete(context.Background(), src)
+		s.Delete(context.Background(), src+"-copy")
+	})
+	want := make([]byte, 13*1024*1024)
+	_, err = crand.Read(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fs.PutContent(t.Context(), src, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fs.Copy(t.Context(), src, src+"-copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.tagging != nil {
+		t.Fatalf("expected no tagging on the copy, got %q", *b.tagging)
+	}
+	err = sss.CopyBetween(t.Context(), fs, src, other, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := other.GetContent(t.Context(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("expected %d bytes, got %d bytes with different content", len(want), len(got))
+	}
+}
+
+func TestConditionalWrite(t *testing.T) {
+	key := "test-conditional"
+	t.Cleanup(func() {
+		s.Delete(context.Background(), key)
+	})
+
+	err := s.PutContent(t.Context(), key, []byte("v1"), sss.WithIfNoneMatch("*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.PutContent(t.Context(), key, []byte("v2"), sss.WithIfNoneMatch("*"))
+	if !errors.Is(err, sss.ErrPreconditionFailed) {
+		t.Fatalf("expected creating an existing object to fail with ErrPreconditionFailed, got %v", err)
+	}
+
+	info, err := s.StatHead(t.Context(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := *info.Sys().(sss.FileInfoExpansion).ETag
+
+	err = s.PutContent(t.Context(), key, []byte("v2"), sss.WithIfMatch(etag))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The swap above changed the ETag.
+	err = s.PutContent(t.Context(), key, []byte("v3"), sss.WithIfMatch(etag))
+	if !errors.Is(err, sss.ErrPreconditionFailed) {
+		t.Fatalf("expected a stale ETag to fail with ErrPreconditionFailed, got %v", err)
+	}
+
+	w, err := s.Writer(t.Context(), key, sss.WithIfNoneMatch("*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.Write([]byte("v3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Commit(t.Context())
+	if !errors.Is(err, sss.ErrPreconditionFailed) {
+		t.Fatalf("expected committing over an existing object to fail with ErrPreconditionFailed, got %v", err)
+	}
+	w.Close()
+	_, err = s.GetMultipart(t.Context(), key)
+	if !errors.Is(err, sss.ErrUploadNotFound) {
+		t.Fatalf("expected the failed upload to be aborted, got %v", err)
+	}
+
+	info, err = s.StatHead(t.Context(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := s.NewMultipart(t.Context(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.UploadPart(t.Context(), 1, strings.NewReader("v3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Commit(t.Context(), sss.WithIfMatch(*info.Sys().(sss.FileInfoExpansion).ETag))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetContent(t.Context(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "v3" {
+		t.Fatalf("expected %q, got %q", "v3", got)
+	}
+}
+
+func TestNotExist(t *testing.T) {
+	key := "test-not-exist"
+
+	_, err := s.GetContent(t.Context(), key)
+	if !errors.Is(err, sss.ErrNotExist) || !errors.Is(err, iofs.ErrNotExist) {
+		t.Fatalf("expected reading a missing object to fail with ErrNotExist, got %v", err)
+	}
+	var sssErr *sss.Error
+	if !errors.As(err, &sssErr) || sssErr.Path != key {
+		t.Fatalf("expected an *sss.Error of %q, got %v", key, err)
+	}
+	_, err = s.Stat(t.Context(), key)
+	if !errors.Is(err, sss.ErrNotExist) {
+		t.Fatalf("expected stating a missing object to fail with ErrNotExist, got %v", err)
+	}
+	err = s.Copy(t.Context(), key, key+"-copy")
+	if !errors.Is(err, sss.ErrNotExist) {
+		t.Fatalf("expected copying a missing object to fail with ErrNotExist, got %v", err)
+	}
+	_, err = s.WriterWithAppendByUploadID(t.Context(), key, "missing")
+	if !errors.Is(err, sss.ErrUploadNotFound) {
+		t.Fatalf("expected appending to a missing upload to fail with ErrUploadNotFound, got %v", err)
+	}
+}
+
+func TestErrorPath(t *testing.T) {
+	rooted, err := newSSS(sss.WithRootDirectory("test-error-root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "test-error-path"
+	t.Cleanup(func() {
+		rooted.Delete(context.Background(), key)
+	})
+
+	err = rooted.PutContent(t.Context(), key, []byte("v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := rooted.Writer(t.Context(), key, sss.WithIfNoneMatch("*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.Write([]byte("v2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Commit(t.Context())
+	w.Close()
+	var sssErr *sss.Error
+	if !errors.As(err, &sssErr) || sssErr.Path != key {
+		t.Fatalf("expected an *sss.Error of %q, got %v", key, err)
+	}
+}
+
+func TestAbortStaleMultipart(t *testing.T) {
+	prefix := "test-abort-stale/"
+	key := prefix + "upload"
+
+	m, err := s.NewMultipart(t.Context(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		m.Cancel(context.Background())
+	})
+	err = m.UploadPart(t.Context(), 1, strings.NewReader("orphaned"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aborted, err := s.AbortStaleMultipart(t.Context(), prefix, time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aborted) != 0 {
+		t.Fatalf("expected a recent upload to be kept, got %d uploads", len(aborted))
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	aborted, err = s.AbortStaleMultipart(t.Context(), prefix, time.Millisecond, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aborted) != 1 || aborted[0].UploadID() != m.UploadID() || aborted[0].Size != 8 {
+		t.Fatalf("expected the upload of 8 bytes to be reported, got %+v", aborted)
+	}
+	if aborted[0].Initiated().IsZero() {
+		t.Fatal("expected the initiated time of the upload")
+	}
+	_, err = s.GetMultipart(t.Context(), key)
+	if err != nil {
+		t.Fatalf("expected a dry run to keep the upload, got %v", err)
+	}
+
+	aborted, err = s.AbortStaleMultipart(t.Context(), prefix, time.Millisecond, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aborted) != 1 || aborted[0].Size != 8 {
+		t.Fatalf("expected the upload of 8 bytes to be aborted, got %+v", aborted)
+	}
+	_, err = s.GetMultipart(t.Context(), key)
+	if !errors.Is(err, sss.ErrUploadNotFound) {
+		t.Fatalf("expected the upload to be aborted, got %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package sss_test
+
+import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"testing"
+
+	"github.com/wzshiming/sss"
+)
+
+func listVersions(t *testing.T, path string) []sss.Version {
+	t.Helper()
+	var versions []sss.Version
+	err := s.ListVersions(context.Background(), path, func(v sss.Version) bool {
+		versions = append(versions, v)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return versions
+}
+
+func TestVersions(t *testing.T) {
+	ctx := context.Background()
+	path := "/versions/obj"
+
+	t.Cleanup(func() {
+		for _, v := range listVersions(t, "/versions") {
+			s.Delete(ctx, v.Path(), sss.WithVersionID(v.VersionID))
+		}
+	})
+
+	err := s.PutContent(ctx, path, []byte("one"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := s.StatHead(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1 := info.Sys().(sss.FileInfoExpansion).VersionID
+	if v1 == nil || *v1 == "null" {
+		t.Skip("backend is not versioned")
+	}
+
+	err = s.PutContent(ctx, path, []byte("two"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.PutContent(ctx, path+"x", []byte("other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions := listVersions(t, path)
+	if len(versions) != 2 {
+		t.Fatalf("expected 2 versions, got %d", len(versions))
+	}
+	if !versions[0].IsLatest || versions[1].IsLatest || versions[1].VersionID != *v1 {
+		t.Fatalf("unexpected versions %+v", versions)
+	}
+	v2 := versions[0].VersionID
+
+	content, err := s.GetContent(ctx, path, sss.WithVersionID(*v1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "one" {
+		t.Errorf("expected content %q, got %q", "one", content)
+	}
+
+	err = s.Delete(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.StatHead(ctx, path)
+	if err == nil {
+		t.Fatal("expected deleted object to be hidden")
+	}
+	versions = listVersions(t, path)
+	if len(versions) != 3 || !versions[0].IsDeleteMarker || !versions[0].IsLatest {
+		t.Fatalf("expected a delete marker on top, got %+v", versions)
+	}
+
+	err = s.RestoreVersion(ctx, path, *v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err = s.GetContent(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "one" {
+		t.Errorf("expected restored content %q, got %q", "one", content)
+	}
+
+	err = s.Delete(ctx, path, sss.WithVersionID(v2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.StatHead(ctx, path, sss.WithVersionID(v2))
+	if err == nil {
+		t.Fatal("expected deleted version to be gone")
+	}
+	for _, v := range listVersions(t, path) {
+		if v.VersionID == v2 {
+			t.Fatalf("expected version %s to be gone", v2)
+		}
+	}
+}
+
+func TestVersionsStop(t *testing.T) {
+	ctx := context.Background()
+	path := "/versions-stop/obj"
+
+	t.Cleanup(func() {
+		for _, v := range listVersions(t, "/versions-stop") {
+			s.Delete(ctx, v.Path(), sss.WithVersionID(v.VersionID))
+		}
+	})
+
+	for _, content := range []string{"one", "two", "three"} {
+		err := s.PutContent(ctx, path, []byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := s.Delete(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listVersions(t, path)) != 4 {
+		t.Skip("backend is not versioned")
+	}
+
+	var got []sss.Version
+	err = s.ListVersions(ctx, path, func(v sss.Version) bool {
+		got = append(got, v)
+		return len(got) < 2
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || !got[0].IsDeleteMarker || !got[0].IsLatest || got[1].IsDeleteMarker {
+		t.Fatalf("expected the delete marker and the newest version, got %+v", got)
+	}
+}
+
+func TestRestoreVersionMultipart(t *testing.T) {
+	ctx := context.Background()
+	path := "/versions-multipart/obj"
+
+	fs, err := newSSS(sss.WithMultipartCopyThreshold(6 * 1024 * 1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		for _, v := range listVersions(t, "/versions-multipart") {
+			s.Delete(ctx, v.Path(), sss.WithVersionID(v.VersionID))
+		}
+	})
+
+	want := make([]byte, 13*1024*1024)
+	_, err = crand.Read(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fs.PutContent(ctx, path, want, sss.WithTags(map[string]string{"team": "storage"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := fs.StatHead(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1 := info.Sys().(sss.FileInfoExpansion).VersionID
+	if v1 == nil || *v1 == "null" {
+		t.Skip("backend is not versioned")
+	}
+	err = fs.PutContent(ctx, path, []byte("two"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fs.RestoreVersion(ctx, path, *v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := fs.GetContent(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("expected %d restored bytes, got %d bytes with different content", len(want), len(got))
+	}
+	tags, err := fs.GetTags(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags["team"] != "storage" {
+		t.Fatalf("expected tag team %q, got %v", "storage", tags)
+	}
+}
@@ -0,0 +1,44 @@
+package sss_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/wzshiming/sss"
+)
+
+func TestWalkSkipDirSibling(t *testing.T) {
+	dir := "/test-walk-skip"
+	keys := []string{
+		dir + "/a/b",
+		dir + "/ab/c",
+	}
+	t.Cleanup(func() {
+		for _, key := range keys {
+			s.Delete(context.Background(), key)
+		}
+	})
+	for _, key := range keys {
+		err := s.PutContent(t.Context(), key, []byte("walk"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	err := s.Walk(t.Context(), dir, func(fileInfo sss.FileInfo) error {
+		got = append(got, fileInfo.Path())
+		if fileInfo.IsDir() && fileInfo.Path() == dir+"/a" {
+			return sss.ErrSkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{dir + "/a", dir + "/ab", dir + "/ab/c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,31 @@
+package sss_test
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWriterEmpty(t *testing.T) {
+	key := "test-writer-empty"
+	t.Cleanup(func() {
+		s.Delete(context.Background(), key)
+	})
+
+	w, err := s.Writer(t.Context(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Commit(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	info, err := s.StatHead(t.Context(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 0 {
+		t.Fatalf("expected an empty object, got %d bytes", info.Size())
+	}
+}